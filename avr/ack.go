@@ -0,0 +1,61 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// WithAck makes SendCommand wait up to timeout for the amp to echo
+// each command, resending it up to retries more times before
+// returning ErrNoAck. Commands ending in UP or DOWN, such as "MVUP",
+// are acknowledged by any report of the same parameter, such as
+// "MV46"; others must be echoed exactly.
+//
+// Only use WithAck with commands whose echo the amp sends, and with
+// commands that are safe to repeat.
+func WithAck(retries int, timeout time.Duration) Option {
+	return func(a *Amp) {
+		if retries < 0 {
+			retries = 0
+		}
+		a.ackRetries = retries
+		a.ackTimeout = timeout
+	}
+}
+
+// sendAck sends cmd until the amp acknowledges it.
+func (a *Amp) sendAck(ctx context.Context, cmd string) error {
+	match := ackMatch(cmd)
+	for try := 0; try <= a.ackRetries; try++ {
+		tctx, cancel := context.WithTimeout(ctx, a.ackTimeout)
+		_, err := a.query(tctx, cmd, match)
+		cancel()
+		switch {
+		case err == nil:
+			return nil
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case !errors.Is(err, context.DeadlineExceeded):
+			return err
+		}
+		a.log.Debug("avr: no ack", "cmd", cmd, "try", try+1)
+	}
+	return ErrNoAck
+}
+
+// ackMatch returns the match func for the amp's acknowledgement of
+// cmd.
+func ackMatch(cmd string) func(string) bool {
+	cmd = strings.TrimSuffix(cmd, "\r")
+	for _, suffix := range []string{"UP", "DOWN"} {
+		if p, ok := strings.CutSuffix(cmd, suffix); ok && p != "" {
+			return replyMatch(strings.TrimRight(p, " ") + "?")
+		}
+	}
+	return func(l string) bool { return l == cmd }
+}
@@ -0,0 +1,70 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import "errors"
+
+// allZoneStereoParams are the parameters used for All Zone Stereo.
+// Newer models use MNZST; older ones PSZST.
+var allZoneStereoParams = []string{"MNZST ", "PSZST "}
+
+// EnableAllZoneStereo plays the main zone's source in all zones.
+func (a *Amp) EnableAllZoneStereo() error {
+	return a.setAllZoneStereo(true)
+}
+
+// DisableAllZoneStereo leaves All Zone Stereo mode.
+func (a *Amp) DisableAllZoneStereo() error {
+	return a.setAllZoneStereo(false)
+}
+
+// AllZoneStereo reports whether All Zone Stereo mode is on.
+func (a *Amp) AllZoneStereo() (on bool, err error) {
+	err = a.tryAllZoneStereo(func(p string) error {
+		on, err = a.onOff(p)
+		return err
+	})
+	return on, err
+}
+
+func (a *Amp) setAllZoneStereo(on bool) error {
+	return a.tryAllZoneStereo(func(p string) error {
+		return a.setOnOff(p, on)
+	})
+}
+
+// tryAllZoneStereo calls f with the All Zone Stereo parameter this
+// amp is known to use, or else with each candidate until one gets an
+// answer.
+func (a *Amp) tryAllZoneStereo(f func(p string) error) error {
+	a.mu.Lock()
+	known := a.zstParam
+	a.mu.Unlock()
+	if known != "" {
+		return f(known)
+	}
+	var err error
+	for _, p := range allZoneStereoParams {
+		err = f(p)
+		if errors.Is(err, ErrTimeout) {
+			continue
+		}
+		a.mu.Lock()
+		a.zstParam = p
+		a.mu.Unlock()
+		return err
+	}
+	return err
+}
+
+// OnAllZoneStereo registers f to be called whenever the amp reports
+// entering or leaving All Zone Stereo mode. The returned func
+// unregisters f.
+func (a *Amp) OnAllZoneStereo(f func(on bool)) (remove func()) {
+	return a.listen(func(ev Event) {
+		if ev, ok := ev.(AllZoneStereoChanged); ok {
+			f(ev.On)
+		}
+	})
+}
@@ -0,0 +1,323 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"code.google.com/p/go-avr/avr/avrtest"
+)
+
+// A simDialer connects Amps to a Simulator, and lets tests hold back
+// dials and drop connections from the Amp's side.
+type simDialer struct {
+	addr string
+
+	mu    sync.Mutex
+	hold  chan struct{} // dials wait until it is closed, if non-nil
+	conns []net.Conn
+}
+
+func (d *simDialer) dial(ctx context.Context, network, _ string) (net.Conn, error) {
+	d.mu.Lock()
+	hold := d.hold
+	d.mu.Unlock()
+	if hold != nil {
+		select {
+		case <-hold:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	var nd net.Dialer
+	c, err := nd.DialContext(ctx, network, d.addr)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	d.conns = append(d.conns, c)
+	d.mu.Unlock()
+	return c, nil
+}
+
+// holdDials makes dials wait until the returned func is called.
+func (d *simDialer) holdDials() (release func()) {
+	hold := make(chan struct{})
+	d.mu.Lock()
+	d.hold = hold
+	d.mu.Unlock()
+	return func() {
+		d.mu.Lock()
+		d.hold = nil
+		d.mu.Unlock()
+		close(hold)
+	}
+}
+
+// drop closes the Amp's connections, as when the network fails.
+func (d *simDialer) drop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, c := range d.conns {
+		c.Close()
+	}
+	d.conns = nil
+}
+
+// newTestAmp returns an Amp connected to a new Simulator, without
+// capability detection, so that the amp receives only the commands
+// the test sends.
+func newTestAmp(t *testing.T, opts ...Option) (*Amp, *avrtest.Simulator, *simDialer) {
+	t.Helper()
+	sim, err := avrtest.NewSimulator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &simDialer{addr: sim.Addr()}
+	opts = append([]Option{
+		WithDialer(d.dial),
+		WithExclusiveConnection(),
+		WithCapabilities(Capabilities{}),
+		WithLogger(NopLogger),
+	}, opts...)
+	a := New("amp", opts...)
+	t.Cleanup(func() {
+		a.Close()
+		sim.Close()
+	})
+	if err := a.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	return a, sim, d
+}
+
+// disconnect makes sim end the Amp's session, once sim has accepted
+// the connection.
+func disconnect(t *testing.T, a *Amp, sim *avrtest.Simulator) {
+	t.Helper()
+	if _, err := a.Query(context.Background(), "PW?"); err != nil {
+		t.Fatal(err)
+	}
+	sim.Disconnect()
+}
+
+// waitFor returns the first event from events for which ok is true,
+// failing the test if none comes within 10 seconds.
+func waitFor(t *testing.T, events <-chan Event, ok func(Event) bool) Event {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case ev, open := <-events:
+			if !open {
+				t.Fatal("events closed")
+			}
+			if ok(ev) {
+				return ev
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for event")
+		}
+	}
+}
+
+// received returns the commands sim has received once it has
+// received n, failing the test if that takes over 10 seconds.
+func received(t *testing.T, sim *avrtest.Simulator, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		got := sim.Received()
+		if len(got) >= n {
+			return got
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("amp received %q, want %d commands", got, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// sendAll sends cmds through a Pipeline, so that they are queued
+// together, and waits for them to be written.
+func sendAll(t *testing.T, a *Amp, cmds ...string) {
+	t.Helper()
+	p := a.Pipeline(len(cmds))
+	for _, cmd := range cmds {
+		if err := p.Send(context.Background(), cmd); err != nil {
+			t.Fatalf("Send(%q): %v", cmd, err)
+		}
+	}
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPacing(t *testing.T) {
+	const gap = 100 * time.Millisecond
+	a, sim, _ := newTestAmp(t, WithCommandGap(gap))
+	start := time.Now()
+	sendAll(t, a, "MUON", "MSSTEREO", "SICD")
+	if d := time.Since(start); d < 2*gap {
+		t.Errorf("3 commands sent in %v, want at least %v", d, 2*gap)
+	}
+	want := []string{"MUON", "MSSTEREO", "SICD"}
+	if got := received(t, sim, len(want)); !slices.Equal(got, want) {
+		t.Errorf("amp received %q, want %q", got, want)
+	}
+}
+
+func TestVolumeCoalescing(t *testing.T) {
+	a, sim, _ := newTestAmp(t, WithCommandGap(100*time.Millisecond))
+	// MUON goes out at once; the volumes queue behind it, each
+	// replacing the one before.
+	sendAll(t, a, "MUON", "MV50", "MV55", "Z230", "MV60", "Z235")
+	want := []string{"MUON", "MV60", "Z235"}
+	if got := received(t, sim, len(want)); !slices.Equal(got, want) {
+		t.Errorf("amp received %q, want %q", got, want)
+	}
+}
+
+func TestSupersededCommandFails(t *testing.T) {
+	a, _, _ := newTestAmp(t, WithCommandGap(100*time.Millisecond))
+	if err := a.SendCommand("MUON"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := a.SendBatch(context.Background(), []Command{
+		{Cmd: "MV50", NoAck: true},
+		{Cmd: "MV60", NoAck: true},
+	})
+	if err == nil || !errors.Is(res[0].Err, ErrSuperseded) {
+		t.Errorf("superseded command: got %v, want ErrSuperseded", res[0].Err)
+	}
+	if res[1].Err != nil {
+		t.Errorf("superseding command: %v", res[1].Err)
+	}
+}
+
+func TestPowerJumpsQueue(t *testing.T) {
+	a, sim, _ := newTestAmp(t, WithCommandGap(100*time.Millisecond))
+	sendAll(t, a, "MUON", "MSSTEREO", "SICD", "PWSTANDBY")
+	want := []string{"MUON", "PWSTANDBY", "MSSTEREO", "SICD"}
+	if got := received(t, sim, len(want)); !slices.Equal(got, want) {
+		t.Errorf("amp received %q, want %q", got, want)
+	}
+}
+
+func TestQueryCorrelation(t *testing.T) {
+	a, sim, _ := newTestAmp(t, WithCommandGap(0))
+	// The amp answers with unrelated reports first, and MVMAX, which
+	// shares the volume query's prefix.
+	sim.Respond(func(cmd string) ([]string, bool) {
+		switch cmd {
+		case "MV?":
+			return []string{"MSSTEREO", "MVMAX 98", "Z2ON", "MV45"}, true
+		case "SI?":
+			return []string{"MUOFF", "PWON", "SIDVD"}, true
+		}
+		return nil, false
+	})
+	var wg sync.WaitGroup
+	for q, want := range map[string]string{"MV?": "MV45", "SI?": "SIDVD"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := a.Query(context.Background(), q)
+			if err != nil || got != want {
+				t.Errorf("Query(%q) = %q, %v; want %q", q, got, err, want)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestReconnectResync(t *testing.T) {
+	a, sim, d := newTestAmp(t)
+	// Answer the zone 3, signal and headphone queries, which Status
+	// would otherwise wait out as the simulator ignores them.
+	sim.Respond(func(cmd string) ([]string, bool) {
+		switch {
+		case cmd == "Z3?":
+			return []string{"Z3OFF", "Z340", "Z3CD"}, true
+		case cmd == "Z3MU?":
+			return []string{"Z3MUOFF"}, true
+		case cmd == "SSHPD ?":
+			return []string{"SSHPD OFF"}, true
+		case strings.HasPrefix(cmd, "SSINF"):
+			return []string{strings.TrimSuffix(cmd, "?") + "UNKNOWN"}, true
+		}
+		return nil, false
+	})
+	events, cancel := a.Subscribe()
+	defer cancel()
+
+	// The amp changes while the connection is down.
+	release := d.holdDials()
+	d.drop()
+	sim.Set("MV50")
+	release()
+
+	ev := waitFor(t, events, func(ev Event) bool {
+		_, ok := ev.(Resynced)
+		return ok
+	})
+	if got := ev.(Resynced).Status.Volume; got != -30 {
+		t.Errorf("resynced volume = %v, want -30", got)
+	}
+	if got := a.CachedState().Status.Volume; got != -30 {
+		t.Errorf("cached volume = %v, want -30", got)
+	}
+}
+
+func TestTakeover(t *testing.T) {
+	a, sim, _ := newTestAmp(t)
+	events, cancel := a.Subscribe()
+	defer cancel()
+
+	disconnect(t, a, sim)
+	waitFor(t, events, func(ev Event) bool {
+		_, ok := ev.(SessionTakenOver)
+		return ok
+	})
+	// Without WithReclaim, the Amp leaves the other client be.
+	time.Sleep(100 * time.Millisecond)
+	if st, err := a.ConnState(); st != Disconnected || !errors.Is(err, ErrSessionTakenOver) {
+		t.Errorf("ConnState() = %v, %v; want Disconnected, ErrSessionTakenOver", st, err)
+	}
+}
+
+func TestTakeoverReclaim(t *testing.T) {
+	a, sim, _ := newTestAmp(t, WithReclaim(50*time.Millisecond, time.Second))
+	events, cancel := a.Subscribe()
+	defer cancel()
+	changes, cancelWatch := a.WatchConnState()
+	defer cancelWatch()
+
+	disconnect(t, a, sim)
+	ev := waitFor(t, events, func(ev Event) bool {
+		_, ok := ev.(SessionTakenOver)
+		return ok
+	})
+	if ev.(SessionTakenOver).ReclaimAt.IsZero() {
+		t.Error("SessionTakenOver.ReclaimAt is zero with WithReclaim")
+	}
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case c := <-changes:
+			if c.State == Connected {
+				return
+			}
+		case <-timeout:
+			t.Fatal("session not reclaimed")
+		}
+	}
+}
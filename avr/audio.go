@@ -0,0 +1,129 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Restorer is a Compressed Audio Restorer setting.
+type Restorer string
+
+const (
+	RestorerOff   Restorer = "OFF"
+	RestorerMode1 Restorer = "MODE1"
+	RestorerMode2 Restorer = "MODE2"
+	RestorerMode3 Restorer = "MODE3"
+)
+
+// SetRestorer sets the Compressed Audio Restorer mode.
+func (a *Amp) SetRestorer(r Restorer) error {
+	switch r {
+	case RestorerOff, RestorerMode1, RestorerMode2, RestorerMode3:
+	default:
+		return fmt.Errorf("invalid restorer mode %q", r)
+	}
+	return a.setParam("PSRSTR ", string(r))
+}
+
+// GetRestorer returns the Compressed Audio Restorer mode.
+func (a *Amp) GetRestorer() (Restorer, error) {
+	v, err := a.param("PSRSTR ")
+	return Restorer(v), err
+}
+
+// MaxAudioDelay is the longest audio delay the amp accepts, in
+// milliseconds.
+const MaxAudioDelay = 200
+
+// SetAudioDelay delays audio relative to video by ms milliseconds,
+// from 0 to MaxAudioDelay.
+func (a *Amp) SetAudioDelay(ms int) error {
+	if ms < 0 || ms > MaxAudioDelay {
+		return fmt.Errorf("audio delay %dms out of range [0, %d]", ms, MaxAudioDelay)
+	}
+	return a.setParam("PSDELAY ", fmt.Sprintf("%03d", ms))
+}
+
+// AudioDelay returns the audio delay in milliseconds.
+func (a *Amp) AudioDelay() (ms int, err error) {
+	v, err := a.param("PSDELAY ")
+	if err != nil {
+		return 0, err
+	}
+	ms, err = strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid audio delay %q", v)
+	}
+	return ms, nil
+}
+
+// DialogEnhancer is a Dialog Enhancer level, which raises dialog
+// relative to other sounds.
+type DialogEnhancer string
+
+const (
+	DialogEnhancerOff    DialogEnhancer = "OFF"
+	DialogEnhancerLow    DialogEnhancer = "LOW"
+	DialogEnhancerMedium DialogEnhancer = "MED"
+	DialogEnhancerHigh   DialogEnhancer = "HIGH"
+)
+
+// SetDialogEnhancer sets the Dialog Enhancer level.
+func (a *Amp) SetDialogEnhancer(d DialogEnhancer) error {
+	switch d {
+	case DialogEnhancerOff, DialogEnhancerLow, DialogEnhancerMedium, DialogEnhancerHigh:
+	default:
+		return fmt.Errorf("invalid dialog enhancer level %q", d)
+	}
+	return a.setParam("PSDEH ", string(d))
+}
+
+// GetDialogEnhancer returns the Dialog Enhancer level.
+func (a *Amp) GetDialogEnhancer() (DialogEnhancer, error) {
+	v, err := a.param("PSDEH ")
+	return DialogEnhancer(v), err
+}
+
+// SetCenterSpread turns Center Spread on or off. It spreads the
+// center channel to the front speakers in Dolby Surround upmixing.
+func (a *Amp) SetCenterSpread(on bool) error {
+	return a.setOnOff("PSCES ", on)
+}
+
+// CenterSpread reports whether Center Spread is on.
+func (a *Amp) CenterSpread() (bool, error) {
+	return a.onOff("PSCES ")
+}
+
+// SetLoudnessManagement turns Dolby Loudness Management on or off. It
+// evens out loudness between programs and is needed for Dynamic
+// Range Compression. SetLoudnessManagement returns ErrUnsupported if
+// the amp is known to lack it.
+func (a *Amp) SetLoudnessManagement(on bool) error {
+	if err := a.checkLoudnessManagement(); err != nil {
+		return err
+	}
+	return a.setOnOff("PSLOM ", on)
+}
+
+// LoudnessManagement reports whether Dolby Loudness Management is on.
+func (a *Amp) LoudnessManagement() (bool, error) {
+	if err := a.checkLoudnessManagement(); err != nil {
+		return false, err
+	}
+	return a.onOff("PSLOM ")
+}
+
+// checkLoudnessManagement returns ErrUnsupported if the amp is known
+// to lack Loudness Management.
+func (a *Amp) checkLoudnessManagement() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.caps.Known && !a.caps.LoudnessManagement {
+		return fmt.Errorf("%w: loudness management", ErrUnsupported)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// MultEQ is an Audyssey MultEQ room correction curve.
+type MultEQ string
+
+const (
+	MultEQAudyssey MultEQ = "AUDYSSEY"
+	MultEQBypassLR MultEQ = "BYP.LR"
+	MultEQFlat     MultEQ = "FLAT"
+	MultEQManual   MultEQ = "MANUAL"
+	MultEQOff      MultEQ = "OFF"
+)
+
+// DynamicVolume is an Audyssey Dynamic Volume setting.
+type DynamicVolume string
+
+const (
+	DynamicVolumeHeavy  DynamicVolume = "HEV"
+	DynamicVolumeMedium DynamicVolume = "MED"
+	DynamicVolumeLight  DynamicVolume = "LIT"
+	DynamicVolumeOff    DynamicVolume = "OFF"
+)
+
+// SetMultEQ selects the Audyssey MultEQ curve.
+func (a *Amp) SetMultEQ(eq MultEQ) error {
+	if eq == "" {
+		return fmt.Errorf("empty MultEQ setting")
+	}
+	return a.setParam("PSMULTEQ:", string(eq))
+}
+
+// GetMultEQ returns the selected Audyssey MultEQ curve.
+func (a *Amp) GetMultEQ() (MultEQ, error) {
+	v, err := a.param("PSMULTEQ:")
+	return MultEQ(v), err
+}
+
+// SetDynamicEQ turns Audyssey Dynamic EQ on or off.
+func (a *Amp) SetDynamicEQ(on bool) error {
+	return a.setOnOff("PSDYNEQ ", on)
+}
+
+// DynamicEQ reports whether Audyssey Dynamic EQ is on.
+func (a *Amp) DynamicEQ() (bool, error) {
+	return a.onOff("PSDYNEQ ")
+}
+
+// SetDynamicVolume sets the Audyssey Dynamic Volume level.
+func (a *Amp) SetDynamicVolume(v DynamicVolume) error {
+	if v == "" {
+		return fmt.Errorf("empty Dynamic Volume setting")
+	}
+	return a.setParam("PSDYNVOL ", string(v))
+}
+
+// GetDynamicVolume returns the Audyssey Dynamic Volume level.
+func (a *Amp) GetDynamicVolume() (DynamicVolume, error) {
+	v, err := a.param("PSDYNVOL ")
+	return DynamicVolume(v), err
+}
+
+// SetReferenceLevel sets the Dynamic EQ reference level offset, in
+// dB: 0, 5, 10 or 15.
+func (a *Amp) SetReferenceLevel(db int) error {
+	switch db {
+	case 0, 5, 10, 15:
+	default:
+		return fmt.Errorf("reference level offset %ddB not one of 0, 5, 10, 15", db)
+	}
+	return a.setParam("PSREFLEV ", strconv.Itoa(db))
+}
+
+// ReferenceLevel returns the Dynamic EQ reference level offset in dB.
+func (a *Amp) ReferenceLevel() (db int, err error) {
+	v, err := a.param("PSREFLEV ")
+	if err != nil {
+		return 0, err
+	}
+	db, err = strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid reference level %q", v)
+	}
+	return db, nil
+}
+
+// Low Frequency Containment amounts, from least to most bass
+// containment.
+const (
+	MinContainment = 1
+	MaxContainment = 7
+)
+
+// SetLFC turns Audyssey Low Frequency Containment on or off. It
+// limits bass that carries to neighboring rooms. SetLFC returns
+// ErrUnsupported if the amp is known to lack it.
+func (a *Amp) SetLFC(on bool) error {
+	if err := a.checkLFC(); err != nil {
+		return err
+	}
+	return a.setOnOff("PSLFC ", on)
+}
+
+// LFC reports whether Audyssey Low Frequency Containment is on.
+func (a *Amp) LFC() (bool, error) {
+	if err := a.checkLFC(); err != nil {
+		return false, err
+	}
+	return a.onOff("PSLFC ")
+}
+
+// SetContainmentAmount sets how strongly Low Frequency Containment
+// limits bass, from MinContainment to MaxContainment.
+func (a *Amp) SetContainmentAmount(n int) error {
+	if n < MinContainment || n > MaxContainment {
+		return fmt.Errorf("containment amount %d out of range [%d, %d]", n, MinContainment, MaxContainment)
+	}
+	if err := a.checkLFC(); err != nil {
+		return err
+	}
+	return a.setParam("PSCNTAMT ", fmt.Sprintf("%02d", n))
+}
+
+// ContainmentAmount returns the Low Frequency Containment amount.
+func (a *Amp) ContainmentAmount() (int, error) {
+	if err := a.checkLFC(); err != nil {
+		return 0, err
+	}
+	v, err := a.param("PSCNTAMT ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid containment amount %q", v)
+	}
+	return n, nil
+}
+
+// checkLFC returns ErrUnsupported if the amp is known to lack Low
+// Frequency Containment.
+func (a *Amp) checkLFC() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.caps.Known && !a.caps.LFC {
+		return fmt.Errorf("%w: low frequency containment", ErrUnsupported)
+	}
+	return nil
+}
@@ -12,24 +12,53 @@ package avr
 
 import (
 	"bufio"
-	"errors"
-	"log"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
 	"net"
+	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.google.com/p/go-avr/avr/heos"
+	"code.google.com/p/go-avr/avr/internal/pace"
+	"code.google.com/p/go-avr/avr/proto"
 )
 
 // New returns a new Amp. The amp is safe for use by use by
 // concurrent multiple goroutines. Broken TCP connections are
 // retried as needed. When finished, call Close.
-func New(addr string) *Amp {
+//
+// The address is a host name or IP address, optionally with a port,
+// such as "avr.local", "192.168.1.20:23", "fe80::1%eth0" or
+// "[2001:db8::20]:23"; the port defaults to 23. Host names are
+// resolved again on each reconnect, so that the Amp follows the amp
+// to a new address.
+func New(addr string, opts ...Option) *Amp {
 	a := &Amp{
-		addr:     addr,
-		reqc:     make(chan request),
-		ampc:     make(chan *ampLine),
-		connerrc: make(chan error),
+		addr:       withPort(addr, controlPort),
+		reqc:       make(chan request),
+		ampc:       make(chan ampLine),
+		connerrc:   make(chan connErr),
+		done:       make(chan struct{}),
+		dialer:     net.Dialer{Timeout: DefaultDialTimeout},
+		cmdTimeout: DefaultCommandTimeout,
+		log:        slog.Default(),
+		metrics:    nopMetrics{},
+		gaps:       pace.Gaps{Min: DefaultCommandGap, Prefix: defaultCommandGaps()},
+		latencies:  defaultLatencies(),
+		ackRetries: -1,
+		caps:       Capabilities{MaxVolume: MaxVolume},
+		volLimit:   MaxVolume,
+	}
+	for _, opt := range opts {
+		opt(a)
 	}
 	a.startConnect()
+	a.wg.Add(1)
 	go a.loop()
 	return a
 }
@@ -37,18 +66,68 @@ func New(addr string) *Amp {
 // Amp represents an AVR Receiver.
 type Amp struct {
 	// Immutable:
-	addr     string
-	reqc     chan request
-	ampc     chan *ampLine
-	connerrc chan error
+	addr         string
+	reqc         chan request
+	ampc         chan ampLine
+	connerrc     chan connErr
+	done         chan struct{}  // closed by Close
+	wg           sync.WaitGroup // the Amp's goroutines
+	dialer       net.Dialer
+	dial         func(ctx context.Context, network, addr string) (net.Conn, error)
+	transport    Transport // overrides dialer and dial if non-nil
+	cmdTimeout   time.Duration
+	log          Logger
+	metrics      Metrics
+	wire         *wireLog                 // nil unless WithWireLog
+	historyLog   *historyLog              // nil unless WithHistoryLog
+	gaps         pace.Gaps                // between consecutive commands
+	latencies    map[string]time.Duration // busy after commands with these prefixes
+	ackRetries   int                      // -1 to not wait for acks
+	ackTimeout   time.Duration
+	hbInterval   time.Duration // heartbeat when idle this long; 0 for none
+	hbMaxSilence time.Duration // reconnect when silent this long
+	volLimit     float64       // highest volume the library sets, in dB
+	reclaimMin   time.Duration // WithReclaim initial delay; 0 for none
+	reclaimMax   time.Duration
+	readOnly     bool         // reject commands other than queries
+	strict       bool         // validate raw commands before sending
+	exclusive    bool         // dial its own TCP connection
+	webURL       string       // for WebStatus, without trailing slash
+	webClient    *http.Client // nil unless WithWebStatus
+
+	// Atomic:
+	queueDepth atomic.Int32               // requests waiting to be sent, set by loop
+	connSeq    atomic.Uint64              // number of the latest connection
+	busyUntil  atomic.Int64               // Unix nanoseconds; set by loop
+	lastCmd    atomic.Int64               // Unix nanoseconds of the last write
+	lastLine   atomic.Int64               // Unix nanoseconds of the last amp line
+	snap       atomic.Pointer[Snapshot]   // copy of cache; set with mu held
+	connSnap   atomic.Pointer[ConnChange] // state and err; set with mu held
 
 	// Guarded by mu:
 	mu             sync.Mutex
 	closed         bool
-	state          state
+	state          ConnState
 	stateListeners []chan error // nil for connected
 	conn           *conn
 	err            error
+	zstParam       string // All Zone Stereo parameter that worked, if known
+	cache          Status // kept current from every amp line
+	subscribers    map[*subscriber]bool
+	lineWatchers   map[chan string]bool
+	history        history          // of published events
+	mac            net.HardwareAddr // for Wake; nil if unknown
+	connWatchers   map[chan ConnChange]bool
+	heos           *heos.Client // nil until HEOS is called
+	caps           Capabilities
+	capsDetecting  bool // detection is under way or has succeeded
+	display        display
+	redial         time.Duration // before retrying a failed reconnect; 0 unless reconnecting
+	redialTimer    *time.Timer   // retries a failed reconnect, if any
+	reclaimTimer   *time.Timer   // reclaims a session taken over, if any
+	noSignalInfo   bool          // the amp ignored the signal queries while on
+	noHeadphones   bool          // the amp ignored the headphone query while on
+	updating       bool          // the amp reported a firmware update under way
 }
 
 // Addr returns the address of the amp.
@@ -56,54 +135,248 @@ func (a *Amp) Addr() string {
 	return a.addr
 }
 
+// Close closes the connection to the amp. Requests in progress fail
+// with ErrClosed, and subscription channels are closed. Close waits
+// for the Amp's goroutines to finish.
 func (a *Amp) Close() error {
+	return a.Shutdown(context.Background())
+}
+
+// Shutdown is like Close but stops waiting for the Amp's goroutines
+// to finish once ctx is done, returning ctx's error.
+func (a *Amp) Shutdown(ctx context.Context) error {
 	a.mu.Lock()
-	defer a.mu.Unlock()
-	if a.closed {
-		return nil
+	if !a.closed {
+		a.closed = true
+		a.setConnState(Disconnected, ErrClosed)
+		if a.redialTimer != nil {
+			a.redialTimer.Stop()
+		}
+		if a.reclaimTimer != nil {
+			a.reclaimTimer.Stop()
+		}
+		close(a.done)
+		for _, ch := range a.stateListeners {
+			ch <- ErrClosed
+		}
+		a.stateListeners = nil
+		if a.conn != nil {
+			a.conn.close()
+		}
+		for s := range a.subscribers {
+			s.close()
+		}
+		a.subscribers = nil
+		for ch := range a.lineWatchers {
+			close(ch)
+		}
+		a.lineWatchers = nil
+		for ch := range a.connWatchers {
+			close(ch)
+		}
+		a.connWatchers = nil
+		if a.heos != nil {
+			a.heos.Close()
+		}
 	}
-	a.closed = true
-	close(a.reqc)
-	if a.conn != nil {
-		a.conn.c.Close()
+	a.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	return nil
 }
 
+// Ping connects to the amp if needed and reports whether the
+// connection succeeded. Use Healthcheck to check that the amp also
+// answers.
 func (a *Amp) Ping() error {
-	a.startConnect() // no-op if already connected/connecting
-	ch := make(chan *response)
-	a.reqc <- request{ch: ch, cmd: pingCmd}
-	res := <-ch
-	return res.err
+	return a.PingContext(context.Background())
+}
+
+// PingContext is like Ping but gives up once ctx is done.
+func (a *Amp) PingContext(ctx context.Context) error {
+	_, err := a.do(ctx, request{cmd: pingCmd})
+	return err
 }
 
+// SendCommand sends cmd, such as "PWON", to the amp without waiting
+// for a reply, unless WithAck is in effect. The trailing carriage
+// return is optional.
 func (a *Amp) SendCommand(cmd string) error {
-	a.startConnect() // no-op if already connected/connecting
-	ch := make(chan *response)
-	a.reqc <- request{ch: ch, cmd: rawCmd, raw: cmd}
-	res := <-ch
-	return res.err
+	return a.SendCommandContext(context.Background(), cmd)
+}
+
+// SendCommandContext is like SendCommand but gives up once ctx is
+// done.
+func (a *Amp) SendCommandContext(ctx context.Context, cmd string) error {
+	if err := a.checkStrict(cmd); err != nil {
+		return err
+	}
+	if err := a.checkRawVolume(cmd); err != nil {
+		return err
+	}
+	if a.ackRetries >= 0 {
+		return a.sendAck(ctx, cmd)
+	}
+	_, err := a.do(ctx, request{cmd: rawCmd, raw: cmd})
+	return err
+}
+
+// do hands req to the loop goroutine and waits for its response,
+// giving up once ctx is done.
+func (a *Amp) do(ctx context.Context, req request) (*response, error) {
+	if req.ch == nil {
+		req.ch = make(chan *response, 1)
+	}
+	if err := a.submit(ctx, &req); err != nil {
+		return nil, err
+	}
+	select {
+	case res := <-req.ch:
+		return res, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// submit hands req to the loop goroutine without waiting for its
+// response, which will be sent on req.ch.
+func (a *Amp) submit(ctx context.Context, req *request) error {
+	a.connectFor(ctx)
+	req.ctx = ctx
+	select {
+	case a.reqc <- *req:
+		return nil
+	case <-a.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// query sends cmd to the amp and returns the first line the amp
+// sends back for which match returns true.
+func (a *Amp) query(ctx context.Context, cmd string, match func(string) bool) (string, error) {
+	res, err := a.do(ctx, request{cmd: queryCmd, raw: cmd, match: match})
+	if err != nil {
+		return "", err
+	}
+	return res.line, nil
+}
+
+// queryLines sends cmd to the amp and returns every line accepted by
+// match, stopping once no matching line has arrived for quiet or,
+// if end is non-nil, after a line for which end returns true.
+func (a *Amp) queryLines(ctx context.Context, cmd string, match, end func(string) bool, quiet time.Duration) ([]string, error) {
+	a.connectFor(ctx)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // drops the waiter
+	ch := make(chan *response, 64)
+	select {
+	case a.reqc <- request{ch: ch, cmd: queryCmd, raw: cmd, match: match, ctx: ctx, multi: true}:
+	case <-a.done:
+		return nil, ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	var lines []string
+	t := time.NewTimer(a.cmdTimeout) // for the first line
+	defer t.Stop()
+	for {
+		select {
+		case res := <-ch:
+			if res.err != nil {
+				return lines, res.err
+			}
+			lines = append(lines, res.line)
+			if end != nil && end(res.line) {
+				return lines, nil
+			}
+			t.Reset(quiet)
+		case <-t.C:
+			if len(lines) == 0 {
+				return nil, fmt.Errorf("%w: no reply to %q", ErrTimeout, cmd)
+			}
+			return lines, nil
+		case <-ctx.Done():
+			return lines, ctx.Err()
+		}
+	}
+}
+
+// timeoutQuery is like query but waits at most the command timeout.
+func (a *Amp) timeoutQuery(cmd string, match func(string) bool) (string, error) {
+	return a.queryContext(context.Background(), cmd, match)
+}
+
+// queryContext is like query but, unless ctx has a deadline, waits
+// at most the command timeout.
+func (a *Amp) queryContext(ctx context.Context, cmd string, match func(string) bool) (string, error) {
+	ctx, cancel := a.timeoutContext(ctx)
+	defer cancel()
+	l, err := a.query(ctx, cmd, match)
+	return l, timeoutErr(err)
+}
+
+// timeoutContext returns ctx bounded by the command timeout, plus
+// the rest of any time the amp is Busy, unless it already has a
+// deadline.
+func (a *Amp) timeoutContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return a.commandContext(ctx, "")
+}
+
+// prefix returns a query match func for lines beginning with p.
+func prefix(p string) func(string) bool {
+	return func(l string) bool { return strings.HasPrefix(l, p) }
+}
+
+// Reconnecting after the connection drops retries a failed dial after
+// minRedial, then twice as long each time, up to maxRedial, so that
+// the Amp finds the amp again once it is back, as after a reboot.
+const (
+	minRedial = time.Second
+	maxRedial = time.Minute
+)
+
+// reconnect starts connecting again after the connection dropped,
+// retrying until a dial succeeds or the Amp is closed.
+func (a *Amp) reconnect() {
+	a.mu.Lock()
+	if a.redial == 0 {
+		a.redial = minRedial
+	}
+	a.mu.Unlock()
+	a.startConnect()
 }
 
 func (a *Amp) startConnect() {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	if a.closed || a.state != unconnected {
+	if a.closed || a.state != Disconnected {
 		return
 	}
-	a.state = connecting
+	a.setConnState(Connecting, a.err)
+	a.notifyConnState(nil)
+	a.wg.Add(1)
 	go a.connect()
 }
 
 // must be called with mu held
 func (a *Amp) setState(err error) {
 	if err == nil {
-		a.state = connected
+		a.setConnState(Connected, nil)
 	} else {
-		a.state = unconnected
+		a.setConnState(Disconnected, err)
 	}
-	a.err = err
+	a.notifyConnState(err)
 	for _, ch := range a.stateListeners {
 		ch <- err
 	}
@@ -113,150 +386,516 @@ func (a *Amp) setState(err error) {
 func (a *Amp) addStateListener(ch chan error) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	if a.state == connecting {
+	if a.state == Connecting {
 		a.stateListeners = append(a.stateListeners, ch)
 	} else {
 		ch <- a.err
 	}
 }
 
+// dialAmp connects to the amp with the Transport or WithDialer func,
+// if any, or else over TCP, sharing the session of any other Amp for
+// the same address unless WithExclusiveConnection. It gives up after
+// the dial timeout or once the Amp is closed.
+func (a *Amp) dialAmp() (io.ReadWriteCloser, error) {
+	ctx, cancel := a.dialContext()
+	defer cancel()
+	if a.transport == nil && a.dial == nil {
+		if !a.exclusive {
+			return a.openShared(ctx)
+		}
+		return a.dialer.DialContext(ctx, "tcp", a.addr)
+	}
+	if a.dialer.Timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, a.dialer.Timeout)
+		defer cancelTimeout()
+	}
+	if a.transport != nil {
+		return a.transport.Open(ctx)
+	}
+	return a.dial(ctx, "tcp", a.addr)
+}
+
+// withPort returns addr as host:port, adding port if addr has none.
+// It accepts IPv6 literals with or without brackets.
+func withPort(addr, port string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	return net.JoinHostPort(host, port)
+}
+
+// backgroundKey marks the contexts of the Amp's own requests.
+type backgroundKey struct{}
+
+// backgroundContext is like dialContext, for the Amp's own requests
+// such as resyncing. Unlike callers' requests, they don't start a
+// connection, so that they don't take the session back from another
+// client.
+func (a *Amp) backgroundContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := a.dialContext()
+	return context.WithValue(ctx, backgroundKey{}, true), cancel
+}
+
+// connectFor starts connecting for a request with context ctx, unless
+// it is a background request or the Amp is already connected or
+// connecting.
+func (a *Amp) connectFor(ctx context.Context) {
+	if ctx.Value(backgroundKey{}) == nil {
+		a.startConnect()
+	}
+}
+
+// dialContext returns a context that is canceled when the Amp is
+// closed.
+func (a *Amp) dialContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-a.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
 func (a *Amp) connect() {
-	c, err := net.Dial("tcp", a.addr)
-	log.Printf("net.Dial: c=%v, err=%v", c, err)
+	defer a.wg.Done()
+	c, err := a.dialAmp()
+	a.metrics.DialDone(a, err)
+	if err != nil {
+		a.log.Debug("avr: dial failed", "addr", a.addr, "err", err)
+	} else if nc, ok := c.(addrConn); ok {
+		a.log.Debug("avr: connected", "addr", a.addr, "remote", nc.RemoteAddr(), "local", nc.LocalAddr())
+	} else {
+		a.log.Debug("avr: connected", "addr", a.addr)
+	}
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	a.setState(err)
+	if a.closed {
+		if c != nil {
+			c.Close()
+		}
+		a.setConnState(Disconnected, ErrClosed)
+		return
+	}
 	if err != nil {
+		a.setState(err)
+		if d := a.redial; d > 0 {
+			a.redial = min(2*d, maxRedial)
+			if a.redialTimer != nil {
+				a.redialTimer.Stop()
+			}
+			a.redialTimer = time.AfterFunc(d, a.startConnect)
+		}
 		return
 	}
+	a.redial = 0
+	// The amp may have restarted with other firmware: ask again.
+	a.noSignalInfo = false
+	a.noHeadphones = false
 
 	a.conn = &conn{
 		a:    a,
+		id:   a.connSeq.Add(1),
 		c:    c,
-		bufr: bufio.NewReader(c),
+		bufr: bufio.NewReaderSize(c, proto.MaxLineLen),
 		bufw: bufio.NewWriter(c),
+		made: time.Now(),
+		done: make(chan struct{}),
 	}
+	a.setState(nil)
+	a.wg.Add(1)
 	go a.conn.readFromAmp()
+	a.startDetect()
+	if a.conn.id > 1 {
+		a.wg.Add(1)
+		go a.resync()
+	}
+}
+
+// connID returns the id of the current connection, or 0 if the Amp
+// isn't connected.
+func (a *Amp) connID() uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.conn == nil {
+		return 0
+	}
+	return a.conn.id
+}
+
+// connAge returns how long the current connection has been up.
+func (a *Amp) connAge() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.conn == nil {
+		return 0
+	}
+	return time.Since(a.conn.made)
+}
+
+// disconnected records that the connection failed with err.
+//
+// run in loop goroutine
+func (a *Amp) disconnected(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.state != Connected {
+		return
+	}
+	a.conn.close()
+	a.conn = nil
+	a.setState(err)
 }
 
 func (a *Amp) loop() {
+	defer a.wg.Done()
+	pend := pending{done: a.queryDone}
+	pace := newPacer(a.gaps)
+	var rc reclaim
+	var hb heartbeat
+	var hbC <-chan time.Time
+	if t := a.newHeartbeatTicker(); t != nil {
+		defer t.Stop()
+		hbC = t.C
+	}
 	for {
 		select {
-		case req, ok := <-a.reqc:
-			if !ok {
-				return
+		case <-a.done:
+			pend.fail(ErrClosed)
+			pace.fail(ErrClosed)
+			return
+		case req := <-a.reqc:
+			if req.cmd == pingCmd {
+				a.handlePing(req)
+				break
 			}
-			a.handleRequest(req)
+			pace.push(req)
+		case <-pace.C():
+			pace.fired()
+		case <-hbC:
+			a.checkHeartbeat(&hb, pace)
 		case ampl := <-a.ampc:
-			log.Printf("amp says: %q", ampl.l)
-		case <-a.connerrc:
-			a.startConnect()
+			if ampl.conn != a.connID() {
+				break // read before its connection was closed
+			}
+			hb.lastRx = time.Now()
+			a.lastLine.Store(hb.lastRx.UnixNano())
+			if !pend.dispatch(ampl.l) {
+				a.log.Debug("avr: amp says", "line", ampl.l)
+			}
+			a.updateCache(ampl)
+		case ce := <-a.connerrc:
+			if ce.conn != a.connID() {
+				break
+			}
+			err := ce.err
+			age := a.connAge()
+			if !isTakeover(err, age, ce.writeFailed) || a.updateUnderWay() {
+				pend.fail(err)
+				a.disconnected(err)
+				a.reconnect()
+				break
+			}
+			err = takeoverErr(err)
+			pend.fail(err)
+			a.disconnected(err)
+			a.takenOver(&rc, age, err)
+		}
+		for {
+			pace.hold(time.Unix(0, a.busyUntil.Load()))
+			req, ok := pace.pop(time.Now())
+			if !ok {
+				break
+			}
+			a.handleRequest(req, &pend)
 		}
+		a.queueDepth.Store(int32(pace.Len()))
 	}
 }
 
 // run in loop goroutine
-func (a *Amp) handleRequest(req request) {
+func (a *Amp) handleRequest(req request, pend *pending) {
 	switch req.cmd {
-	case pingCmd:
-		a.handlePing(req)
 	case rawCmd:
 		a.handleRaw(req)
+	case queryCmd:
+		req.sent = time.Now()
+		if a.handleQuery(req) {
+			pend.add(req)
+		}
 	default:
-		log.Printf("unhandled command request: %#v", req)
+		a.log.Error("avr: unhandled command request", "cmd", req.cmd)
+		req.ch <- &response{err: fmt.Errorf("%w: command request %d", ErrUnsupported, req.cmd)}
 	}
 }
 
+// handlePing answers req once the amp is connected, or has failed
+// to connect, without blocking the loop.
+//
 // run in loop goroutine
 func (a *Amp) handlePing(req request) {
 	a.mu.Lock()
 	st := a.state
 	a.mu.Unlock()
 
-	if st == connected {
+	if st == Connected {
 		req.ch <- &response{err: nil}
+		return
 	}
 
 	a.startConnect()
-	ch := make(chan error)
+	ch := make(chan error, 1)
 	a.addStateListener(ch)
 
-	req.ch <- &response{err: <-ch}
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		var err error
+		select {
+		case err = <-ch:
+		case <-a.done:
+			err = ErrClosed
+		case <-req.ctx.Done():
+			return
+		}
+		select {
+		case req.ch <- &response{err: err}:
+		default:
+			// Already answered.
+		}
+	}()
 }
 
 // run in loop goroutine
 func (a *Amp) handleRaw(req request) {
+	req.ch <- &response{err: a.write(req.raw)}
+}
+
+// handleQuery writes the query's command. It reports whether the
+// request should wait for a reply; if not, req has been answered.
+//
+// run in loop goroutine
+func (a *Amp) handleQuery(req request) bool {
+	if err := a.write(req.raw); err != nil {
+		req.ch <- &response{err: err}
+		return false
+	}
+	if req.written != nil {
+		close(req.written)
+	}
+	return true
+}
+
+// queryDone reports an answered or failed query to the Metrics.
+//
+// run in loop goroutine
+func (a *Amp) queryDone(req request, err error) {
+	if !req.multi {
+		a.metrics.QueryDone(a, strings.TrimSuffix(req.raw, "\r"), time.Since(req.sent), err)
+	}
+}
+
+// write sends raw to the amp, adding the trailing carriage return
+// if needed.
+//
+// run in loop goroutine
+func (a *Amp) write(raw string) error {
+	if err := a.checkReadOnly(raw); err != nil {
+		return err
+	}
 	a.mu.Lock()
 	st := a.state
 	conn := a.conn
 	a.mu.Unlock()
 
-	if st != connected {
-		req.ch <- &response{err: errors.New("not connected")}
-		return
+	if st != Connected {
+		if err := a.connErr(); err != nil {
+			return fmt.Errorf("%w: %w", ErrNotConnected, err)
+		}
+		return ErrNotConnected
 	}
 
-	raw := req.raw
 	if !strings.HasSuffix(raw, "\r") {
 		raw += "\r"
 	}
 	conn.bufw.WriteString(raw)
-	conn.bufw.Flush()
-	req.ch <- &response{err: nil}
+	if err := conn.bufw.Flush(); err != nil {
+		conn.writeFailed.Store(true)
+		return err
+	}
+	cmd := strings.TrimSuffix(raw, "\r")
+	a.wire.log(conn.id, "tx", cmd)
+	a.metrics.CommandSent(a, cmd)
+	a.noteSent(cmd)
+	a.lastCmd.Store(time.Now().UnixNano())
+	return nil
 }
 
-// conn is a single TCP connection to an AVR. If it fails, the
-// amp makes a new one.
+// conn is a single connection to an AVR. If it fails, the amp
+// makes a new one. Its reader stops once it is closed, and the loop
+// ignores what a reader sent for a connection other than the latest,
+// so that a connection never outlives its replacement.
 type conn struct {
 	// All immutable:
 	a    *Amp
-	c    net.Conn
+	id   uint64 // for the wire log
+	c    io.ReadWriteCloser
 	bufr *bufio.Reader
 	bufw *bufio.Writer
+	made time.Time     // when connected
+	done chan struct{} // closed by close
+
+	lines lineTable // used only by readFromAmp
+
+	closeOnce   sync.Once
+	killErr     atomic.Pointer[error] // set by kill
+	writeFailed atomic.Bool           // a write to c has failed
 }
 
-type state int
+// close closes the connection and stops its reader, which then
+// reports nothing more to the loop.
+func (c *conn) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.c.Close()
+	})
+}
 
-const (
-	unconnected state = iota
-	connecting
-	connected
-)
+// kill closes the connection, making its reader report err.
+func (c *conn) kill(err error) {
+	c.killErr.Store(&err)
+	if sc, ok := c.c.(*sharedConn); ok {
+		sc.abort(err)
+		return
+	}
+	c.c.Close()
+}
 
 type command int
 
 const (
 	pingCmd command = iota
 	rawCmd
+	queryCmd
 )
 
 type request struct {
 	ch  chan *response
 	cmd command
+	ctx context.Context // the caller gives up once ctx is done
 
-	// If rawCmd
+	// If rawCmd or queryCmd
 	raw string
+
+	// If queryCmd
+	match   func(string) bool // reports whether an amp line answers the query
+	multi   bool              // answer with every matching line until ctx is done
+	sent    time.Time         // when the query was written; set by handleRequest
+	written chan struct{}     // if non-nil, closed once the query is written
 }
 
 type response struct {
-	err error // for ping
+	err  error  // for ping
+	line string // for queryCmd
 }
 
 func (c *conn) readFromAmp() {
+	defer c.a.wg.Done()
 	for {
 		bs, err := c.bufr.ReadSlice('\r')
+		if err == bufio.ErrBufferFull {
+			// Amps send garbage without line breaks during firmware
+			// updates. Drop it rather than the connection.
+			c.a.log.Debug("avr: dropping overlong line", "prefix", string(bs[:16]))
+			err = c.skipLine()
+			if err == nil {
+				continue
+			}
+		}
 		if err != nil {
-			c.a.connerrc <- err
+			if p := c.killErr.Load(); p != nil {
+				err = *p
+			}
+			select {
+			case c.a.connerrc <- connErr{c.id, err, c.writeFailed.Load()}:
+			case <-c.done:
+			case <-c.a.done:
+			}
+			return
+		}
+		ampl := c.lines.line(c.id, bs[:len(bs)-1])
+		c.a.wire.log(c.id, "rx", ampl.l)
+		select {
+		case c.a.ampc <- ampl:
+		case <-c.done:
+			return
+		case <-c.a.done:
 			return
 		}
-		c.a.ampc <- newAmpLine(string(bs))
 	}
 }
 
+// skipLine discards input through the next carriage return.
+func (c *conn) skipLine() error {
+	for {
+		_, err := c.bufr.ReadSlice('\r')
+		if err != bufio.ErrBufferFull {
+			return err
+		}
+	}
+}
+
+// An ampLine is a line from the amp, without its carriage return,
+// and what it parses to. It is passed by value, and lines the amp
+// repeats come from the connection's lineTable, so that most lines
+// are read without allocating.
 type ampLine struct {
-	l string
+	conn uint64 // id of the connection it was read from
+	l    string
+	m    proto.Message // l parsed
+	ev   Event         // m's Event, or nil if it has none
+}
+
+// A lineTable interns the lines a connection reads, with what they
+// parse to, so that the lines an amp repeats, such as its volume and
+// power reports, are converted to strings and parsed once rather than
+// every time they are read.
+type lineTable map[string]ampLine
+
+// Lines longer than maxInternLen, such as display text, are rarely
+// repeated and aren't interned. A lineTable holding maxInterned lines
+// is emptied before adding another.
+const (
+	maxInternLen = 32
+	maxInterned  = 256
+)
+
+// line returns the ampLine for bs, a line read from connection id.
+// It allocates only for lines not in t.
+func (t *lineTable) line(id uint64, bs []byte) ampLine {
+	if l, ok := (*t)[string(bs)]; ok { // the lookup doesn't copy bs
+		return l
+	}
+	l := ampLine{conn: id, l: string(bs)}
+	l.m = proto.Parse(l.l)
+	l.ev = eventOf(l.m)
+	if len(bs) <= maxInternLen {
+		if *t == nil || len(*t) >= maxInterned {
+			*t = make(lineTable)
+		}
+		(*t)[l.l] = l
+	}
+	return l
 }
 
-func newAmpLine(s string) *ampLine {
-	return &ampLine{l: s}
+// A connErr reports why a connection's reader stopped.
+type connErr struct {
+	conn        uint64 // id of the connection
+	err         error
+	writeFailed bool // a write to the connection failed first
 }
@@ -0,0 +1,185 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+// Package avrgrpc serves an avr.Amp as the gRPC AvrService defined in
+// avr.proto, for clients in languages other than Go.
+package avrgrpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative avr.proto
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"code.google.com/p/go-avr/avr"
+)
+
+// Server implements AvrServiceServer by calling an Amp.
+type Server struct {
+	UnimplementedAvrServiceServer
+	amp *avr.Amp
+}
+
+// NewServer returns a server controlling amp. Register it with
+// RegisterAvrServiceServer.
+func NewServer(amp *avr.Amp) *Server {
+	return &Server{amp: amp}
+}
+
+// GetStatus implements AvrServiceServer.
+func (s *Server) GetStatus(ctx context.Context, _ *GetStatusRequest) (*Status, error) {
+	st, err := s.amp.Status(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &Status{
+		Power:        st.Power,
+		VolumeDb:     st.Volume,
+		Input:        string(st.Input),
+		SurroundMode: string(st.SurroundMode),
+		Muted:        st.Muted,
+		Zone2:        zoneStatus(st.Zone2),
+		Zone3:        zoneStatus(st.Zone3),
+		Signal:       signalInfo(st.Signal),
+		Headphones:   st.Headphones,
+	}, nil
+}
+
+// SetVolume implements AvrServiceServer.
+func (s *Server) SetVolume(ctx context.Context, req *SetVolumeRequest) (*SetVolumeResponse, error) {
+	if err := s.amp.SetVolume(req.GetVolumeDb()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &SetVolumeResponse{}, nil
+}
+
+// SelectInput implements AvrServiceServer.
+func (s *Server) SelectInput(ctx context.Context, req *SelectInputRequest) (*SelectInputResponse, error) {
+	if req.GetInput() == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing input")
+	}
+	if err := s.amp.SelectInput(avr.InputSource(req.GetInput())); err != nil {
+		return nil, toStatus(err)
+	}
+	return &SelectInputResponse{}, nil
+}
+
+// StreamEvents implements AvrServiceServer. It returns when the
+// client cancels or the Amp is closed.
+func (s *Server) StreamEvents(_ *StreamEventsRequest, stream grpc.ServerStreamingServer[Event]) error {
+	events, cancel := s.amp.Subscribe()
+	defer cancel()
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return status.Error(codes.Unavailable, "amp closed")
+			}
+			pe := event(ev)
+			if pe == nil {
+				continue
+			}
+			if err := stream.Send(pe); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+// event converts ev, returning nil for events AvrService doesn't
+// carry.
+func event(ev avr.Event) *Event {
+	var e Event
+	if ze, ok := ev.(avr.ZoneEvent); ok {
+		e.Zone = int32(ze.Zone)
+		ev = ze.Event
+	}
+	switch ev := ev.(type) {
+	case avr.VolumeChanged:
+		e.Event = &Event_VolumeDb{ev.Volume}
+	case avr.PowerChanged:
+		e.Event = &Event_Power{ev.On}
+	case avr.InputChanged:
+		e.Event = &Event_Input{string(ev.Input)}
+	case avr.MuteChanged:
+		e.Event = &Event_Muted{ev.Muted}
+	case avr.SurroundModeChanged:
+		e.Event = &Event_SurroundMode{string(ev.Mode)}
+	case avr.NowPlayingChanged:
+		np := ev.NowPlaying
+		elapsed := np.Elapsed.Milliseconds()
+		if np.Elapsed < 0 {
+			elapsed = -1
+		}
+		e.Event = &Event_NowPlaying{&NowPlaying{
+			Source:    np.Source,
+			Track:     np.Track,
+			Artist:    np.Artist,
+			Album:     np.Album,
+			Station:   np.Station,
+			ElapsedMs: elapsed,
+		}}
+	case avr.SignalChanged:
+		e.Event = &Event_Signal{signalInfo(&ev.Signal)}
+	case avr.RawLine:
+		e.Event = &Event_RawLine{ev.Line}
+	case avr.HeadphonesChanged:
+		e.Event = &Event_Headphones{ev.Connected}
+	default:
+		return nil
+	}
+	return &e
+}
+
+func zoneStatus(z *avr.ZoneStatus) *ZoneStatus {
+	if z == nil {
+		return nil
+	}
+	return &ZoneStatus{
+		Power:    z.Power,
+		VolumeDb: z.Volume,
+		Source:   string(z.Source),
+		Muted:    z.Muted,
+	}
+}
+
+func signalInfo(si *avr.SignalInfo) *SignalInfo {
+	if si == nil {
+		return nil
+	}
+	return &SignalInfo{
+		SampleRateHz: int32(si.SampleRate),
+		AudioFormat:  si.AudioFormat,
+		Video:        si.Video,
+		Hdr:          si.HDR,
+	}
+}
+
+// toStatus returns err as a gRPC status error.
+func toStatus(err error) error {
+	var se *avr.StateError
+	code := codes.Unknown
+	switch {
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, avr.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, avr.ErrNotConnected), errors.Is(err, avr.ErrBusy), errors.Is(err, avr.ErrClosed):
+		code = codes.Unavailable
+	case errors.Is(err, avr.ErrUnsupported):
+		code = codes.Unimplemented
+	case errors.Is(err, avr.ErrReadOnly):
+		code = codes.PermissionDenied
+	case errors.Is(err, avr.ErrInvalidCommand):
+		code = codes.InvalidArgument
+	case errors.Is(err, avr.ErrVolumeLimited), errors.As(err, &se):
+		code = codes.FailedPrecondition
+	}
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,243 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+// Package avrhomekit exposes an avr.Amp as a HomeKit television
+// accessory with a speaker, so it can be controlled from the Home
+// app and the Control Center remote: power, the input list, volume
+// and mute via the remote's volume buttons, and menu navigation via
+// its arrow keys.
+//
+// Serve the accessory with the hap package:
+//
+//	acc, err := avrhomekit.New(ctx, amp)
+//	...
+//	srv, err := hap.NewServer(hap.NewFsStore("./db"), acc.A)
+//	...
+//	go acc.Run(ctx)
+//	srv.ListenAndServe(ctx)
+package avrhomekit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/brutella/hap/accessory"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+
+	"code.google.com/p/go-avr/avr"
+)
+
+// An Accessory is a HomeKit television accessory controlling an Amp.
+type Accessory struct {
+	*accessory.A
+
+	amp     *avr.Amp
+	tv      *service.Television
+	speaker *service.Speaker
+	inputs  []avr.Input // input with identifier i is inputs[i-1]
+}
+
+// New returns an accessory for amp, listing the amp's inputs as
+// returned by amp.Inputs or, if that fails, those of
+// amp.Capabilities or avr.CommonSources. HomeKit caches the input
+// list when the accessory is paired.
+func New(ctx context.Context, amp *avr.Amp) (*Accessory, error) {
+	caps := amp.Capabilities()
+	inputs, err := amp.Inputs(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		srcs := caps.Inputs
+		if srcs == nil {
+			srcs = avr.CommonSources
+		}
+		inputs = nil
+		for _, src := range srcs {
+			inputs = append(inputs, avr.Input{Source: src, Name: string(src)})
+		}
+	}
+	name := caps.FriendlyName
+	if name == "" {
+		name = "AVR"
+	}
+	serial := "-"
+	if len(caps.MAC) > 0 {
+		serial = caps.MAC.String()
+	}
+	tv := accessory.NewTelevision(accessory.Info{
+		Name:         name,
+		SerialNumber: serial,
+		Manufacturer: caps.Brand,
+		Model:        caps.Model,
+	})
+	acc := &Accessory{
+		A:       tv.A,
+		amp:     amp,
+		tv:      tv.Television,
+		speaker: tv.Speaker,
+		inputs:  inputs,
+	}
+	acc.setUp(name)
+	return acc, nil
+}
+
+// setUp adds the remote, volume and input source characteristics and
+// wires them to the amp.
+func (acc *Accessory) setUp(name string) {
+	tv := acc.tv
+	tv.ConfiguredName.SetValue(name)
+	tv.SleepDiscoveryMode.SetValue(characteristic.SleepDiscoveryModeAlwaysDiscoverable)
+	tv.Active.OnSetRemoteValue(func(v int) error {
+		if v == characteristic.ActiveActive {
+			return acc.amp.PowerOn()
+		}
+		return acc.amp.PowerOff()
+	})
+	tv.ActiveIdentifier.OnSetRemoteValue(func(id int) error {
+		if id < 1 || id > len(acc.inputs) {
+			return fmt.Errorf("no input with identifier %d", id)
+		}
+		return acc.amp.SelectInput(acc.inputs[id-1].Source)
+	})
+	remote := characteristic.NewRemoteKey()
+	remote.OnSetRemoteValue(acc.remoteKey)
+	tv.AddC(remote.C)
+
+	for i, in := range acc.inputs {
+		s := service.NewInputSource()
+		id := characteristic.NewIdentifier()
+		id.SetValue(i + 1)
+		s.AddC(id.C)
+		s.ConfiguredName.SetValue(in.Name)
+		s.InputSourceType.SetValue(sourceType(in.Source))
+		s.IsConfigured.SetValue(characteristic.IsConfiguredConfigured)
+		vis := characteristic.CurrentVisibilityStateShown
+		if in.Hidden {
+			vis = characteristic.CurrentVisibilityStateHidden
+		}
+		s.CurrentVisibilityState.SetValue(vis)
+		acc.AddS(s.S)
+		tv.AddS(s.S)
+	}
+
+	sp := acc.speaker
+	active := characteristic.NewActive()
+	active.SetValue(characteristic.ActiveActive)
+	sp.AddC(active.C)
+	ctl := characteristic.NewVolumeControlType()
+	ctl.SetValue(characteristic.VolumeControlTypeRelative)
+	sp.AddC(ctl.C)
+	sel := characteristic.NewVolumeSelector()
+	sel.OnSetRemoteValue(func(v int) error {
+		if v == characteristic.VolumeSelectorIncrement {
+			return acc.amp.VolumeUp()
+		}
+		return acc.amp.VolumeDown()
+	})
+	sp.AddC(sel.C)
+	sp.Mute.OnSetRemoteValue(acc.amp.Mute)
+	tv.AddS(sp.S)
+}
+
+// remoteKey applies a key press on the Control Center remote.
+func (acc *Accessory) remoteKey(key int) error {
+	m := acc.amp.Menu()
+	switch key {
+	case characteristic.RemoteKeyArrowUp:
+		return m.CursorUp()
+	case characteristic.RemoteKeyArrowDown:
+		return m.CursorDown()
+	case characteristic.RemoteKeyArrowLeft:
+		return m.CursorLeft()
+	case characteristic.RemoteKeyArrowRight:
+		return m.CursorRight()
+	case characteristic.RemoteKeySelect:
+		return m.Enter()
+	case characteristic.RemoteKeyBack, characteristic.RemoteKeyExit:
+		return m.Return()
+	case characteristic.RemoteKeyInfo:
+		return m.Info()
+	}
+	return fmt.Errorf("unsupported remote key %d", key)
+}
+
+// Run keeps the accessory's characteristics in step with the amp
+// until ctx is done or the Amp is closed.
+func (acc *Accessory) Run(ctx context.Context) error {
+	events, cancel := acc.amp.Subscribe()
+	defer cancel()
+	if st, err := acc.amp.Status(ctx); err == nil {
+		acc.setStatus(st)
+	}
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return avr.ErrClosed
+			}
+			switch ev := ev.(type) {
+			case avr.PowerChanged:
+				acc.setPower(ev.On)
+			case avr.InputChanged:
+				acc.setInput(ev.Input)
+			case avr.MuteChanged:
+				acc.speaker.Mute.SetValue(ev.Muted)
+			case avr.Resynced:
+				acc.setStatus(&ev.Status)
+			case avr.Overflow:
+				// The cached state includes whatever the dropped
+				// events reported.
+				snap := acc.amp.CachedState()
+				acc.setStatus(&snap.Status)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (acc *Accessory) setStatus(st *avr.Status) {
+	acc.setPower(st.Power)
+	acc.setInput(st.Input)
+	acc.speaker.Mute.SetValue(st.Muted)
+}
+
+func (acc *Accessory) setPower(on bool) {
+	v := characteristic.ActiveInactive
+	if on {
+		v = characteristic.ActiveActive
+	}
+	acc.tv.Active.SetValue(v)
+}
+
+func (acc *Accessory) setInput(src avr.InputSource) {
+	for i, in := range acc.inputs {
+		if in.Source == src {
+			acc.tv.ActiveIdentifier.SetValue(i + 1)
+			return
+		}
+	}
+}
+
+// sourceType returns the HomeKit input source type for src.
+func sourceType(src avr.InputSource) int {
+	switch src {
+	case avr.SourceTuner:
+		return characteristic.InputSourceTypeTuner
+	case avr.SourceNet, avr.SourceNetUSB, avr.SourceServer, avr.SourceIRadio,
+		avr.SourceFavorites, avr.SourcePandora, avr.SourceLastFM,
+		avr.SourceFlickr, avr.SourceNapster, avr.SourceRhapsody, avr.SourceBluetooth:
+		return characteristic.InputSourceTypeApplication
+	case avr.SourceUSB, avr.SourceIPod, avr.SourceDock:
+		return characteristic.InputSourceTypeUsb
+	case avr.SourcePhono, avr.SourceCD, avr.SourceAux1, avr.SourceAux2, avr.SourceVAux:
+		return characteristic.InputSourceTypeOther
+	}
+	if strings.HasPrefix(string(src), "AUX") {
+		return characteristic.InputSourceTypeOther
+	}
+	return characteristic.InputSourceTypeHdmi
+}
@@ -0,0 +1,272 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+// Package avrhttp serves an avr.Amp over HTTP with JSON bodies:
+//
+//	GET  /status   the amp's avr.Status
+//	POST /power    {"on": true}
+//	POST /volume   {"db": -30} or {"step": "up"} or {"step": "down"}
+//	POST /mute     {"muted": true}
+//	POST /input    {"input": "BD"}
+//	GET  /events   server-sent events, one per amp event
+//	GET  /history  the amp's recent events, oldest first, as
+//	               [{"time": "...", "type": "VolumeChanged", "event": {...}}]
+//	GET  /health   {"state": "connected", "last_command": "...",
+//	               "last_line": "...", "latency_ms": 12.5}, with
+//	               status 503 and "error" unless the amp answers
+//
+// Errors are returned as {"error": "..."}.
+package avrhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"code.google.com/p/go-avr/avr"
+)
+
+// keepAliveInterval is how often /events sends a comment to keep
+// idle connections open through proxies.
+const keepAliveInterval = 30 * time.Second
+
+// NewHandler returns a handler serving amp.
+func NewHandler(amp *avr.Amp) http.Handler {
+	h := &handler{amp: amp}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", h.status)
+	mux.HandleFunc("POST /power", h.power)
+	mux.HandleFunc("POST /volume", h.volume)
+	mux.HandleFunc("POST /mute", h.mute)
+	mux.HandleFunc("POST /input", h.input)
+	mux.HandleFunc("GET /events", h.events)
+	mux.HandleFunc("GET /history", h.history)
+	mux.HandleFunc("GET /health", h.health)
+	return mux
+}
+
+type handler struct {
+	amp *avr.Amp
+}
+
+func (h *handler) status(w http.ResponseWriter, r *http.Request) {
+	st, err := h.amp.Status(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (h *handler) power(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		On *bool `json:"on"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.On == nil {
+		writeError(w, badRequest(`missing "on"`))
+		return
+	}
+	if *req.On {
+		reply(w, h.amp.PowerOn())
+	} else {
+		reply(w, h.amp.PowerOff())
+	}
+}
+
+func (h *handler) volume(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DB   *float64 `json:"db"`
+		Step string   `json:"step"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	var err error
+	switch {
+	case req.DB != nil && req.Step == "":
+		err = h.amp.SetVolume(*req.DB)
+	case req.DB == nil && req.Step == "up":
+		err = h.amp.VolumeUp()
+	case req.DB == nil && req.Step == "down":
+		err = h.amp.VolumeDown()
+	default:
+		err = badRequest(`want "db" or a "step" of "up" or "down"`)
+	}
+	reply(w, err)
+}
+
+func (h *handler) mute(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Muted *bool `json:"muted"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Muted == nil {
+		writeError(w, badRequest(`missing "muted"`))
+		return
+	}
+	reply(w, h.amp.Mute(*req.Muted))
+}
+
+func (h *handler) input(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Input string `json:"input"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Input == "" {
+		writeError(w, badRequest(`missing "input"`))
+		return
+	}
+	reply(w, h.amp.SelectInput(avr.InputSource(strings.ToUpper(req.Input))))
+}
+
+// events streams the amp's events as server-sent events, with the
+// event type, such as "VolumeChanged", as the SSE event name.
+func (h *handler) events(w http.ResponseWriter, r *http.Request) {
+	fl, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, errors.New("streaming unsupported"))
+		return
+	}
+	events, cancel := h.amp.Subscribe()
+	defer cancel()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fl.Flush()
+	t := time.NewTicker(keepAliveInterval)
+	defer t.Stop()
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", EventName(ev), data)
+			fl.Flush()
+		case <-t.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			fl.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// history lists the events the amp remembers WithEventHistory.
+func (h *handler) history(w http.ResponseWriter, r *http.Request) {
+	type entry struct {
+		Time  time.Time `json:"time"`
+		Type  string    `json:"type"`
+		Event avr.Event `json:"event"`
+	}
+	entries := []entry{}
+	for _, te := range h.amp.RecentEvents() {
+		entries = append(entries, entry{te.Time, EventName(te.Event), te.Event})
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (h *handler) health(w http.ResponseWriter, r *http.Request) {
+	hc, err := h.amp.Healthcheck(r.Context())
+	resp := struct {
+		State       string     `json:"state"`
+		Error       string     `json:"error,omitempty"`
+		LastCommand *time.Time `json:"last_command,omitempty"`
+		LastLine    *time.Time `json:"last_line,omitempty"`
+		LatencyMS   float64    `json:"latency_ms"`
+	}{
+		State:     hc.State.String(),
+		LatencyMS: float64(hc.Latency.Microseconds()) / 1000,
+	}
+	if !hc.LastCommand.IsZero() {
+		resp.LastCommand = &hc.LastCommand
+	}
+	if !hc.LastLine.IsZero() {
+		resp.LastLine = &hc.LastLine
+	}
+	code := http.StatusOK
+	if err != nil {
+		resp.Error = err.Error()
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, resp)
+}
+
+// EventName returns the name of ev's type, such as "VolumeChanged".
+func EventName(ev avr.Event) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", ev), "avr.")
+}
+
+type requestError struct {
+	msg string
+}
+
+func (e *requestError) Error() string { return e.msg }
+
+func badRequest(msg string) error { return &requestError{msg} }
+
+// decode decodes r's JSON body into v, replying with an error and
+// returning false if it can't.
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, badRequest("bad JSON body: "+err.Error()))
+		return false
+	}
+	return true
+}
+
+// reply replies with err, or with {} if err is nil.
+func reply(w http.ResponseWriter, err error) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, statusCode(err), map[string]string{"error": err.Error()})
+}
+
+// statusCode returns the HTTP status for err.
+func statusCode(err error) int {
+	var re *requestError
+	var se *avr.StateError
+	switch {
+	case errors.As(err, &re), errors.Is(err, avr.ErrInvalidCommand):
+		return http.StatusBadRequest
+	case errors.Is(err, avr.ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, avr.ErrNotConnected), errors.Is(err, avr.ErrBusy):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, avr.ErrUnsupported):
+		return http.StatusNotImplemented
+	case errors.Is(err, avr.ErrReadOnly):
+		return http.StatusForbidden
+	case errors.Is(err, avr.ErrVolumeLimited), errors.As(err, &se):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
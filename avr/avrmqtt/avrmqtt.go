@@ -0,0 +1,386 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+// Package avrmqtt bridges an avr.Amp to an MQTT broker.
+//
+// The bridge publishes the amp's state as retained messages:
+//
+//	avr/available            "online" or "offline"
+//	avr/main/power           "ON" or "OFF"
+//	avr/main/volume          volume in dB, such as "-30.5"
+//	avr/main/mute            "ON" or "OFF"
+//	avr/main/input           input source, such as "SAT/CBL"
+//	avr/main/surround        surround mode, such as "STEREO"
+//	avr/main/headphones      "ON" while headphones are plugged in, else "OFF"
+//	avr/zone2/power, avr/zone2/volume, avr/zone2/mute, avr/zone2/input
+//	avr/zone3/...
+//
+// and accepts commands on the same topics followed by "/set", such
+// as "avr/main/volume/set" with payload "-35", except for headphones,
+// which are read-only. Main zone volume also accepts "UP" and "DOWN".
+// Commands are applied one at a time, in the order they arrive;
+// those that fail, or that are dropped because too many are waiting,
+// are logged. The "avr" prefix is configurable WithPrefix.
+//
+// WithHomeAssistant additionally announces the amp's entities for
+// Home Assistant's MQTT discovery.
+package avrmqtt
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"code.google.com/p/go-avr/avr"
+)
+
+// DefaultPrefix is the default topic prefix.
+const DefaultPrefix = "avr"
+
+// A Bridge publishes an Amp's state to MQTT and applies commands
+// received over MQTT.
+type Bridge struct {
+	amp    *avr.Amp
+	client mqtt.Client
+	prefix string
+	qos    byte
+	log    avr.Logger
+
+	haPrefix string // Home Assistant discovery prefix, or ""
+
+	cmds chan mqtt.Message // commands waiting for Run's worker
+}
+
+// cmdBuffer is how many commands can wait for the amp. Commands
+// arriving while it is full are dropped and logged.
+const cmdBuffer = 16
+
+// An Option configures a Bridge in New.
+type Option func(*Bridge)
+
+// WithPrefix sets the prefix of every topic. The default is
+// DefaultPrefix.
+func WithPrefix(p string) Option {
+	return func(b *Bridge) { b.prefix = strings.TrimSuffix(p, "/") }
+}
+
+// WithQoS sets the QoS of published messages and command
+// subscriptions. The default is 0.
+func WithQoS(qos byte) Option {
+	return func(b *Bridge) { b.qos = qos }
+}
+
+// WithLogger sets the Logger for failed commands and publishes. The
+// default is avr.NopLogger.
+func WithLogger(l avr.Logger) Option {
+	return func(b *Bridge) { b.log = l }
+}
+
+// New returns a bridge between amp and the broker client is connected
+// to. Call Run to start it.
+//
+// To have the broker mark the amp offline when the bridge goes away,
+// set the client's will to AvailabilityTopic with payload "offline"
+// and retain set.
+func New(amp *avr.Amp, client mqtt.Client, opts ...Option) *Bridge {
+	b := &Bridge{
+		amp:    amp,
+		client: client,
+		prefix: DefaultPrefix,
+		log:    avr.NopLogger,
+		cmds:   make(chan mqtt.Message, cmdBuffer),
+	}
+	for _, o := range opts {
+		o(b)
+	}
+	return b
+}
+
+// Topic returns the full topic for a state, such as "avr/main/volume"
+// for zone 1 and "volume". Zone numbers other than 1 are "zoneN".
+func (b *Bridge) Topic(zone int, state string) string {
+	return b.prefix + "/" + zoneName(zone) + "/" + state
+}
+
+// AvailabilityTopic returns the topic on which the bridge publishes
+// "online" while it is connected to the amp and "offline" otherwise.
+func (b *Bridge) AvailabilityTopic() string {
+	return b.prefix + "/available"
+}
+
+// Run publishes the amp's state and serves commands until ctx is
+// done, then publishes "offline" and unsubscribes. It returns ctx's
+// error, or an error if subscribing fails.
+func (b *Bridge) Run(ctx context.Context) error {
+	events, cancel := b.amp.Subscribe()
+	defer cancel()
+	changes, cancelWatch := b.amp.WatchConnState()
+	defer cancelWatch()
+
+	// Commands are applied on a goroutine of their own, as the MQTT
+	// client doesn't deliver other messages while a handler runs.
+	work, stopWork := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer stopWork()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		b.work(work)
+	}()
+
+	cmdTopic := b.prefix + "/+/+/set"
+	if t := b.client.Subscribe(cmdTopic, b.qos, b.handle); t.Wait() && t.Error() != nil {
+		return fmt.Errorf("subscribing to %s: %w", cmdTopic, t.Error())
+	}
+	defer b.client.Unsubscribe(cmdTopic)
+
+	if s, _ := b.amp.ConnState(); s == avr.Connected {
+		b.publish(b.AvailabilityTopic(), "online")
+	}
+	st, err := b.amp.Status(ctx)
+	if err != nil {
+		b.log.Error("avrmqtt: status query failed; publishing cached state", "err", err)
+		snap := b.amp.CachedState()
+		st = &snap.Status
+	}
+	b.publishStatus(st)
+
+	if b.haPrefix != "" {
+		zones := []int{1}
+		if st.Zone2 != nil {
+			zones = append(zones, 2)
+		}
+		if st.Zone3 != nil {
+			zones = append(zones, 3)
+		}
+		b.publishDiscovery(zones)
+		unsubscribe, err := b.subscribeHAStatus(zones)
+		if err != nil {
+			b.log.Error("avrmqtt: subscribing to Home Assistant status", "err", err)
+		} else {
+			defer unsubscribe()
+		}
+	}
+
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				b.publish(b.AvailabilityTopic(), "offline")
+				return avr.ErrClosed
+			}
+			b.publishEvent(ev)
+		case c, ok := <-changes:
+			if !ok {
+				continue
+			}
+			switch c.State {
+			case avr.Connected:
+				b.publish(b.AvailabilityTopic(), "online")
+			case avr.Disconnected:
+				b.publish(b.AvailabilityTopic(), "offline")
+			}
+		case <-ctx.Done():
+			b.publish(b.AvailabilityTopic(), "offline")
+			return ctx.Err()
+		}
+	}
+}
+
+func (b *Bridge) publishStatus(st *avr.Status) {
+	b.publish(b.Topic(1, "power"), onOff(st.Power))
+	b.publish(b.Topic(1, "volume"), formatDB(st.Volume))
+	b.publish(b.Topic(1, "mute"), onOff(st.Muted))
+	if st.Input != "" {
+		b.publish(b.Topic(1, "input"), string(st.Input))
+	}
+	if st.SurroundMode != "" {
+		b.publish(b.Topic(1, "surround"), string(st.SurroundMode))
+	}
+	b.publish(b.Topic(1, "headphones"), onOff(st.Headphones))
+	for n, zs := range map[int]*avr.ZoneStatus{2: st.Zone2, 3: st.Zone3} {
+		if zs == nil {
+			continue
+		}
+		b.publish(b.Topic(n, "power"), onOff(zs.Power))
+		b.publish(b.Topic(n, "volume"), formatDB(zs.Volume))
+		b.publish(b.Topic(n, "mute"), onOff(zs.Muted))
+		if zs.Source != "" {
+			b.publish(b.Topic(n, "input"), string(zs.Source))
+		}
+	}
+}
+
+func (b *Bridge) publishEvent(ev avr.Event) {
+	zone := 1
+	if ze, ok := ev.(avr.ZoneEvent); ok {
+		zone, ev = ze.Zone, ze.Event
+	}
+	switch ev := ev.(type) {
+	case avr.PowerChanged:
+		b.publish(b.Topic(zone, "power"), onOff(ev.On))
+	case avr.VolumeChanged:
+		b.publish(b.Topic(zone, "volume"), formatDB(ev.Volume))
+	case avr.MuteChanged:
+		b.publish(b.Topic(zone, "mute"), onOff(ev.Muted))
+	case avr.InputChanged:
+		b.publish(b.Topic(zone, "input"), string(ev.Input))
+	case avr.SurroundModeChanged:
+		b.publish(b.Topic(zone, "surround"), string(ev.Mode))
+	case avr.HeadphonesChanged:
+		b.publish(b.Topic(zone, "headphones"), onOff(ev.Connected))
+	case avr.Resynced:
+		b.publishStatus(&ev.Status)
+	case avr.Overflow:
+		// The cached state includes whatever the dropped events
+		// reported.
+		snap := b.amp.CachedState()
+		b.publishStatus(&snap.Status)
+	}
+}
+
+// publish publishes a retained message without waiting for the
+// broker, logging if it fails.
+func (b *Bridge) publish(topic, payload string) {
+	t := b.client.Publish(topic, b.qos, true, payload)
+	go func() {
+		if t.Wait() && t.Error() != nil {
+			b.log.Error("avrmqtt: publish failed", "topic", topic, "err", t.Error())
+		}
+	}()
+}
+
+// handle queues a message on a command topic for work.
+func (b *Bridge) handle(_ mqtt.Client, m mqtt.Message) {
+	select {
+	case b.cmds <- m:
+	default:
+		b.log.Error("avrmqtt: command dropped; too many waiting", "topic", m.Topic(), "payload", string(m.Payload()))
+	}
+}
+
+// work applies queued commands until ctx is done.
+func (b *Bridge) work(ctx context.Context) {
+	for {
+		select {
+		case m := <-b.cmds:
+			b.apply(m)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// apply applies a message on a command topic.
+func (b *Bridge) apply(m mqtt.Message) {
+	topic := strings.TrimPrefix(m.Topic(), b.prefix+"/")
+	f := strings.Split(topic, "/")
+	if len(f) != 3 || f[2] != "set" {
+		return
+	}
+	payload := strings.TrimSpace(string(m.Payload()))
+	if err := b.command(f[0], f[1], payload); err != nil {
+		b.log.Error("avrmqtt: command failed", "topic", m.Topic(), "payload", payload, "err", err)
+	}
+}
+
+// command applies payload to a state of the zone with the given name.
+func (b *Bridge) command(zone, state, payload string) error {
+	n, err := parseZone(zone)
+	if err != nil {
+		return err
+	}
+	z := b.amp.MainZone()
+	switch n {
+	case 2:
+		z = b.amp.Zone2()
+	case 3:
+		z = b.amp.Zone3()
+	}
+	switch state {
+	case "power":
+		on, err := parseOnOff(payload)
+		if err != nil {
+			return err
+		}
+		if on {
+			return z.PowerOn()
+		}
+		return z.PowerOff()
+	case "volume":
+		switch strings.ToUpper(payload) {
+		case "UP":
+			if n == 1 {
+				return b.amp.VolumeUp()
+			}
+		case "DOWN":
+			if n == 1 {
+				return b.amp.VolumeDown()
+			}
+		}
+		db, err := strconv.ParseFloat(payload, 64)
+		if err != nil {
+			return fmt.Errorf("invalid volume %q", payload)
+		}
+		return z.SetVolume(db)
+	case "mute":
+		on, err := parseOnOff(payload)
+		if err != nil {
+			return err
+		}
+		return z.Mute(on)
+	case "input":
+		return z.SetSource(avr.InputSource(payload))
+	case "surround":
+		if n != 1 {
+			return fmt.Errorf("zone %d has no surround mode", n)
+		}
+		return b.amp.SetSurroundMode(avr.SurroundMode(payload))
+	}
+	return fmt.Errorf("unknown state %q", state)
+}
+
+func zoneName(n int) string {
+	if n == 1 {
+		return "main"
+	}
+	return "zone" + strconv.Itoa(n)
+}
+
+func parseZone(s string) (int, error) {
+	switch s {
+	case "main":
+		return 1, nil
+	case "zone2":
+		return 2, nil
+	case "zone3":
+		return 3, nil
+	}
+	return 0, fmt.Errorf("unknown zone %q", s)
+}
+
+func onOff(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
+
+func parseOnOff(s string) (bool, error) {
+	switch strings.ToUpper(s) {
+	case "ON":
+		return true, nil
+	case "OFF":
+		return false, nil
+	}
+	return false, fmt.Errorf("want ON or OFF, got %q", s)
+}
+
+func formatDB(db float64) string {
+	return strconv.FormatFloat(db, 'f', -1, 64)
+}
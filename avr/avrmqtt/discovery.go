@@ -0,0 +1,177 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avrmqtt
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"code.google.com/p/go-avr/avr"
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// DefaultDiscoveryPrefix is Home Assistant's default MQTT discovery
+// prefix.
+const DefaultDiscoveryPrefix = "homeassistant"
+
+// WithHomeAssistant makes the bridge publish Home Assistant MQTT
+// discovery configs under prefix, normally DefaultDiscoveryPrefix,
+// so the amp appears in Home Assistant as a device with, per zone, a
+// power switch, a mute switch, a volume slider and an input select,
+// and a sensor for the main zone's surround mode. Home Assistant's
+// MQTT integration has no media_player entity, so it doesn't get
+// one. The configs are published when Run starts and again whenever
+// Home Assistant announces itself on its status topic.
+func WithHomeAssistant(prefix string) Option {
+	return func(b *Bridge) { b.haPrefix = strings.TrimSuffix(prefix, "/") }
+}
+
+// haDevice is the device section of a discovery config.
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer,omitempty"`
+	Model        string   `json:"model,omitempty"`
+}
+
+// haConfig is a discovery config for any of the entities the bridge
+// announces.
+type haConfig struct {
+	Name              string    `json:"name"`
+	UniqueID          string    `json:"unique_id"`
+	Device            *haDevice `json:"device"`
+	AvailabilityTopic string    `json:"availability_topic"`
+	StateTopic        string    `json:"state_topic"`
+	CommandTopic      string    `json:"command_topic,omitempty"`
+	Icon              string    `json:"icon,omitempty"`
+
+	// switch
+	PayloadOn  string `json:"payload_on,omitempty"`
+	PayloadOff string `json:"payload_off,omitempty"`
+
+	// number
+	Min  *float64 `json:"min,omitempty"`
+	Max  *float64 `json:"max,omitempty"`
+	Step float64  `json:"step,omitempty"`
+	Unit string   `json:"unit_of_measurement,omitempty"`
+	Mode string   `json:"mode,omitempty"`
+
+	// select
+	Options []string `json:"options,omitempty"`
+}
+
+// haEntity is an entity to announce, with its component type such
+// as "switch".
+type haEntity struct {
+	component string
+	objectID  string
+	config    haConfig
+}
+
+var nonIDChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// nodeID returns the discovery node ID for the amp: its MAC address
+// if known, else the topic prefix.
+func (b *Bridge) nodeID(caps avr.Capabilities) string {
+	id := b.prefix
+	if len(caps.MAC) > 0 {
+		id = caps.MAC.String()
+	}
+	return strings.Trim(nonIDChars.ReplaceAllString(id, "_"), "_")
+}
+
+// haEntities returns the entities of the given zones.
+func (b *Bridge) haEntities(caps avr.Capabilities, zones []int) []haEntity {
+	node := b.nodeID(caps)
+	dev := &haDevice{
+		Identifiers:  []string{node},
+		Name:         caps.FriendlyName,
+		Manufacturer: caps.Brand,
+		Model:        caps.Model,
+	}
+	if dev.Name == "" {
+		dev.Name = "AVR " + b.amp.Addr()
+	}
+	sources := caps.Inputs
+	if sources == nil {
+		sources = avr.CommonSources
+	}
+	options := make([]string, len(sources))
+	for i, s := range sources {
+		options[i] = string(s)
+	}
+	minVol, maxVol := proto.MinVolume, caps.MaxVolume
+	if maxVol == 0 {
+		maxVol = avr.MaxVolume
+	}
+
+	var ents []haEntity
+	add := func(component string, zone int, state, name string, c haConfig) {
+		objectID := zoneName(zone) + "_" + state
+		if zone != 1 {
+			name = "Zone " + strconv.Itoa(zone) + " " + name
+		}
+		c.Name = name
+		c.UniqueID = node + "_" + objectID
+		c.Device = dev
+		c.AvailabilityTopic = b.AvailabilityTopic()
+		c.StateTopic = b.Topic(zone, state)
+		ents = append(ents, haEntity{component, objectID, c})
+	}
+	for _, z := range zones {
+		set := func(state string) string { return b.Topic(z, state) + "/set" }
+		add("switch", z, "power", "Power", haConfig{
+			CommandTopic: set("power"), PayloadOn: "ON", PayloadOff: "OFF",
+			Icon: "mdi:power",
+		})
+		add("switch", z, "mute", "Mute", haConfig{
+			CommandTopic: set("mute"), PayloadOn: "ON", PayloadOff: "OFF",
+			Icon: "mdi:volume-off",
+		})
+		add("number", z, "volume", "Volume", haConfig{
+			CommandTopic: set("volume"), Min: &minVol, Max: &maxVol,
+			Step: 0.5, Unit: "dB", Mode: "slider", Icon: "mdi:volume-high",
+		})
+		add("select", z, "input", "Input", haConfig{
+			CommandTopic: set("input"), Options: options,
+			Icon: "mdi:video-input-hdmi",
+		})
+	}
+	add("sensor", 1, "surround", "Surround mode", haConfig{Icon: "mdi:surround-sound"})
+	return ents
+}
+
+// publishDiscovery publishes the discovery configs of the given zones.
+func (b *Bridge) publishDiscovery(zones []int) {
+	caps := b.amp.Capabilities()
+	node := b.nodeID(caps)
+	for _, e := range b.haEntities(caps, zones) {
+		data, err := json.Marshal(e.config)
+		if err != nil {
+			b.log.Error("avrmqtt: encoding discovery config", "err", err)
+			continue
+		}
+		b.publish(b.haPrefix+"/"+e.component+"/"+node+"/"+e.objectID+"/config", string(data))
+	}
+}
+
+// subscribeHAStatus republishes the discovery configs of zones each
+// time Home Assistant comes online, as it forgets entities it hasn't
+// seen configs for since starting. The returned func unsubscribes.
+func (b *Bridge) subscribeHAStatus(zones []int) (unsubscribe func(), err error) {
+	topic := b.haPrefix + "/status"
+	t := b.client.Subscribe(topic, b.qos, func(_ mqtt.Client, m mqtt.Message) {
+		if string(m.Payload()) == "online" {
+			b.publishDiscovery(zones)
+		}
+	})
+	if t.Wait() && t.Error() != nil {
+		return nil, t.Error()
+	}
+	return func() { b.client.Unsubscribe(topic) }, nil
+}
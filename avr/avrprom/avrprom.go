@@ -0,0 +1,209 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+// Package avrprom exports the activity and state of Amps as
+// Prometheus metrics. Every metric has an "amp" label with the amp's
+// address. The state gauges are read from each amp's cached state at
+// scrape time rather than followed through events, so they are never
+// left stale by events dropped for falling behind.
+//
+//	c := avrprom.NewCollector()
+//	prometheus.MustRegister(c)
+//	amp := avr.New(addr, avr.WithMetrics(c))
+package avrprom
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"code.google.com/p/go-avr/avr"
+)
+
+// A Collector is an avr.Metrics that collects the metrics of each
+// Amp created with it. Closed Amps are dropped.
+type Collector struct {
+	connects   *prometheus.CounterVec
+	reconnects *prometheus.CounterVec
+	dialErrors *prometheus.CounterVec
+	commands   *prometheus.CounterVec
+	queries    *prometheus.HistogramVec
+	queryErrs  *prometheus.CounterVec
+
+	connected *prometheus.Desc
+	queue     *prometheus.Desc
+	power     *prometheus.Desc
+	volume    *prometheus.Desc
+	muted     *prometheus.Desc
+
+	mu   sync.Mutex
+	amps map[*avr.Amp]bool // value is whether the amp has connected
+}
+
+// NewCollector returns a new Collector.
+func NewCollector() *Collector {
+	amp := []string{"amp"}
+	ampType := []string{"amp", "type"}
+	zone := []string{"amp", "zone"}
+	return &Collector{
+		connects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "avr_connects_total",
+			Help: "Connections made to the amp.",
+		}, amp),
+		reconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "avr_reconnects_total",
+			Help: "Connections made to the amp after the first.",
+		}, amp),
+		dialErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "avr_dial_errors_total",
+			Help: "Failed attempts to connect to the amp.",
+		}, amp),
+		commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "avr_commands_sent_total",
+			Help: "Lines written to the amp, by command type such as PW or MV.",
+		}, ampType),
+		queries: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "avr_query_duration_seconds",
+			Help:    "Time from writing a query to the amp's answer, by command type.",
+			Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, ampType),
+		queryErrs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "avr_query_errors_total",
+			Help: "Queries that failed after being written, by command type.",
+		}, ampType),
+		connected: prometheus.NewDesc("avr_connected",
+			"Whether the connection to the amp is up.", amp, nil),
+		queue: prometheus.NewDesc("avr_queue_depth",
+			"Commands waiting to be sent to the amp.", amp, nil),
+		power: prometheus.NewDesc("avr_power_on",
+			"Whether the zone is powered on, as last reported by the amp.", zone, nil),
+		volume: prometheus.NewDesc("avr_volume_db",
+			"The zone's volume in dB, as last reported by the amp.", zone, nil),
+		muted: prometheus.NewDesc("avr_muted",
+			"Whether the zone is muted, as last reported by the amp.", zone, nil),
+		amps: make(map[*avr.Amp]bool),
+	}
+}
+
+func (c *Collector) vecs() []prometheus.Collector {
+	return []prometheus.Collector{c.connects, c.reconnects, c.dialErrors, c.commands, c.queries, c.queryErrs}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, v := range c.vecs() {
+		v.Describe(ch)
+	}
+	ch <- c.connected
+	ch <- c.queue
+	ch <- c.power
+	ch <- c.volume
+	ch <- c.muted
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, v := range c.vecs() {
+		v.Collect(ch)
+	}
+	c.mu.Lock()
+	amps := make([]*avr.Amp, 0, len(c.amps))
+	for a := range c.amps {
+		if st, err := a.ConnState(); st == avr.Disconnected && errors.Is(err, avr.ErrClosed) {
+			delete(c.amps, a)
+			continue
+		}
+		amps = append(amps, a)
+	}
+	c.mu.Unlock()
+	for _, a := range amps {
+		addr := a.Addr()
+		st, _ := a.ConnState()
+		ch <- prometheus.MustNewConstMetric(c.connected, prometheus.GaugeValue, boolValue(st == avr.Connected), addr)
+		ch <- prometheus.MustNewConstMetric(c.queue, prometheus.GaugeValue, float64(a.QueueDepth()), addr)
+		snap := a.CachedState()
+		if snap.Revision == 0 {
+			continue // nothing reported yet
+		}
+		s := snap.Status
+		c.zone(ch, addr, 1, s.Power, s.Volume, s.Muted)
+		for n, zs := range map[int]*avr.ZoneStatus{2: s.Zone2, 3: s.Zone3} {
+			if zs != nil {
+				c.zone(ch, addr, n, zs.Power, zs.Volume, zs.Muted)
+			}
+		}
+	}
+}
+
+func (c *Collector) zone(ch chan<- prometheus.Metric, addr string, n int, power bool, volume float64, muted bool) {
+	z := strconv.Itoa(n)
+	ch <- prometheus.MustNewConstMetric(c.power, prometheus.GaugeValue, boolValue(power), addr, z)
+	ch <- prometheus.MustNewConstMetric(c.volume, prometheus.GaugeValue, volume, addr, z)
+	ch <- prometheus.MustNewConstMetric(c.muted, prometheus.GaugeValue, boolValue(muted), addr, z)
+}
+
+// DialDone implements avr.Metrics.
+func (c *Collector) DialDone(a *avr.Amp, err error) {
+	addr := a.Addr()
+	if err != nil {
+		c.dialErrors.WithLabelValues(addr).Inc()
+		c.track(a, false)
+		return
+	}
+	c.connects.WithLabelValues(addr).Inc()
+	if c.track(a, true) {
+		c.reconnects.WithLabelValues(addr).Inc()
+	}
+}
+
+// track records a, and whether it has now connected. It reports
+// whether a had connected before.
+func (c *Collector) track(a *avr.Amp, connected bool) (before bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	before = c.amps[a]
+	c.amps[a] = before || connected
+	return before
+}
+
+// CommandSent implements avr.Metrics.
+func (c *Collector) CommandSent(a *avr.Amp, cmd string) {
+	c.track(a, false)
+	c.commands.WithLabelValues(a.Addr(), CommandType(cmd)).Inc()
+}
+
+// QueryDone implements avr.Metrics.
+func (c *Collector) QueryDone(a *avr.Amp, query string, latency time.Duration, err error) {
+	typ := CommandType(query)
+	if err != nil {
+		c.queryErrs.WithLabelValues(a.Addr(), typ).Inc()
+		return
+	}
+	c.queries.WithLabelValues(a.Addr(), typ).Observe(latency.Seconds())
+}
+
+// CommandType returns the type of cmd used as the "type" label: its
+// leading two characters, such as "PW" for "PWON" or "Z2" for
+// "Z2MUON", or "other" if those aren't letters or digits.
+func CommandType(cmd string) string {
+	if len(cmd) < 2 {
+		return "other"
+	}
+	for _, r := range cmd[:2] {
+		if r > unicode.MaxASCII || !(unicode.IsUpper(r) || unicode.IsDigit(r)) {
+			return "other"
+		}
+	}
+	return cmd[:2]
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,208 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+// Package avrproxy serves an avr.Amp to clients speaking the amp's
+// own telnet protocol, such as control systems with Denon drivers.
+// The amp accepts a single control session, so rather than compete
+// for it, such clients connect to the proxy, which sends their
+// commands through the Amp's queue and pacing alongside those of Go
+// code, and relays every line the amp sends to every client.
+//
+// Commands the Amp refuses, as when it is read-only, are dropped, as
+// the amp drops commands it doesn't understand.
+package avrproxy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	"code.google.com/p/go-avr/avr"
+)
+
+// maxLineLen is the length of the longest command the proxy relays.
+const maxLineLen = 256
+
+// A Server relays telnet protocol clients to an Amp.
+type Server struct {
+	amp *avr.Amp
+	log avr.Logger
+
+	mu        sync.Mutex
+	closed    bool
+	listeners map[net.Listener]bool
+	conns     map[net.Conn]bool
+	wg        sync.WaitGroup // for connections
+}
+
+// An Option configures a Server in NewServer.
+type Option func(*Server)
+
+// WithLogger sets the Logger for dropped commands and client
+// errors. The default is avr.NopLogger.
+func WithLogger(l avr.Logger) Option {
+	return func(s *Server) { s.log = l }
+}
+
+// NewServer returns a server relaying clients to amp.
+func NewServer(amp *avr.Amp, opts ...Option) *Server {
+	s := &Server{
+		amp:       amp,
+		log:       avr.NopLogger,
+		listeners: make(map[net.Listener]bool),
+		conns:     make(map[net.Conn]bool),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// ErrServerClosed is returned by Serve and ListenAndServe after
+// Close.
+var ErrServerClosed = errors.New("proxy server closed")
+
+// ListenAndServe listens on TCP address addr, such as ":23", and
+// serves clients on it; see Serve.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts clients on l until Close, then returns
+// ErrServerClosed, or until l fails, returning its error. It closes
+// l.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+		l.Close()
+	}()
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			c.Close()
+			return ErrServerClosed
+		}
+		s.conns[c] = true
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serveConn(c)
+	}
+}
+
+// Close stops the server's listeners, disconnects its clients and
+// waits for their commands in progress to finish. It doesn't close
+// the Amp.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// serveConn relays client c until it disconnects.
+func (s *Server) serveConn(c net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+	s.log.Debug("avrproxy: client connected", "client", c.RemoteAddr())
+
+	lines, cancel := s.amp.WatchLines()
+	ctx, stop := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.relay(c, lines)
+		stop()
+		c.Close() // ends the read loop
+	}()
+	err := s.readCommands(ctx, c)
+	cancel() // ends relay
+	wg.Wait()
+	s.log.Debug("avrproxy: client disconnected", "client", c.RemoteAddr(), "err", err)
+}
+
+// relay writes the amp's lines to c until lines is closed or a write
+// fails.
+func (s *Server) relay(c net.Conn, lines <-chan string) {
+	w := bufio.NewWriter(c)
+	for l := range lines {
+		w.WriteString(l)
+		w.WriteByte('\r')
+		if len(lines) > 0 {
+			continue // flush once caught up
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// readCommands sends the commands c sends to the amp until c fails
+// or ctx is done.
+func (s *Server) readCommands(ctx context.Context, c net.Conn) error {
+	r := bufio.NewReaderSize(c, maxLineLen)
+	for {
+		b, err := r.ReadSlice('\r')
+		if err == bufio.ErrBufferFull {
+			s.log.Debug("avrproxy: dropping overlong command", "client", c.RemoteAddr())
+			for err == bufio.ErrBufferFull {
+				_, err = r.ReadSlice('\r')
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		// Some clients end commands with CR LF.
+		cmd := strings.Trim(string(b), "\r\n")
+		if cmd == "" {
+			continue
+		}
+		if err := s.amp.SendCommandContext(ctx, cmd); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			s.log.Debug("avrproxy: dropping command", "client", c.RemoteAddr(), "cmd", cmd, "err", err)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+// Package avrtest provides a simulated Denon AVR for testing code
+// that uses package avr without hardware.
+package avrtest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// A Simulator is a fake amp listening on a local TCP port. It keeps
+// the state set by commands, answers queries from it, echoes
+// commands as the amp does, and sends changes made with Set to every
+// connection, like the amp's unsolicited reports.
+type Simulator struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	state    map[string]string // parameter to its full report line
+	conns    map[net.Conn]bool
+	received []string
+	respond  func(cmd string) (replies []string, ok bool)
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// maxVolumeLine is the volume limit report that follows master
+// volume reports.
+const maxVolumeLine = "MVMAX 98"
+
+// NewSimulator starts a Simulator on a local port. The simulated amp
+// is on, at -40dB, unmuted, on the CD input in stereo, with zone 2
+// off.
+func NewSimulator() (*Simulator, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Simulator{
+		ln:    ln,
+		state: make(map[string]string),
+		conns: make(map[net.Conn]bool),
+	}
+	for _, l := range []string{"PWON", "ZMON", "MV40", "MUOFF", "SICD", "MSSTEREO", "Z2OFF", "Z240", "Z2CD", "Z2MUOFF"} {
+		s.state[param(l)] = l
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the host:port to pass to avr.New.
+func (s *Simulator) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Close stops the Simulator and closes its connections.
+func (s *Simulator) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+// Set changes the simulated state as if from the front panel or
+// remote: l is a report line such as "MV50" or "PWSTANDBY", which is
+// stored and sent to every connection.
+func (s *Simulator) Set(l string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state[param(l)] = l
+	s.broadcast(l)
+}
+
+// Emit sends line l to every connection without changing the state.
+func (s *Simulator) Emit(l string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.broadcast(l)
+}
+
+// Get returns the state's report line for parameter p, such as "MV"
+// or "Z2MU", or "" if it has none.
+func (s *Simulator) Get(p string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state[p]
+}
+
+// Received returns the commands received so far, oldest first.
+func (s *Simulator) Received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.received...)
+}
+
+// Disconnect closes every connection, as the amp does when it reboots
+// or another client takes over.
+func (s *Simulator) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.Close()
+	}
+}
+
+// Respond sets f to be called with each command before the built-in
+// handling. If f returns ok, its replies are sent instead.
+func (s *Simulator) Respond(f func(cmd string) (replies []string, ok bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.respond = f
+}
+
+// must be called with mu held
+func (s *Simulator) broadcast(lines ...string) {
+	for c := range s.conns {
+		write(c, lines)
+	}
+}
+
+func write(c net.Conn, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	c.Write([]byte(strings.Join(lines, "\r") + "\r"))
+}
+
+func (s *Simulator) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			c.Close()
+			return
+		}
+		s.conns[c] = true
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.handleConn(c)
+	}
+}
+
+func (s *Simulator) handleConn(c net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+	br := bufio.NewReader(c)
+	for {
+		cmd, err := br.ReadString('\r')
+		if err != nil {
+			return
+		}
+		cmd = strings.TrimSuffix(cmd, "\r")
+		if cmd == "" {
+			continue
+		}
+		s.mu.Lock()
+		s.received = append(s.received, cmd)
+		f := s.respond
+		s.mu.Unlock()
+		if f != nil {
+			if replies, ok := f(cmd); ok {
+				write(c, replies)
+				continue
+			}
+		}
+		s.command(c, cmd)
+	}
+}
+
+// command carries out cmd. Queries are answered on c alone; changes
+// are reported to every connection, as the amp does.
+func (s *Simulator) command(c net.Conn, cmd string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := strings.CutSuffix(cmd, "?"); ok {
+		write(c, s.query(strings.TrimRight(p, " ")))
+		return
+	}
+	if cmd == "NSE" || cmd == "NSA" {
+		// Requests the network audio display.
+		write(c, s.query(cmd))
+		return
+	}
+	replies, err := s.set(cmd)
+	if err != nil {
+		return // the amp ignores bad commands
+	}
+	s.broadcast(replies...)
+}
+
+// query returns the reports answering a query for parameter p.
+//
+// must be called with mu held
+func (s *Simulator) query(p string) []string {
+	switch p {
+	case "MV":
+		return []string{s.state["MV"], maxVolumeLine}
+	case "Z2", "Z3":
+		var ls []string
+		for _, q := range []string{p, p + "VOL", p + "SRC"} {
+			if l := s.state[q]; l != "" {
+				ls = append(ls, l)
+			}
+		}
+		return ls
+	}
+	if l := s.state[p]; l != "" {
+		return []string{l}
+	}
+	// Parameters with several values, such as channel levels.
+	var ls []string
+	for q, l := range s.state {
+		if strings.HasPrefix(q, p) {
+			ls = append(ls, l)
+		}
+	}
+	sort.Strings(ls)
+	return ls
+}
+
+// set applies cmd to the state and returns the resulting reports.
+//
+// must be called with mu held
+func (s *Simulator) set(cmd string) ([]string, error) {
+	switch cmd {
+	case "MVUP", "MVDOWN":
+		l, err := step(s.state["MV"], "MV", cmd == "MVUP")
+		if err != nil {
+			return nil, err
+		}
+		s.state["MV"] = l
+		return []string{l, maxVolumeLine}, nil
+	case "Z2UP", "Z2DOWN", "Z3UP", "Z3DOWN":
+		z := cmd[:2]
+		l, err := step(s.state[z+"VOL"], z, strings.HasSuffix(cmd, "UP"))
+		if err != nil {
+			return nil, err
+		}
+		s.state[z+"VOL"] = l
+		return []string{l}, nil
+	}
+	if len(cmd) < 3 {
+		return nil, errors.New("unknown command")
+	}
+	s.state[param(cmd)] = cmd
+	replies := []string{cmd}
+	if param(cmd) == "MV" {
+		replies = append(replies, maxVolumeLine)
+	}
+	return replies, nil
+}
+
+// step returns the report for volume report l, with prefix p, one
+// half step up or down.
+func step(l, p string, up bool) (string, error) {
+	db, err := proto.ParseVolume(strings.TrimPrefix(l, p))
+	if err != nil {
+		return "", err
+	}
+	if up {
+		db += 0.5
+	} else {
+		db -= 0.5
+	}
+	db = math.Max(-80, math.Min(18, db))
+	halves := int(math.Round((db + 80) * 2))
+	if halves%2 == 0 {
+		return fmt.Sprintf("%s%02d", p, halves/2), nil
+	}
+	return fmt.Sprintf("%s%02d5", p, halves/2), nil
+}
+
+// param returns the state parameter set by report or command l.
+func param(l string) string {
+	if p, _, ok := strings.Cut(l, " "); ok {
+		return p
+	}
+	switch m := proto.Parse(l).(type) {
+	case proto.Zone:
+		z := l[:2]
+		switch m.Msg.(type) {
+		case proto.Volume:
+			return z + "VOL"
+		case proto.Input:
+			return z + "SRC"
+		case proto.Mute:
+			return z + "MU"
+		case proto.Power:
+			return z
+		}
+	case proto.Unknown:
+		if strings.HasPrefix(l, "Z2") || strings.HasPrefix(l, "Z3") {
+			return l // a zone setting such as "Z2SOURCE"
+		}
+	case proto.QuickSelect:
+		return "MSQUICK"
+	case proto.Display:
+		return l[:4]
+	}
+	for _, p := range []string{"TFAN", "SLP", "SSINF"} {
+		if strings.HasPrefix(l, p) {
+			return p
+		}
+	}
+	return l[:min(2, len(l))]
+}
@@ -0,0 +1,103 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"fmt"
+)
+
+// A Command is one command of a batch; see SendBatch.
+type Command struct {
+	Cmd string // a raw command, such as "MV45"
+
+	// Match, if non-nil, accepts the amp line acknowledging Cmd.
+	// Otherwise Cmd is acknowledged as WithAck describes: by its
+	// echo or, for commands ending in UP or DOWN, by any report of
+	// the same parameter.
+	Match func(string) bool
+
+	// NoAck sends Cmd without waiting for an acknowledgement, for
+	// commands the amp doesn't echo.
+	NoAck bool
+}
+
+// A CommandResult is the outcome of one command of a batch.
+type CommandResult struct {
+	Cmd  string
+	Line string // the amp's acknowledgement, unless NoAck
+	Err  error
+}
+
+// SendBatch queues cmds for the amp together and waits for all of
+// their acknowledgements at once, returning a result for each in
+// order and the first of their errors, if any. Commands go out in
+// order at the usual pace, except that power commands go first and
+// a volume command replaces a queued one for the same volume, which
+// then fails with ErrSuperseded. A command that fails doesn't stop
+// the others. Commands are checked as by SendCommand, but WithAck
+// retries are not applied.
+//
+// If ctx has no deadline, SendBatch gives up after the command
+// timeout plus the time pacing the batch takes.
+func (a *Amp) SendBatch(ctx context.Context, cmds []Command) ([]CommandResult, error) {
+	ctx, cancel := a.batchContext(ctx, cmds)
+	defer cancel()
+	results := make([]CommandResult, len(cmds))
+	reqs := make([]request, len(cmds))
+	for i, c := range cmds {
+		results[i].Cmd = c.Cmd
+		if err := a.checkStrict(c.Cmd); err != nil {
+			results[i].Err = err
+			continue
+		}
+		if err := a.checkRawVolume(c.Cmd); err != nil {
+			results[i].Err = err
+			continue
+		}
+		req := request{ch: make(chan *response, 1), cmd: rawCmd, raw: c.Cmd}
+		if !c.NoAck {
+			req.cmd, req.match = queryCmd, c.Match
+			if req.match == nil {
+				req.match = ackMatch(c.Cmd)
+			}
+		}
+		if err := a.submit(ctx, &req); err != nil {
+			results[i].Err = err
+			continue
+		}
+		reqs[i] = req
+	}
+	var first error
+	for i, req := range reqs {
+		if req.ch != nil {
+			select {
+			case res := <-req.ch:
+				results[i].Line, results[i].Err = res.line, res.err
+			case <-ctx.Done():
+				results[i].Err = ctx.Err()
+			}
+		}
+		if err := timeoutErr(results[i].Err); err != nil {
+			results[i].Err = err
+			if first == nil {
+				first = fmt.Errorf("%q: %w", cmds[i].Cmd, err)
+			}
+		}
+	}
+	return results, first
+}
+
+// batchContext returns ctx bounded by the time sending cmds should
+// take, unless it already has a deadline.
+func (a *Amp) batchContext(ctx context.Context, cmds []Command) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	d := a.cmdTimeout + a.busyRemaining()
+	for _, c := range cmds {
+		d += a.gaps.After(c.Cmd) + a.latency(c.Cmd)
+	}
+	return context.WithTimeout(ctx, d)
+}
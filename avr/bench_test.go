@@ -0,0 +1,122 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"code.google.com/p/go-avr/avr/avrtest"
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// newBenchAmp returns an Amp connected to a new Simulator, with no
+// gap between commands so that the benchmarks measure the Amp rather
+// than its pacing.
+func newBenchAmp(b *testing.B) (*Amp, *avrtest.Simulator) {
+	b.Helper()
+	sim, err := avrtest.NewSimulator()
+	if err != nil {
+		b.Fatal(err)
+	}
+	a := New(sim.Addr(), WithCommandGap(0), WithCapabilities(Capabilities{}), WithExclusiveConnection())
+	b.Cleanup(func() {
+		a.Close()
+		sim.Close()
+	})
+	if err := a.Ping(); err != nil {
+		b.Fatal(err)
+	}
+	return a, sim
+}
+
+func BenchmarkSendCommand(b *testing.B) {
+	a, _ := newBenchAmp(b)
+	cmds := []string{"MUON", "MUOFF"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := a.SendCommand(cmds[i%2]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkQueryRoundTrip(b *testing.B) {
+	a, _ := newBenchAmp(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := a.PowerState(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEventFanout(b *testing.B) {
+	for _, n := range []int{1, 16} {
+		b.Run(fmt.Sprintf("subscribers=%d", n), func(b *testing.B) {
+			a, sim := newBenchAmp(b)
+			subs := make([]<-chan Event, n)
+			for i := range subs {
+				events, cancel := a.Subscribe()
+				defer cancel()
+				subs[i] = events
+			}
+			lines := []string{"MV50", "MV51"}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				sim.Set(lines[i%2])
+				for _, events := range subs {
+					for ev := range events {
+						if _, ok := ev.(VolumeChanged); ok {
+							break
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+// A repeatReader reads data over and over.
+type repeatReader struct {
+	data []byte
+	off  int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		m := copy(p[n:], r.data[r.off:])
+		n += m
+		r.off = (r.off + m) % len(r.data)
+	}
+	return n, nil
+}
+
+// benchLines is typical traffic from an amp in use.
+const benchLines = "MV45\rMVMAX 98\rPWON\rZMON\rSICD\rMSSTEREO\rMUOFF\rZ2OFF\rZ240\rNSE1Song\rMV46\rMVMAX 98\r"
+
+// BenchmarkReadLine measures reading an amp line and updating the
+// cached state from it, with no subscribers. Run with -benchmem:
+// lines the amp repeats shouldn't allocate.
+func BenchmarkReadLine(b *testing.B) {
+	a := New("bench", WithDialer(func(context.Context, string, string) (net.Conn, error) {
+		return nil, errors.New("not dialing")
+	}), WithCapabilities(Capabilities{}))
+	defer a.Close()
+	c := &conn{a: a, bufr: bufio.NewReaderSize(&repeatReader{data: []byte(benchLines)}, proto.MaxLineLen)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bs, err := c.bufr.ReadSlice('\r')
+		if err != nil {
+			b.Fatal(err)
+		}
+		a.updateCache(c.lines.line(c.id, bs[:len(bs)-1]))
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import "fmt"
+
+// BluetoothOutput is where the amp plays while its Bluetooth
+// transmitter is on.
+type BluetoothOutput string
+
+const (
+	BluetoothAndSpeakers BluetoothOutput = "SP" // Bluetooth headphones and the speakers
+	BluetoothOnly        BluetoothOutput = "BT" // Bluetooth headphones only
+)
+
+// The amp answers "BTTX ?" with two lines, such as "BTTX ON" and
+// "BTTX SP", so each setting needs its own match func.
+
+func isBluetoothSwitchLine(l string) bool {
+	return l == "BTTX ON" || l == "BTTX OFF"
+}
+
+func isBluetoothOutputLine(l string) bool {
+	return l == "BTTX SP" || l == "BTTX BT"
+}
+
+// SetBluetoothTransmitter turns the Bluetooth transmitter, which
+// sends the main zone's audio to Bluetooth headphones or speakers,
+// on or off.
+func (a *Amp) SetBluetoothTransmitter(on bool) error {
+	cmd := "BTTX OFF"
+	if on {
+		cmd = "BTTX ON"
+	}
+	return a.setConfirm(cmd, isBluetoothSwitchLine, cmd)
+}
+
+// BluetoothTransmitter reports whether the Bluetooth transmitter is
+// on.
+func (a *Amp) BluetoothTransmitter() (bool, error) {
+	l, err := a.timeoutQuery("BTTX ?", isBluetoothSwitchLine)
+	if err != nil {
+		return false, err
+	}
+	return l == "BTTX ON", nil
+}
+
+// SetBluetoothOutput sets where the amp plays while the Bluetooth
+// transmitter is on.
+func (a *Amp) SetBluetoothOutput(o BluetoothOutput) error {
+	switch o {
+	case BluetoothAndSpeakers, BluetoothOnly:
+	default:
+		return fmt.Errorf("invalid Bluetooth output %q", o)
+	}
+	cmd := "BTTX " + string(o)
+	return a.setConfirm(cmd, isBluetoothOutputLine, cmd)
+}
+
+// GetBluetoothOutput returns where the amp plays while the Bluetooth
+// transmitter is on.
+func (a *Amp) GetBluetoothOutput() (BluetoothOutput, error) {
+	l, err := a.timeoutQuery("BTTX ?", isBluetoothOutputLine)
+	if err != nil {
+		return "", err
+	}
+	return BluetoothOutput(l[len("BTTX "):]), nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// A CommandBuilder builds a raw command for SendCommand from a zone
+// and a setting, for commands chosen at run time, as from a
+// configuration file:
+//
+//	cmd, err := avr.Cmd().Zone(2).Volume(-35).Build() // "Z245"
+//
+// The zone defaults to the main zone. Mistakes, such as a volume out
+// of range or two settings, are reported by Build. Sending the command
+// with SendCommand paces it like any other, and WithStrictValidation
+// also checks it against the amp's capabilities.
+type CommandBuilder struct {
+	zone    int
+	setting func(zone int) (string, error) // nil until a setting is given
+	err     error                          // the first mistake
+}
+
+// Cmd returns a CommandBuilder for the main zone.
+func Cmd() *CommandBuilder {
+	return &CommandBuilder{zone: 1}
+}
+
+// Zone sets the zone the command is for: 1 for the main zone, or 2
+// or 3.
+func (b *CommandBuilder) Zone(n int) *CommandBuilder {
+	if n < 1 || n > 3 {
+		b.fail(fmt.Errorf("no zone %d", n))
+	}
+	b.zone = n
+	return b
+}
+
+// Power turns the zone on or off.
+func (b *CommandBuilder) Power(on bool) *CommandBuilder {
+	return b.set(func(zone int) (string, error) {
+		p := zonePrefix(zone)
+		if zone == 1 {
+			p = "ZM"
+		}
+		if on {
+			return p + "ON", nil
+		}
+		return p + "OFF", nil
+	})
+}
+
+// Volume sets the zone's volume to db, rounded to the nearest half
+// step.
+func (b *CommandBuilder) Volume(db float64) *CommandBuilder {
+	return b.set(func(zone int) (string, error) {
+		enc, err := encodeVolume(db)
+		if err != nil {
+			return "", err
+		}
+		return volumePrefix(zone) + enc, nil
+	})
+}
+
+// VolumeUp raises the zone's volume by one step.
+func (b *CommandBuilder) VolumeUp() *CommandBuilder {
+	return b.set(func(zone int) (string, error) {
+		return volumePrefix(zone) + "UP", nil
+	})
+}
+
+// VolumeDown lowers the zone's volume by one step.
+func (b *CommandBuilder) VolumeDown() *CommandBuilder {
+	return b.set(func(zone int) (string, error) {
+		return volumePrefix(zone) + "DOWN", nil
+	})
+}
+
+// Mute mutes or unmutes the zone.
+func (b *CommandBuilder) Mute(on bool) *CommandBuilder {
+	return b.set(func(zone int) (string, error) {
+		p := zonePrefix(zone)
+		if zone == 1 {
+			p = ""
+		}
+		if on {
+			return p + "MUON", nil
+		}
+		return p + "MUOFF", nil
+	})
+}
+
+// Input switches the zone to src.
+func (b *CommandBuilder) Input(src InputSource) *CommandBuilder {
+	return b.set(func(zone int) (string, error) {
+		if src == "" {
+			return "", errors.New("empty input source")
+		}
+		if zone == 1 {
+			return "SI" + string(src), nil
+		}
+		return zonePrefix(zone) + string(src), nil
+	})
+}
+
+// SurroundMode sets the surround mode, which only the main zone has.
+func (b *CommandBuilder) SurroundMode(m SurroundMode) *CommandBuilder {
+	return b.set(func(zone int) (string, error) {
+		if zone != 1 {
+			return "", fmt.Errorf("zone %d has no surround mode", zone)
+		}
+		if m == "" {
+			return "", errors.New("empty surround mode")
+		}
+		return "MS" + string(m), nil
+	})
+}
+
+// Build returns the command, without a trailing carriage return, or
+// an error wrapping ErrInvalidCommand describing the first mistake.
+func (b *CommandBuilder) Build() (string, error) {
+	if b.err == nil && b.setting == nil {
+		b.fail(errors.New("no setting"))
+	}
+	if b.err != nil {
+		return "", b.err
+	}
+	cmd, err := b.setting(b.zone)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidCommand, err)
+	}
+	return cmd, nil
+}
+
+// set records the builder's setting.
+func (b *CommandBuilder) set(f func(zone int) (string, error)) *CommandBuilder {
+	if b.setting != nil {
+		b.fail(errors.New("more than one setting"))
+	}
+	b.setting = f
+	return b
+}
+
+// fail records err unless an earlier mistake was recorded.
+func (b *CommandBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = fmt.Errorf("%w: %v", ErrInvalidCommand, err)
+	}
+}
+
+// zonePrefix returns the command prefix of zone 2 or 3, such as "Z2".
+func zonePrefix(zone int) string {
+	return "Z" + strconv.Itoa(zone)
+}
+
+// volumePrefix returns the volume command prefix of a zone.
+func volumePrefix(zone int) string {
+	if zone == 1 {
+		return "MV"
+	}
+	return zonePrefix(zone)
+}
@@ -0,0 +1,107 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// defaultLatencies returns how long the amp may take to act on slow
+// commands, by command prefix. Powering on from standby, the amp
+// goes silent for several seconds.
+func defaultLatencies() map[string]time.Duration {
+	return map[string]time.Duration{
+		"PWON": 4 * time.Second,
+		"ZMON": 4 * time.Second,
+	}
+}
+
+// WithCommandLatency declares that the amp may take up to d to act
+// on any command beginning with prefix, ignoring other commands
+// meanwhile. After sending such a command the Amp is Busy for d:
+// later commands are queued rather than sent, and their timeouts are
+// extended by the rest of d instead of expiring while they wait. The
+// command's own timeout is extended by d too. Power-on commands count
+// only when the amp is off. By default PWON and ZMON take four
+// seconds; a d of zero removes prefix's latency.
+func WithCommandLatency(prefix string, d time.Duration) Option {
+	return func(a *Amp) {
+		if d <= 0 {
+			delete(a.latencies, prefix)
+			return
+		}
+		a.latencies[prefix] = d
+	}
+}
+
+// Busy reports whether the amp is still acting on a slow command, as
+// declared WithCommandLatency, and if so until when.
+func (a *Amp) Busy() (busy bool, until time.Time) {
+	until = time.Unix(0, a.busyUntil.Load())
+	if !time.Now().Before(until) {
+		return false, time.Time{}
+	}
+	return true, until
+}
+
+// busyRemaining returns how much longer the amp is busy.
+func (a *Amp) busyRemaining() time.Duration {
+	return max(time.Until(time.Unix(0, a.busyUntil.Load())), 0)
+}
+
+// latency returns the expected latency of command cmd.
+func (a *Amp) latency(cmd string) time.Duration {
+	var d time.Duration
+	for prefix, l := range a.latencies {
+		if strings.HasPrefix(cmd, prefix) && l > d {
+			d = l
+		}
+	}
+	return d
+}
+
+// noteSent makes the amp busy after sending cmd if cmd is slow.
+//
+// run in loop goroutine
+func (a *Amp) noteSent(cmd string) {
+	d := a.latency(cmd)
+	if d == 0 {
+		return
+	}
+	if isPowerOn(proto.Parse(cmd)) {
+		a.mu.Lock()
+		on := a.cache.Power
+		a.mu.Unlock()
+		if on {
+			return
+		}
+	}
+	until := time.Now().Add(d)
+	if until.UnixNano() > a.busyUntil.Load() {
+		a.busyUntil.Store(until.UnixNano())
+	}
+}
+
+// isPowerOn reports whether command m turns the amp or its main zone
+// on.
+func isPowerOn(m proto.Message) bool {
+	if z, ok := m.(proto.Zone); ok && z.Zone == 1 {
+		m = z.Msg
+	}
+	p, ok := m.(proto.Power)
+	return ok && p.On
+}
+
+// commandContext is like timeoutContext but also allows for cmd's
+// expected latency.
+func (a *Amp) commandContext(ctx context.Context, cmd string) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, a.cmdTimeout+a.busyRemaining()+a.latency(cmd))
+}
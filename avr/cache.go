@@ -0,0 +1,152 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import "code.google.com/p/go-avr/avr/proto"
+
+// A Snapshot is the amp's state as last reported by the amp.
+type Snapshot struct {
+	Status
+
+	// Revision increases each time the state changes. It is zero if
+	// the amp has not yet reported anything.
+	Revision uint64
+}
+
+// CachedState returns the amp's state as tracked from every line it
+// has sent, without a round trip. Fields the amp has not reported
+// since New are zero; call Status to fill them in. It doesn't lock,
+// so it is cheap enough to call on every frame of a user interface.
+func (a *Amp) CachedState() Snapshot {
+	s := a.snap.Load()
+	if s == nil {
+		return Snapshot{}
+	}
+	return Snapshot{Status: *s.clone(), Revision: s.Revision}
+}
+
+// cacheChanged records a change to the cached state for CachedState.
+// The snapshot is never modified once stored.
+//
+// must be called with mu held
+func (a *Amp) cacheChanged() {
+	var rev uint64
+	if s := a.snap.Load(); s != nil {
+		rev = s.Revision
+	}
+	a.snap.Store(&Snapshot{Status: *a.cache.clone(), Revision: rev + 1})
+}
+
+// updateCache applies amp line ampl to the cached state and publishes
+// the resulting event, if any.
+//
+// run in loop goroutine
+func (a *Amp) updateCache(ampl ampLine) {
+	l, m, ev := ampl.l, ampl.m, ampl.ev
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.publishLine(l)
+	switch m := m.(type) {
+	case proto.MaxVolume:
+		a.updateMaxVolume(m.DB)
+	case proto.Display:
+		a.updateDisplay(m)
+	case proto.Signal:
+		a.updateSignal(m)
+	case proto.Update:
+		a.noteUpdate(m)
+	case proto.Power:
+		if m.On && !a.cache.Power {
+			a.poweredOn()
+		}
+	}
+	switch ev.(type) {
+	case nil:
+		if len(a.subscribers) > 0 {
+			a.publish(RawLine{Line: l})
+		}
+		return
+	case UpdateStatus, QuickSelectRecalled, AllZoneStereoChanged:
+		// Not state the cache holds.
+		a.publish(ev)
+		return
+	}
+	if a.cache.apply(ev) {
+		a.cacheChanged()
+		a.publish(ev)
+	}
+}
+
+// clone returns a deep copy of st.
+func (st *Status) clone() *Status {
+	c := *st
+	if st.Zone2 != nil {
+		z := *st.Zone2
+		c.Zone2 = &z
+	}
+	if st.Zone3 != nil {
+		z := *st.Zone3
+		c.Zone3 = &z
+	}
+	if st.Signal != nil {
+		si := *st.Signal
+		c.Signal = &si
+	}
+	return &c
+}
+
+// apply updates st from ev, reporting whether st changed. It runs
+// for every amp line, so it compares fields rather than copying st.
+func (st *Status) apply(ev Event) bool {
+	switch ev := ev.(type) {
+	case PowerChanged:
+		return set(&st.Power, ev.On)
+	case VolumeChanged:
+		return set(&st.Volume, ev.Volume)
+	case InputChanged:
+		return set(&st.Input, ev.Input)
+	case SurroundModeChanged:
+		return set(&st.SurroundMode, ev.Mode)
+	case MuteChanged:
+		return set(&st.Muted, ev.Muted)
+	case HeadphonesChanged:
+		return set(&st.Headphones, ev.Connected)
+	case ZoneEvent:
+		zp := &st.Zone2
+		if ev.Zone == 3 {
+			zp = &st.Zone3
+		}
+		added := *zp == nil
+		if added {
+			*zp = new(ZoneStatus)
+		}
+		return (*zp).apply(ev.Event) || added
+	}
+	return false
+}
+
+// apply updates zs from ev, a zone's change event, reporting whether
+// zs changed.
+func (zs *ZoneStatus) apply(ev Event) bool {
+	switch ev := ev.(type) {
+	case PowerChanged:
+		return set(&zs.Power, ev.On)
+	case VolumeChanged:
+		return set(&zs.Volume, ev.Volume)
+	case MuteChanged:
+		return set(&zs.Muted, ev.Muted)
+	case InputChanged:
+		return set(&zs.Source, ev.Input)
+	}
+	return false
+}
+
+// set sets *p to v, reporting whether that changed it.
+func set[T comparable](p *T, v T) bool {
+	if *p == v {
+		return false
+	}
+	*p = v
+	return true
+}
@@ -0,0 +1,344 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.google.com/p/go-avr/avr/heos"
+)
+
+// Capabilities describes what the amp's model supports. The Amp
+// detects them once it first connects and is on, and methods return
+// ErrUnsupported rather than send commands the model would ignore.
+// If detection fails, Known is false and nothing is refused.
+type Capabilities struct {
+	Known        bool
+	Model        string // such as "AVR-X2400H"
+	Brand        string // "Denon" or "Marantz", if known
+	FriendlyName string // the name set on the amp
+	MAC          net.HardwareAddr
+	Zones        int           // including the main zone
+	MaxVolume    float64       // the amp's volume limit in dB, or MaxVolume
+	Inputs       []InputSource // the main zone's sources; nil if unknown
+	HEOS         bool          // whether the amp has a HEOS CLI
+
+	// SpeakerPresets reports whether the amp has two speaker
+	// configuration presets; see SetSpeakerPreset.
+	SpeakerPresets bool
+
+	// LFC reports whether the amp has Audyssey Low Frequency
+	// Containment; see SetLFC.
+	LFC bool
+
+	// LoudnessManagement reports whether the amp has Dolby Loudness
+	// Management; see SetLoudnessManagement.
+	LoudnessManagement bool
+
+	// VideoProcessor reports whether the amp has a video processor
+	// with picture adjustments; see SetPictureMode.
+	VideoProcessor bool
+}
+
+// detectTimeout bounds capability detection.
+const detectTimeout = 20 * time.Second
+
+// probeTimeout is how long each query that detection probes a
+// feature with waits for an answer once written. Models without the
+// feature never answer.
+const probeTimeout = 2 * time.Second
+
+// Ports where models serve Deviceinfo.xml: 8080 on HEOS models, 80
+// on older ones.
+var deviceInfoPorts = []string{"8080", "80"}
+
+// networkSources are sources that Deviceinfo.xml doesn't list but
+// that every networked model accepts.
+var networkSources = []InputSource{
+	SourceNet, SourceNetUSB, SourceServer, SourceIRadio, SourceFavorites,
+	SourcePandora, SourceLastFM, SourceFlickr, SourceNapster, SourceRhapsody,
+}
+
+// zoneFollowsMain is the source of a zone playing the main zone's.
+const zoneFollowsMain InputSource = "SOURCE"
+
+// deviceInfoSources maps the source names of Deviceinfo.xml, in
+// upper case, that differ from their SI command names.
+var deviceInfoSources = map[string]InputSource{
+	"CBL/SAT":      SourceSatCbl,
+	"MEDIA PLAYER": SourceMediaPlay,
+	"BLU-RAY":      SourceBD,
+	"TV AUDIO":     SourceTV,
+	"BLUETOOTH":    SourceBluetooth,
+	"ONLINE MUSIC": SourceNet,
+	"AUX":          SourceAux1,
+}
+
+// WithCapabilities sets the amp's capabilities instead of detecting
+// them. Set Known to have methods refuse what c doesn't include.
+func WithCapabilities(c Capabilities) Option {
+	return func(a *Amp) {
+		if c.MaxVolume == 0 {
+			c.MaxVolume = MaxVolume
+		}
+		a.caps = c
+		a.capsDetecting = true // never start
+	}
+}
+
+// Capabilities returns the amp's capabilities as detected so far.
+func (a *Amp) Capabilities() Capabilities {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	c := a.caps
+	c.Inputs = slices.Clone(c.Inputs)
+	return c
+}
+
+// startDetect starts capability detection unless it has succeeded
+// or is under way.
+//
+// must be called with mu held
+func (a *Amp) startDetect() {
+	if a.capsDetecting {
+		return
+	}
+	a.capsDetecting = true
+	a.wg.Add(1)
+	go a.detect()
+}
+
+// detect queries the amp's model, zones, inputs, optional features
+// and HEOS support. If that fails, or leaves a feature unknown,
+// detection is retried on the next connect or power on. The model,
+// inputs and HEOS support are only looked up over TCP, on ports
+// other than the control port, if the Amp dials the amp itself.
+func (a *Amp) detect() {
+	defer a.wg.Done()
+	ctx, cancel := a.backgroundContext()
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, detectTimeout)
+	defer cancelTimeout()
+
+	var c Capabilities
+	if l, res, _ := a.probeQuery(ctx, "NSFRN ?", prefix("NSFRN "), probeTimeout); res == probeAnswered {
+		c.FriendlyName = strings.TrimSpace(l[len("NSFRN "):])
+	}
+	if a.dialsItself() {
+		if di, err := a.deviceInfo(ctx); err == nil {
+			di.fill(&c)
+		} else {
+			a.log.Debug("avr: capability detection failed", "err", err)
+		}
+	}
+	// A feature counts as missing only if the amp ignored its probe
+	// while on, as in standby it answers only probeSentinel. If the
+	// amp was off or too slow to tell, as just after power on, the
+	// feature counts as present, and detection runs again on the next
+	// connect or power on.
+	features := []struct {
+		p   string
+		has *bool
+	}{
+		{"SPPR ", &c.SpeakerPresets},
+		{"PSLFC ", &c.LFC},
+		{"PSLOM ", &c.LoudnessManagement},
+		{"PVPICT ", &c.VideoProcessor},
+	}
+	results := make([]probeResult, len(features))
+	for i, f := range features {
+		results[i] = a.probe(ctx, f.p)
+	}
+	if a.dialsItself() {
+		if hc, err := a.dialer.DialContext(ctx, "tcp", net.JoinHostPort(a.host(), strconv.Itoa(heos.DefaultPort))); err == nil {
+			hc.Close()
+			c.HEOS = true
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	decided := true
+	for i, f := range features {
+		ignored := results[i] == probeIgnored && a.cache.Power
+		*f.has = !ignored
+		decided = decided && (results[i] == probeAnswered || ignored)
+	}
+	c.MaxVolume = a.caps.MaxVolume // kept current from MVMAX lines
+	a.caps = c
+	if a.mac == nil {
+		a.mac = c.MAC
+	}
+	a.capsDetecting = c.Known && decided
+}
+
+// dialsItself reports whether the Amp dials the amp over TCP rather
+// than through WithDialer or a Transport, which may lead only to the
+// control port, or to a fake amp.
+func (a *Amp) dialsItself() bool {
+	return a.dial == nil && a.transport == nil
+}
+
+// probe queries parameter p, such as "SPPR ", to find out whether
+// the amp has it.
+func (a *Amp) probe(ctx context.Context, p string) probeResult {
+	_, res, _ := a.probeQuery(ctx, strings.TrimSpace(p)+" ?", prefix(p), probeTimeout)
+	return res
+}
+
+// updateMaxVolume records the volume limit from an MVMAX line.
+//
+// must be called with mu held
+func (a *Amp) updateMaxVolume(db float64) {
+	a.caps.MaxVolume = db
+}
+
+// host returns the amp's host name or address.
+func (a *Amp) host() string {
+	host, _, err := net.SplitHostPort(a.addr)
+	if err != nil {
+		return a.addr
+	}
+	return host
+}
+
+// deviceInfo fetches Deviceinfo.xml from the WithWebStatus URL, if
+// any, with its client, or else from each of deviceInfoPorts until
+// one answers, dialing as for the control connection.
+func (a *Amp) deviceInfo(ctx context.Context) (*deviceInfo, error) {
+	var err error
+	if a.webURL != "" {
+		var di *deviceInfo
+		if di, err = fetchDeviceInfo(ctx, a.webClient, a.webURL); err == nil {
+			return di, nil
+		}
+	}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext:       a.dialer.DialContext,
+		DisableKeepAlives: true,
+	}}
+	for _, port := range deviceInfoPorts {
+		var di *deviceInfo
+		if di, err = fetchDeviceInfo(ctx, client, "http://"+net.JoinHostPort(a.host(), port)); err == nil {
+			return di, nil
+		}
+	}
+	return nil, err
+}
+
+// deviceInfo is the part of Deviceinfo.xml used for Capabilities and
+// DeviceInfo.
+type deviceInfo struct {
+	BrandCode       string `xml:"BrandCode"`
+	ModelName       string `xml:"ModelName"`
+	ManualModelName string `xml:"ManualModelName"`
+	MacAddress      string `xml:"MacAddress"`
+	DeviceZones     int    `xml:"DeviceZones"`
+	UpgradeVersion  string `xml:"UpgradeVersion"`
+	Zones           []struct {
+		No      int      `xml:"Zone>No"`
+		Sources []string `xml:"InputSource>List>Source>FuncName"`
+	} `xml:"DeviceZoneCapabilities"`
+}
+
+func fetchDeviceInfo(ctx context.Context, client *http.Client, base string) (*deviceInfo, error) {
+	u := base + "/goform/Deviceinfo.xml"
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", u, res.Status)
+	}
+	di := new(deviceInfo)
+	if err := xml.NewDecoder(res.Body).Decode(di); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", u, err)
+	}
+	return di, nil
+}
+
+func (di *deviceInfo) fill(c *Capabilities) {
+	c.Known = true
+	c.Model = strings.TrimSpace(di.ManualModelName)
+	if c.Model == "" {
+		c.Model = strings.TrimPrefix(strings.TrimSpace(di.ModelName), "*")
+	}
+	switch strings.TrimSpace(di.BrandCode) {
+	case "0":
+		c.Brand = "Denon"
+	case "1":
+		c.Brand = "Marantz"
+	}
+	if mac, err := hex.DecodeString(strings.TrimSpace(di.MacAddress)); err == nil && len(mac) == 6 {
+		c.MAC = net.HardwareAddr(mac)
+	}
+	c.Zones = max(di.DeviceZones, 1)
+	for _, z := range di.Zones {
+		if z.No != 0 {
+			continue
+		}
+		for _, name := range z.Sources {
+			c.Inputs = append(c.Inputs, deviceInfoSource(name))
+		}
+	}
+}
+
+// deviceInfoSource returns the SI name of a Deviceinfo.xml source.
+func deviceInfoSource(name string) InputSource {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if src, ok := deviceInfoSources[name]; ok {
+		return src
+	}
+	return InputSource(name)
+}
+
+// checkZone returns ErrUnsupported if the amp is known to lack zone
+// n.
+func (a *Amp) checkZone(n int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.caps.Known && n > a.caps.Zones {
+		return fmt.Errorf("%w: zone %d", ErrUnsupported, n)
+	}
+	return nil
+}
+
+// checkInput returns ErrUnsupported if the amp is known to lack
+// source src.
+func (a *Amp) checkInput(src InputSource) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.caps.Known || a.caps.Inputs == nil {
+		return nil
+	}
+	if src == zoneFollowsMain || slices.Contains(a.caps.Inputs, src) || slices.Contains(networkSources, src) {
+		return nil
+	}
+	return fmt.Errorf("%w: input %s", ErrUnsupported, src)
+}
+
+// checkHEOS returns ErrUnsupported if the amp is known to have no
+// HEOS CLI.
+func (a *Amp) checkHEOS() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.caps.Known && !a.caps.HEOS {
+		return fmt.Errorf("%w: HEOS", ErrUnsupported)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// Speaker is a speaker channel name as used by the CV command.
+type Speaker string
+
+const (
+	SpeakerFrontLeft      Speaker = "FL"
+	SpeakerFrontRight     Speaker = "FR"
+	SpeakerCenter         Speaker = "C"
+	SpeakerSubwoofer      Speaker = "SW"
+	SpeakerSurroundLeft   Speaker = "SL"
+	SpeakerSurroundRight  Speaker = "SR"
+	SpeakerSurroundBack   Speaker = "SB"
+	SpeakerSurroundBackL  Speaker = "SBL"
+	SpeakerSurroundBackR  Speaker = "SBR"
+	SpeakerFrontHeightL   Speaker = "FHL"
+	SpeakerFrontHeightR   Speaker = "FHR"
+	SpeakerFrontWideLeft  Speaker = "FWL"
+	SpeakerFrontWideRight Speaker = "FWR"
+)
+
+// Channel level limits, in dB. The amp encodes channel levels like
+// the master volume but with 50 as 0dB.
+const (
+	MinChannelLevel = -12.0
+	MaxChannelLevel = 12.0
+
+	channelZeroLevel = 50
+)
+
+// channelListQuiet is how long a CV? query waits after the last
+// channel line in case the amp never sends CVEND.
+const channelListQuiet = 500 * time.Millisecond
+
+// SetChannelLevel trims the level of speaker ch to db, rounded to
+// the nearest half step.
+func (a *Amp) SetChannelLevel(ch Speaker, db float64) error {
+	if ch == "" || strings.Contains(string(ch), " ") {
+		return fmt.Errorf("invalid speaker %q", ch)
+	}
+	if math.IsNaN(db) || db < MinChannelLevel || db > MaxChannelLevel {
+		return fmt.Errorf("channel level %vdB out of range [%v, %v]", db, MinChannelLevel, MaxChannelLevel)
+	}
+	cmd := "CV" + string(ch) + " " + encodeLevel(db, channelZeroLevel)
+	return a.setConfirm(cmd, prefix("CV"+string(ch)+" "), cmd)
+}
+
+// ChannelLevel returns the level trim of speaker ch in dB.
+func (a *Amp) ChannelLevel(ch Speaker) (db float64, err error) {
+	levels, err := a.channelLevels()
+	if err != nil {
+		return 0, err
+	}
+	db, ok := levels[ch]
+	if !ok {
+		return 0, fmt.Errorf("speaker %q not configured", ch)
+	}
+	return db, nil
+}
+
+// Speakers returns the speakers in the amp's active configuration
+// along with their level trims in dB.
+func (a *Amp) Speakers() (map[Speaker]float64, error) {
+	return a.channelLevels()
+}
+
+// channelLevels issues CV?, to which the amp replies with one line
+// per configured speaker such as "CVFL 50", then "CVEND".
+func (a *Amp) channelLevels() (map[Speaker]float64, error) {
+	ctx, cancel := a.timeoutContext(context.Background())
+	defer cancel()
+	lines, err := a.queryLines(ctx, "CV?", prefix("CV"), is[proto.ChannelEnd], channelListQuiet)
+	if err != nil && len(lines) == 0 {
+		return nil, err
+	}
+	levels := make(map[Speaker]float64)
+	for _, l := range lines {
+		if cl, ok := proto.Parse(l).(proto.ChannelLevel); ok {
+			levels[Speaker(cl.Channel)] = cl.DB
+		}
+	}
+	return levels, nil
+}
@@ -0,0 +1,393 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A Config describes a household's receivers, as read by LoadConfig.
+// In YAML:
+//
+//	amps:
+//	  - name: den
+//	    addr: 192.168.1.20
+//	    mac: "00:05:cd:12:34:56"
+//	    volume_limit: -10
+//	    scenes:
+//	      movie:
+//	        - power: on
+//	          warm_up: 4s
+//	        - input: BD
+//	        - volume: -30
+//	          fade: 3s
+//	  - name: kitchen
+//	    addr: 192.168.1.21
+//	    brand: onkyo
+//
+// JSON uses the same keys.
+type Config struct {
+	Amps []AmpConfig `json:"amps"`
+}
+
+// An AmpConfig describes one receiver.
+type AmpConfig struct {
+	Name string `json:"name"`
+	Addr string `json:"addr"` // as for New, or the brand's New
+
+	// Brand is "denon", the default, or "marantz" for an Amp, or a
+	// brand registered with RegisterBrand, such as "onkyo" once the
+	// eiscp package is imported, in any case. The rest is for Amps
+	// only.
+	Brand string `json:"brand,omitempty"`
+
+	MAC         string                  `json:"mac,omitempty"`          // for Wake
+	VolumeLimit *float64                `json:"volume_limit,omitempty"` // WithVolumeLimit, in dB
+	Scenes      map[string][]StepConfig `json:"scenes,omitempty"`
+}
+
+// A StepConfig describes a scene step. It sets exactly one of Power,
+// Input, Volume, Mute, Surround, Command or Wait.
+type StepConfig struct {
+	Power    string       `json:"power,omitempty"`   // "on" or "off"
+	WarmUp   string       `json:"warm_up,omitempty"` // after power on, such as "4s"
+	Input    InputSource  `json:"input,omitempty"`
+	Volume   *float64     `json:"volume,omitempty"` // in dB
+	Fade     string       `json:"fade,omitempty"`   // fade to Volume over this long
+	Mute     *bool        `json:"mute,omitempty"`
+	Surround SurroundMode `json:"surround,omitempty"`
+	Command  string       `json:"command,omitempty"` // raw command
+	Wait     string       `json:"wait,omitempty"`    // such as "500ms"
+}
+
+// brands holds the constructors registered with RegisterBrand.
+var brands = struct {
+	sync.Mutex
+	m map[string]func(addr string) Receiver
+}{m: make(map[string]func(addr string) Receiver)}
+
+// RegisterBrand makes LoadConfig create receivers of brand name, in
+// any case, with open. Other brands' driver packages register
+// themselves.
+func RegisterBrand(name string, open func(addr string) Receiver) {
+	brands.Lock()
+	defer brands.Unlock()
+	brands.m[strings.ToLower(name)] = open
+}
+
+// LoadConfig reads the Config in file path, which is YAML unless its
+// name ends in ".json", and returns a Manager for it. The options
+// apply to each Amp.
+func LoadConfig(path string, opts ...Option) (*Manager, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := parseConfig(b, filepath.Ext(path) == ".json")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	m, err := NewManager(cfg, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// parseConfig parses a Config in JSON or YAML. YAML is converted to
+// JSON first so that both read the same keys and reject the same
+// unknown ones.
+func parseConfig(b []byte, isJSON bool) (*Config, error) {
+	if !isJSON {
+		var v any
+		if err := yaml.Unmarshal(b, &v); err != nil {
+			return nil, err
+		}
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	cfg := new(Config)
+	if err := d.Decode(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// A Manager holds the receivers of a Config by name. Each is created,
+// and so connects, only when first asked for, so that amps allowing
+// one control session at a time are left alone unless used.
+type Manager struct {
+	names []string // in the Config's order
+	addrs map[string]string
+	plans map[string]ampPlan
+
+	mu        sync.Mutex
+	receivers map[string]Receiver // those created so far
+}
+
+// NewManager checks cfg and returns a Manager for the receivers it
+// describes. The options apply to each Amp.
+func NewManager(cfg *Config, opts ...Option) (*Manager, error) {
+	m := &Manager{
+		addrs:     make(map[string]string),
+		plans:     make(map[string]ampPlan),
+		receivers: make(map[string]Receiver),
+	}
+	seen := make(map[string]bool)
+	for i, ac := range cfg.Amps {
+		switch {
+		case ac.Name == "":
+			return nil, fmt.Errorf("amp %d: missing name", i+1)
+		case seen[ac.Name]:
+			return nil, fmt.Errorf("amp %q: duplicate name", ac.Name)
+		case ac.Addr == "":
+			return nil, fmt.Errorf("amp %q: missing addr", ac.Name)
+		}
+		p, err := planAmp(ac, opts)
+		if err != nil {
+			return nil, fmt.Errorf("amp %q: %w", ac.Name, err)
+		}
+		seen[ac.Name] = true
+		m.names = append(m.names, ac.Name)
+		m.addrs[ac.Name] = ac.Addr
+		m.plans[ac.Name] = p
+	}
+	return m, nil
+}
+
+// ampPlan is how a Manager creates a receiver: with open, or as an
+// Amp with opts if open is nil.
+type ampPlan struct {
+	brand  string // never empty
+	open   func(addr string) Receiver
+	opts   []Option
+	scenes map[string]*Scene
+}
+
+// planAmp checks ac and returns how to create its receiver.
+func planAmp(ac AmpConfig, opts []Option) (ampPlan, error) {
+	brand := strings.ToLower(ac.Brand)
+	switch brand {
+	case "", "denon", "marantz":
+	default:
+		brands.Lock()
+		open := brands.m[brand]
+		brands.Unlock()
+		if open == nil {
+			return ampPlan{}, fmt.Errorf("unknown brand %q", ac.Brand)
+		}
+		if ac.MAC != "" || ac.VolumeLimit != nil || len(ac.Scenes) > 0 {
+			return ampPlan{}, errors.New("mac, volume_limit and scenes need a denon or marantz")
+		}
+		return ampPlan{brand: brand, open: open}, nil
+	}
+
+	p := ampPlan{brand: brand, opts: append([]Option(nil), opts...)}
+	if p.brand == "" {
+		p.brand = "denon"
+	}
+	if ac.MAC != "" {
+		mac, err := net.ParseMAC(ac.MAC)
+		if err != nil {
+			return ampPlan{}, err
+		}
+		p.opts = append(p.opts, WithMAC(mac))
+	}
+	if ac.VolumeLimit != nil {
+		p.opts = append(p.opts, WithVolumeLimit(*ac.VolumeLimit))
+	}
+	p.scenes = make(map[string]*Scene)
+	for name, scs := range ac.Scenes {
+		s := &Scene{Name: name}
+		for i, sc := range scs {
+			st, err := sc.step()
+			if err != nil {
+				return ampPlan{}, fmt.Errorf("scene %q: step %d: %w", name, i+1, err)
+			}
+			s.Steps = append(s.Steps, st)
+		}
+		p.scenes[name] = s
+	}
+	return p, nil
+}
+
+// step returns the Step sc describes.
+func (sc StepConfig) step() (Step, error) {
+	var steps []Step
+	add := func(st Step) { steps = append(steps, st) }
+	warmUp, err := parseDuration("warm_up", sc.WarmUp)
+	if err != nil {
+		return Step{}, err
+	}
+	fade, err := parseDuration("fade", sc.Fade)
+	if err != nil {
+		return Step{}, err
+	}
+	switch sc.Power {
+	case "":
+	case "on":
+		add(PowerOnStep(warmUp))
+	case "off":
+		add(PowerOffStep())
+	default:
+		return Step{}, fmt.Errorf("power %q: want on or off", sc.Power)
+	}
+	if sc.Input != "" {
+		add(SelectInputStep(sc.Input))
+	}
+	if sc.Volume != nil {
+		if _, err := encodeVolume(*sc.Volume); err != nil {
+			return Step{}, err
+		}
+		if fade > 0 {
+			add(FadeVolumeStep(*sc.Volume, fade))
+		} else {
+			add(SetVolumeStep(*sc.Volume))
+		}
+	}
+	if sc.Mute != nil {
+		add(MuteStep(*sc.Mute))
+	}
+	if sc.Surround != "" {
+		add(SetSurroundModeStep(sc.Surround))
+	}
+	if sc.Command != "" {
+		add(CommandStep(sc.Command))
+	}
+	if sc.Wait != "" {
+		d, err := parseDuration("wait", sc.Wait)
+		if err != nil {
+			return Step{}, err
+		}
+		add(WaitStep(d))
+	}
+	switch {
+	case len(steps) == 0:
+		return Step{}, errors.New("no action")
+	case len(steps) > 1:
+		return Step{}, errors.New("more than one action")
+	case warmUp > 0 && sc.Power != "on":
+		return Step{}, errors.New("warm_up needs power: on")
+	case fade > 0 && sc.Volume == nil:
+		return Step{}, errors.New("fade needs volume")
+	}
+	return steps[0], nil
+}
+
+// parseDuration parses the duration s of field key, if any.
+func parseDuration(key, s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", key, err)
+	}
+	return d, nil
+}
+
+// Names returns the names of the Manager's receivers, in the
+// Config's order.
+func (m *Manager) Names() []string {
+	return append([]string(nil), m.names...)
+}
+
+// Receiver returns the receiver with the given name, creating it on
+// first use, or nil.
+func (m *Manager) Receiver(name string) Receiver {
+	p, ok := m.plans[name]
+	if !ok {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.receivers[name]
+	if r == nil {
+		if p.open != nil {
+			r = p.open(m.addrs[name])
+		} else {
+			r = New(m.addrs[name], p.opts...)
+		}
+		m.receivers[name] = r
+	}
+	return r
+}
+
+// Brand returns the brand of the receiver with the given name, as
+// in its AmpConfig but in lower case, defaulting to "denon", or "" if
+// there is none.
+func (m *Manager) Brand(name string) string {
+	return m.plans[name].brand
+}
+
+// Amp returns the Amp with the given name, creating it on first use,
+// or nil if there is none or it is another brand's receiver.
+func (m *Manager) Amp(name string) *Amp {
+	if m.plans[name].open != nil {
+		return nil
+	}
+	a, _ := m.Receiver(name).(*Amp)
+	return a
+}
+
+// Scenes returns the names of the scenes of the named amp, sorted.
+func (m *Manager) Scenes(amp string) []string {
+	var names []string
+	for name := range m.plans[amp].scenes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RunScene runs the named scene of the named amp, after waiting for
+// the amp to connect.
+func (m *Manager) RunScene(ctx context.Context, amp, scene string) error {
+	a := m.Amp(amp)
+	if a == nil {
+		return fmt.Errorf("no amp %q", amp)
+	}
+	s := m.plans[amp].scenes[scene]
+	if s == nil {
+		return fmt.Errorf("amp %q has no scene %q", amp, scene)
+	}
+	if err := a.PingContext(ctx); err != nil {
+		return err
+	}
+	return a.RunScene(ctx, s)
+}
+
+// Close closes every receiver created so far.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var errs []error
+	for _, name := range m.names {
+		r := m.receivers[name]
+		if r == nil {
+			continue
+		}
+		if err := r.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,96 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+// ConnState is the state of an Amp's connection to the amp.
+type ConnState int
+
+const (
+	Disconnected ConnState = iota
+	Connecting
+	Connected
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case Disconnected:
+		return "disconnected"
+	case Connecting:
+		return "connecting"
+	case Connected:
+		return "connected"
+	}
+	return "unknown"
+}
+
+// A ConnChange is a transition of the connection state. Err is the
+// reason for a transition to Disconnected, and nil otherwise.
+type ConnChange struct {
+	State ConnState
+	Err   error
+}
+
+// ConnState returns the current connection state and, if
+// Disconnected, the error that caused it. Once the Amp is closed it
+// is Disconnected with ErrClosed. Like CachedState, it doesn't lock.
+func (a *Amp) ConnState() (ConnState, error) {
+	c := a.connSnap.Load()
+	if c == nil {
+		return Disconnected, nil
+	}
+	return c.State, c.Err
+}
+
+// setConnState sets the connection state and err, the error that
+// caused the latest disconnection.
+//
+// must be called with mu held
+func (a *Amp) setConnState(s ConnState, err error) {
+	a.state, a.err = s, err
+	c := &ConnChange{State: s}
+	if s == Disconnected {
+		c.Err = err
+	}
+	a.connSnap.Store(c)
+}
+
+// connWatchBuffer is the capacity of each WatchConnState channel.
+// Changes are dropped for watchers that fall this far behind.
+const connWatchBuffer = 16
+
+// WatchConnState returns a channel receiving each change of the
+// connection state. Call cancel to stop watching, which closes the
+// channel; Close also closes it.
+func (a *Amp) WatchConnState() (changes <-chan ConnChange, cancel func()) {
+	ch := make(chan ConnChange, connWatchBuffer)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if a.connWatchers == nil {
+		a.connWatchers = make(map[chan ConnChange]bool)
+	}
+	a.connWatchers[ch] = true
+	return ch, func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.connWatchers[ch] { // else already closed
+			delete(a.connWatchers, ch)
+			close(ch)
+		}
+	}
+}
+
+// must be called with mu held
+func (a *Amp) notifyConnState(err error) {
+	c := ConnChange{State: a.state, Err: err}
+	for ch := range a.connWatchers {
+		select {
+		case ch <- c:
+		default:
+		}
+	}
+}
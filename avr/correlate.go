@@ -0,0 +1,115 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"strings"
+)
+
+// Query sends q to the amp and returns the amp's reply, without the
+// trailing carriage return.
+//
+// q is normally a status request such as "MV?" or "PSDYNEQ ?", and
+// the reply is the first line beginning with q minus its "?" and
+// trailing spaces; for "MV?" that is "MV45" but not "MVMAX 98". Any
+// other q is answered by the first line that equals q, as when the
+// amp echoes a command.
+//
+// If ctx has no deadline, Query gives up after the command timeout.
+// Errors caused by a deadline match ErrTimeout.
+func (a *Amp) Query(ctx context.Context, q string) (string, error) {
+	if err := a.checkStrict(q); err != nil {
+		return "", err
+	}
+	ctx, cancel := a.commandContext(ctx, q)
+	defer cancel()
+	l, err := a.query(ctx, q, replyMatch(q))
+	return l, timeoutErr(err)
+}
+
+// replyMatchers are the match funcs for queries whose reply prefix
+// is shared with other, unrelated lines.
+var replyMatchers = map[string]func(string) bool{
+	"MV":   isVolumeLine,
+	"MS":   isSurroundLine,
+	"TFAN": isFrequencyLine,
+	"VSSC": isScalerLine,
+}
+
+// replyMatch returns the match func for the reply to q.
+func replyMatch(q string) func(string) bool {
+	q = strings.TrimSuffix(q, "\r")
+	if !strings.HasSuffix(q, "?") {
+		return func(l string) bool { return l == q }
+	}
+	p := strings.TrimRight(strings.TrimSuffix(q, "?"), " ")
+	if m, ok := replyMatchers[p]; ok {
+		return m
+	}
+	return prefix(p)
+}
+
+// pending tracks the queries awaiting replies from the amp. It is
+// only used by the loop goroutine.
+type pending struct {
+	reqs []request                  // queryCmd requests, oldest first
+	done func(r request, err error) // called as each query is answered or fails, if non-nil
+}
+
+func (p *pending) add(req request) {
+	p.reqs = append(p.reqs, req)
+}
+
+// dispatch answers the oldest query matching line l, and every
+// matching multi-line query, dropping queries whose callers have
+// given up. It reports whether any query was answered.
+func (p *pending) dispatch(l string) (answered bool) {
+	kept := p.reqs[:0]
+	taken := false // by a single-line query
+	for _, r := range p.reqs {
+		switch {
+		case r.ctx.Err() != nil:
+			// Caller is gone.
+		case r.multi:
+			if r.match(l) {
+				select {
+				case r.ch <- &response{line: l}:
+				default:
+					// Caller is behind; drop the line.
+				}
+				answered = true
+			}
+			kept = append(kept, r)
+		case !taken && r.match(l):
+			r.ch <- &response{line: l}
+			taken, answered = true, true
+			p.finished(r, nil)
+		default:
+			kept = append(kept, r)
+		}
+	}
+	p.reqs = kept
+	return answered
+}
+
+// fail answers every pending query with err.
+func (p *pending) fail(err error) {
+	for _, r := range p.reqs {
+		select {
+		case r.ch <- &response{err: err}:
+		default:
+		}
+		if r.ctx.Err() == nil {
+			p.finished(r, err)
+		}
+	}
+	p.reqs = nil
+}
+
+func (p *pending) finished(r request, err error) {
+	if p.done != nil {
+		p.done(r, err)
+	}
+}
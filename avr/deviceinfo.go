@@ -0,0 +1,91 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// DeviceInfo identifies an amp, for keeping an inventory of them.
+// Fields the amp doesn't report are empty.
+type DeviceInfo struct {
+	Model        string // such as "AVR-X2400H"
+	Brand        string // "Denon" or "Marantz"
+	FriendlyName string
+	Firmware     string // the firmware version
+	MAC          net.HardwareAddr
+	IP           net.IP // the address the Amp connects to
+}
+
+// modelQueryTimeout bounds the model query, which older models
+// don't answer.
+const modelQueryTimeout = time.Second
+
+// DeviceInfo asks the amp for its model, friendly name, firmware
+// version and Ethernet address, over the control connection with
+// the NSFRN and SYMODEL queries and from its Deviceinfo.xml web
+// page, and resolves its IP address. It fails only if none of these
+// answer.
+func (a *Amp) DeviceInfo(ctx context.Context) (*DeviceInfo, error) {
+	ctx, cancel := a.timeoutContext(ctx)
+	defer cancel()
+	d := new(DeviceInfo)
+	answered := false
+	l, errName := a.query(ctx, "NSFRN ?", prefix("NSFRN "))
+	if errName == nil {
+		d.FriendlyName = strings.TrimSpace(l[len("NSFRN "):])
+		answered = true
+	}
+	qctx, qcancel := context.WithTimeout(ctx, modelQueryTimeout)
+	if l, err := a.query(qctx, "SYMODEL ?", prefix("SYMODEL ")); err == nil {
+		d.Model = strings.TrimSpace(l[len("SYMODEL "):])
+		answered = true
+	}
+	qcancel()
+	di, errWeb := a.deviceInfo(ctx)
+	if errWeb == nil {
+		var c Capabilities
+		di.fill(&c)
+		if c.Model != "" {
+			d.Model = c.Model
+		}
+		d.Brand = c.Brand
+		d.MAC = c.MAC
+		d.Firmware = strings.TrimSpace(di.UpgradeVersion)
+		answered = true
+	}
+	if !answered {
+		return nil, timeoutErr(errors.Join(errName, errWeb))
+	}
+	d.IP = a.remoteIP(ctx)
+	return d, nil
+}
+
+// remoteIP returns the IP address of the amp's connection, or else
+// of its host, or nil.
+func (a *Amp) remoteIP(ctx context.Context) net.IP {
+	a.mu.Lock()
+	c := a.conn
+	a.mu.Unlock()
+	if c != nil {
+		if nc, ok := c.c.(addrConn); ok {
+			if ta, ok := nc.RemoteAddr().(*net.TCPAddr); ok {
+				return ta.IP
+			}
+		}
+	}
+	host := a.host()
+	if ip := net.ParseIP(host); ip != nil {
+		return ip
+	}
+	ips, err := a.dialer.Resolver.LookupIP(ctx, "ip", host)
+	if err != nil || len(ips) == 0 {
+		return nil
+	}
+	return ips[0]
+}
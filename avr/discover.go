@@ -0,0 +1,294 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A DiscoveredAmp is a receiver found on the local network.
+type DiscoveredAmp struct {
+	Addr         string // control address, such as host:23 for New
+	FriendlyName string // the name set on the amp, such as "Living Room"
+	Manufacturer string // such as "Denon"
+	ModelName    string // such as "AVR-X2400H"
+	Serial       string
+	MAC          net.HardwareAddr // nil if unknown; for WithMAC
+	Location     string           // URL of the UPnP device description
+}
+
+// Discovery settings.
+const (
+	ssdpAddr         = "239.255.255.250:1900"
+	ssdpTarget       = "urn:schemas-upnp-org:device:MediaRenderer:1"
+	controlPort      = "23"
+	defaultDiscovery = 3 * time.Second // if ctx has no deadline
+)
+
+// Discover searches the local network for Denon, Marantz, Yamaha
+// and Pioneer receivers, both with an SSDP M-SEARCH for UPnP media renderers and
+// with mDNS queries for their AirPlay and HEOS services. Receivers
+// found both ways are returned once, matched by Ethernet address or
+// else by address. Discover waits for answers until ctx is done, or
+// for three seconds if ctx has no deadline. It fails only if both
+// searches fail.
+//
+// Yamaha and Pioneer receivers are only found by SSDP. Their Addr is
+// their own control port, for yamaha.New or pioneer.New rather than
+// New; check Manufacturer before choosing a driver.
+func Discover(ctx context.Context) ([]DiscoveredAmp, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultDiscovery)
+		defer cancel()
+	}
+	var (
+		wg               sync.WaitGroup
+		ssdpAmps, mdAmps []DiscoveredAmp
+		ssdpErr, mdnsErr error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		ssdpAmps, ssdpErr = discoverSSDP(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		mdAmps, mdnsErr = mdnsSearch(ctx)
+	}()
+	wg.Wait()
+	if ssdpErr != nil && mdnsErr != nil {
+		return nil, ssdpErr
+	}
+	return mergeDiscovered(ssdpAmps, mdAmps), nil
+}
+
+// mergeDiscovered returns the amps in a and b, with each amp in b
+// that matches one already seen filling in only its missing fields.
+func mergeDiscovered(a, b []DiscoveredAmp) []DiscoveredAmp {
+	var all []DiscoveredAmp
+	find := func(d *DiscoveredAmp) *DiscoveredAmp {
+		for i := range all {
+			o := &all[i]
+			if d.MAC != nil && o.MAC != nil {
+				if bytes.Equal(d.MAC, o.MAC) {
+					return o
+				}
+				continue
+			}
+			if d.Addr == o.Addr {
+				return o
+			}
+		}
+		return nil
+	}
+	for _, d := range append(a, b...) {
+		o := find(&d)
+		if o == nil {
+			all = append(all, d)
+			continue
+		}
+		fill(&o.FriendlyName, d.FriendlyName)
+		fill(&o.Manufacturer, d.Manufacturer)
+		fill(&o.ModelName, d.ModelName)
+		fill(&o.Serial, d.Serial)
+		fill(&o.Location, d.Location)
+		if o.MAC == nil {
+			o.MAC = d.MAC
+		}
+	}
+	return all
+}
+
+func fill(dst *string, v string) {
+	if *dst == "" {
+		*dst = v
+	}
+}
+
+// discoverSSDP finds receivers by SSDP and fetches each one's device
+// description.
+func discoverSSDP(ctx context.Context) ([]DiscoveredAmp, error) {
+	locs, err := ssdpSearch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The search used up ctx; give the description fetches their
+	// own short deadline.
+	fctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), DefaultCommandTimeout)
+	defer cancel()
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		amps []DiscoveredAmp
+	)
+	for _, loc := range locs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d, err := fetchDescription(fctx, loc)
+			if err != nil || d.Addr == "" {
+				return
+			}
+			mu.Lock()
+			amps = append(amps, *d)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return amps, nil
+}
+
+// ssdpSearch multicasts an M-SEARCH and returns the distinct
+// LOCATION headers of the answers received until ctx is done.
+func ssdpSearch(ctx context.Context) ([]string, error) {
+	c, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	dst, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return nil, err
+	}
+	msg := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddr + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n" +
+		"ST: " + ssdpTarget + "\r\n\r\n"
+	// UDP is lossy, so send the search twice.
+	for range 2 {
+		if _, err := c.WriteTo([]byte(msg), dst); err != nil {
+			return nil, err
+		}
+	}
+
+	stop := context.AfterFunc(ctx, func() { c.SetReadDeadline(time.Now()) })
+	defer stop()
+	seen := make(map[string]bool)
+	var locs []string
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := c.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return locs, nil
+			}
+			return nil, err
+		}
+		loc := ssdpLocation(buf[:n])
+		if loc != "" && !seen[loc] {
+			seen[loc] = true
+			locs = append(locs, loc)
+		}
+	}
+}
+
+// ssdpLocation returns the LOCATION header of an SSDP response, or
+// "" if it has none.
+func ssdpLocation(b []byte) string {
+	res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(b)), nil)
+	if err != nil {
+		return ""
+	}
+	res.Body.Close()
+	return res.Header.Get("Location")
+}
+
+type deviceDescription struct {
+	Device struct {
+		FriendlyName string `xml:"friendlyName"`
+		Manufacturer string `xml:"manufacturer"`
+		ModelName    string `xml:"modelName"`
+		SerialNumber string `xml:"serialNumber"`
+		UDN          string `xml:"UDN"`
+	} `xml:"device"`
+}
+
+// fetchDescription fetches and parses the UPnP device description
+// at loc.
+func fetchDescription(ctx context.Context, loc string) (*DiscoveredAmp, error) {
+	u, err := url.Parse(loc)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", loc, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", loc, res.Status)
+	}
+	var dd deviceDescription
+	if err := xml.NewDecoder(res.Body).Decode(&dd); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", loc, err)
+	}
+	d := dd.Device
+	addr := ""
+	if port, ok := makerPort(d.Manufacturer); ok {
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+	return &DiscoveredAmp{
+		Addr:         addr,
+		FriendlyName: strings.TrimSpace(d.FriendlyName),
+		Manufacturer: strings.TrimSpace(d.Manufacturer),
+		ModelName:    strings.TrimSpace(d.ModelName),
+		Serial:       strings.TrimSpace(d.SerialNumber),
+		MAC:          udnMAC(d.UDN),
+		Location:     loc,
+	}, nil
+}
+
+// udnMAC returns the Ethernet address at the end of a UPnP UDN such
+// as "uuid:5f9ec1b3-ed59-1900-4530-0005cd123456", as Denon and Marantz
+// amps use, or nil if the UDN doesn't end in one.
+func udnMAC(udn string) net.HardwareAddr {
+	i := strings.LastIndexByte(udn, '-')
+	if i < 0 || len(udn)-i-1 != 12 {
+		return nil
+	}
+	mac, err := hex.DecodeString(udn[i+1:])
+	if err != nil {
+		return nil
+	}
+	return net.HardwareAddr(mac)
+}
+
+// makerPorts maps the manufacturers whose receivers Discover
+// returns, in lower case, to their receivers' control ports.
+var makerPorts = map[string]string{
+	"denon":   controlPort,
+	"marantz": controlPort,
+	"yamaha":  "50000", // YNCA
+	"pioneer": "8102",
+}
+
+// makerPort returns the control port of receivers made by
+// manufacturer, and whether Discover returns them.
+func makerPort(manufacturer string) (port string, ok bool) {
+	m := strings.ToLower(manufacturer)
+	for name, port := range makerPorts {
+		if strings.Contains(m, name) {
+			return port, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,37 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import "fmt"
+
+// Brightness is a front panel display brightness.
+type Brightness string
+
+const (
+	BrightnessBright Brightness = "BRI"
+	BrightnessDim    Brightness = "DIM"
+	BrightnessDark   Brightness = "DAR"
+	BrightnessOff    Brightness = "OFF"
+)
+
+// SetDisplayBrightness sets the front panel display brightness.
+func (a *Amp) SetDisplayBrightness(level Brightness) error {
+	switch level {
+	case BrightnessBright, BrightnessDim, BrightnessDark, BrightnessOff:
+	default:
+		return fmt.Errorf("invalid display brightness %q", level)
+	}
+	return a.setParam("DIM ", string(level))
+}
+
+// DisplayBrightness returns the front panel display brightness.
+func (a *Amp) DisplayBrightness() (Brightness, error) {
+	v, err := a.param("DIM ")
+	return Brightness(v), err
+}
+
+// LockPanel locks or unlocks the front panel buttons.
+func (a *Amp) LockPanel(lock bool) error {
+	return a.setOnOff("SYPANEL LOCK ", lock)
+}
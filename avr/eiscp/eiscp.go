@@ -0,0 +1,354 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+// Package eiscp controls Onkyo and Integra receivers over eISCP, the
+// Integra Serial Control Protocol carried over TCP. A Receiver
+// implements avr.Receiver, so code written against that interface
+// works with Denon, Marantz, Onkyo and Integra amps alike.
+package eiscp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.google.com/p/go-avr/avr"
+	"code.google.com/p/go-avr/avr/internal/link"
+	"code.google.com/p/go-avr/avr/internal/pace"
+)
+
+// DefaultPort is the TCP port receivers serve eISCP on.
+const DefaultPort = 60128
+
+// DefaultTimeout is how long commands wait for the receiver to
+// answer.
+const DefaultTimeout = 5 * time.Second
+
+// ErrNotAvailable means the receiver answered "N/A": the command
+// isn't available in its current state or on its model.
+var ErrNotAvailable = errors.New("not available")
+
+// A Receiver is an Onkyo or Integra receiver. It connects on first
+// use and reconnects after the connection breaks. It is safe for
+// concurrent use.
+type Receiver struct {
+	c *link.Client
+}
+
+// The package registers brands "onkyo" and "integra" for avr.LoadConfig.
+func init() {
+	open := func(addr string) avr.Receiver { return New(addr) }
+	avr.RegisterBrand("onkyo", open)
+	avr.RegisterBrand("integra", open)
+}
+
+// New returns a Receiver for the receiver at addr, which defaults to
+// DefaultPort if it has no port.
+func New(addr string) *Receiver {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, strconv.Itoa(DefaultPort))
+	}
+	return &Receiver{c: link.New(addr, DefaultTimeout, protocol)}
+}
+
+// protocol is eISCP for link.Client.
+var protocol = link.Protocol{
+	Write: func(w io.Writer, cmd string) error {
+		_, err := w.Write(Encode(cmd))
+		return err
+	},
+	Read:  func(r *bufio.Reader) (string, error) { return ReadMessage(r) },
+	Split: split,
+	Event: event,
+	Gaps: pace.Gaps{
+		Min: avr.DefaultCommandGap,
+		// The receiver ignores commands while powering on.
+		Prefix: map[string]time.Duration{"PWR01": time.Second},
+	},
+}
+
+// split splits an ISCP message into its three letter parameter and
+// its value.
+func split(msg string) (p, v string, ok bool) {
+	if len(msg) < 3 {
+		return "", "", false
+	}
+	return msg[:3], msg[3:], true
+}
+
+// Close closes the connection and every subscription channel.
+func (r *Receiver) Close() error { return r.c.Close() }
+
+// Volume levels. Receivers show volume from 0 to 100; with their
+// display set to relative, level 82 is shown as 0dB.
+const (
+	levelZero = 82
+	maxLevel  = 100
+)
+
+// PowerOn turns the receiver on.
+func (r *Receiver) PowerOn() error { return r.set("PWR01") }
+
+// PowerOff puts the receiver in standby.
+func (r *Receiver) PowerOff() error { return r.set("PWR00") }
+
+// PowerState reports whether the receiver is on.
+func (r *Receiver) PowerState() (on bool, err error) {
+	v, err := r.query("PWR")
+	return v == "01", err
+}
+
+// SetVolume sets the master volume to db, rounded to a whole level.
+func (r *Receiver) SetVolume(db float64) error {
+	level := int(math.Round(db)) + levelZero
+	if db <= avr.MinVolume {
+		level = 0
+	}
+	if level < 0 || level > maxLevel {
+		return fmt.Errorf("volume %vdB out of range [%v, %v]", db, avr.MinVolume, maxLevel-levelZero)
+	}
+	return r.set(fmt.Sprintf("MVL%02X", level))
+}
+
+// GetVolume returns the master volume in dB.
+func (r *Receiver) GetVolume() (db float64, err error) {
+	v, err := r.query("MVL")
+	if err != nil {
+		return 0, err
+	}
+	return parseVolume(v)
+}
+
+// VolumeUp raises the master volume by one level.
+func (r *Receiver) VolumeUp() error { return r.set("MVLUP") }
+
+// VolumeDown lowers the master volume by one level.
+func (r *Receiver) VolumeDown() error { return r.set("MVLDOWN") }
+
+// Mute mutes or unmutes the receiver.
+func (r *Receiver) Mute(on bool) error {
+	if on {
+		return r.set("AMT01")
+	}
+	return r.set("AMT00")
+}
+
+// IsMuted reports whether the receiver is muted.
+func (r *Receiver) IsMuted() (bool, error) {
+	v, err := r.query("AMT")
+	return v == "01", err
+}
+
+// SelectInput switches to src. Sources without an eISCP equivalent
+// return avr.ErrUnsupported.
+func (r *Receiver) SelectInput(src avr.InputSource) error {
+	code, ok := selectorCodes[src]
+	if !ok {
+		return fmt.Errorf("input %s: %w", src, avr.ErrUnsupported)
+	}
+	return r.set("SLI" + code)
+}
+
+// CurrentInput returns the selected source.
+func (r *Receiver) CurrentInput() (avr.InputSource, error) {
+	v, err := r.query("SLI")
+	if err != nil {
+		return "", err
+	}
+	return inputSource(v), nil
+}
+
+// Subscribe implements avr.Receiver. Typed events are sent when the
+// receiver reports a change; messages that aren't power, volume,
+// mute or input reports are sent as avr.RawLine. Subscribe
+// connects to the receiver if needed.
+func (r *Receiver) Subscribe() (events <-chan avr.Event, cancel func()) {
+	return r.c.Subscribe()
+}
+
+// SendRaw implements avr.Receiver. cmd is an ISCP message such as
+// "PWR01"; SendRaw adds the eISCP packet framing.
+func (r *Receiver) SendRaw(ctx context.Context, cmd string) error {
+	return r.c.Send(ctx, cmd)
+}
+
+// QueryRaw implements avr.Receiver. cmd is an ISCP message such as
+// "PWRQSTN", and the reply is the first message whose three letter
+// parameter is cmd's, such as "PWR01".
+func (r *Receiver) QueryRaw(ctx context.Context, cmd string) (string, error) {
+	if len(cmd) < 3 {
+		return "", fmt.Errorf("bad ISCP message %q", cmd)
+	}
+	return r.c.Query(ctx, cmd, cmd[:3])
+}
+
+// set sends cmd and waits for the receiver to report the resulting
+// state of cmd's parameter.
+func (r *Receiver) set(cmd string) error {
+	_, err := r.roundTrip(cmd, cmd[:3])
+	return err
+}
+
+// query asks for parameter p, such as "PWR", and returns the value
+// the receiver reports.
+func (r *Receiver) query(p string) (string, error) {
+	return r.roundTrip(p+"QSTN", p)
+}
+
+// roundTrip sends cmd and returns the value of the first message
+// for parameter p that arrives.
+func (r *Receiver) roundTrip(cmd, p string) (string, error) {
+	v, err := r.c.RoundTrip(cmd, p)
+	if err == nil && v == "N/A" {
+		return "", fmt.Errorf("%s: %w", cmd, ErrNotAvailable)
+	}
+	return v, err
+}
+
+// event returns the event for a message with parameter p and value
+// v, or nil.
+func event(p, v string) avr.Event {
+	switch p {
+	case "PWR":
+		if v == "00" || v == "01" {
+			return avr.PowerChanged{On: v == "01"}
+		}
+	case "AMT":
+		if v == "00" || v == "01" {
+			return avr.MuteChanged{Muted: v == "01"}
+		}
+	case "MVL":
+		if db, err := parseVolume(v); err == nil {
+			return avr.VolumeChanged{Volume: db}
+		}
+	case "SLI":
+		if v != "N/A" {
+			return avr.InputChanged{Input: inputSource(v)}
+		}
+	}
+	return nil
+}
+
+// parseVolume parses an MVL value, a hexadecimal level, into dB.
+func parseVolume(v string) (float64, error) {
+	n, err := strconv.ParseUint(v, 16, 8)
+	if err != nil || n > maxLevel {
+		return 0, fmt.Errorf("invalid volume %q", v)
+	}
+	if n == 0 {
+		return avr.MinVolume, nil
+	}
+	return float64(int(n) - levelZero), nil
+}
+
+// selectorCodes maps input sources to SLI codes.
+var selectorCodes = map[avr.InputSource]string{
+	avr.SourceDVR:       "00",
+	avr.SourceSatCbl:    "01",
+	avr.SourceSat:       "01",
+	avr.SourceGame:      "02",
+	avr.SourceAux1:      "03",
+	avr.SourceAux2:      "04",
+	avr.SourceBD:        "10",
+	avr.SourceDVD:       "10",
+	avr.SourceTV:        "12",
+	avr.SourcePhono:     "22",
+	avr.SourceCD:        "23",
+	avr.SourceTuner:     "24",
+	avr.SourceNet:       "2B",
+	avr.SourceNetUSB:    "2B",
+	avr.SourceUSB:       "29",
+	avr.SourceBluetooth: "2E",
+}
+
+// selectorSources maps SLI codes to the input sources reported for
+// them.
+var selectorSources = map[string]avr.InputSource{
+	"00": avr.SourceDVR,
+	"01": avr.SourceSatCbl,
+	"02": avr.SourceGame,
+	"03": avr.SourceAux1,
+	"04": avr.SourceAux2,
+	"10": avr.SourceBD,
+	"12": avr.SourceTV,
+	"22": avr.SourcePhono,
+	"23": avr.SourceCD,
+	"24": avr.SourceTuner,
+	"25": avr.SourceTuner,
+	"26": avr.SourceTuner,
+	"29": avr.SourceUSB,
+	"2B": avr.SourceNet,
+	"2C": avr.SourceUSB,
+	"2E": avr.SourceBluetooth,
+}
+
+// inputSource returns the input source for SLI code v; codes
+// without one are returned as "SLI" followed by the code.
+func inputSource(v string) avr.InputSource {
+	if src, ok := selectorSources[v]; ok {
+		return src
+	}
+	return avr.InputSource("SLI" + v)
+}
+
+// The eISCP packet header: "ISCP", the header size and the message
+// size, both big-endian, the version, and three reserved bytes.
+const (
+	headerSize = 16
+	version    = 1
+)
+
+// maxMessageSize bounds the messages ReadMessage accepts.
+const maxMessageSize = 64 << 10
+
+// Encode returns the eISCP packet carrying ISCP message msg, such as
+// "PWR01", to a receiver.
+func Encode(msg string) []byte {
+	data := "!1" + msg + "\r"
+	b := make([]byte, headerSize, headerSize+len(data))
+	copy(b, "ISCP")
+	binary.BigEndian.PutUint32(b[4:], headerSize)
+	binary.BigEndian.PutUint32(b[8:], uint32(len(data)))
+	b[12] = version
+	return append(b, data...)
+}
+
+// ReadMessage reads an eISCP packet from r and returns its ISCP
+// message without the "!1" start and the end terminator.
+func ReadMessage(r io.Reader) (string, error) {
+	var h [headerSize]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return "", err
+	}
+	if string(h[:4]) != "ISCP" {
+		return "", errors.New("bad eISCP packet: no ISCP signature")
+	}
+	hsize := binary.BigEndian.Uint32(h[4:])
+	dsize := binary.BigEndian.Uint32(h[8:])
+	if hsize < headerSize || dsize > maxMessageSize {
+		return "", fmt.Errorf("bad eISCP packet sizes %d, %d", hsize, dsize)
+	}
+	if _, err := io.CopyN(io.Discard, r, int64(hsize-headerSize)); err != nil {
+		return "", err
+	}
+	data := make([]byte, dsize)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", err
+	}
+	data = bytes.TrimRight(data, "\x1a\r\n")
+	if len(data) < 2 || data[0] != '!' {
+		return "", fmt.Errorf("bad ISCP message %q", data)
+	}
+	return strings.TrimSpace(string(data[2:])), nil
+}
+
+var _ avr.Receiver = (*Receiver)(nil)
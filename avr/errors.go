@@ -0,0 +1,78 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Errors returned by the Amp. Where there is an underlying cause,
+// such as the network error that broke the connection, the returned
+// error wraps both it and one of these, so use errors.Is to test
+// for them.
+var (
+	// ErrNotConnected means the Amp has no connection to the amp.
+	ErrNotConnected = errors.New("not connected")
+
+	// ErrClosed means the Amp was closed.
+	ErrClosed = errors.New("amp closed")
+
+	// ErrTimeout means the amp didn't answer in time.
+	ErrTimeout = errors.New("timeout waiting for amp")
+
+	// ErrUnsupported means the amp's model doesn't support the
+	// request; see Capabilities.
+	ErrUnsupported = errors.New("not supported by this amp model")
+
+	// ErrVolumeLimited means a volume change was capped at the
+	// limit set by WithVolumeLimit.
+	ErrVolumeLimited = errors.New("volume limited")
+
+	// ErrSuperseded means a command was dropped from the queue,
+	// unsent, in favor of a later one setting the same volume.
+	ErrSuperseded = errors.New("superseded by a later command")
+
+	// ErrBusy means too many commands are waiting to be sent.
+	ErrBusy = errors.New("amp busy")
+
+	// ErrSilent means the connection was dropped because the amp
+	// sent nothing for the WithHeartbeat maximum silence.
+	ErrSilent = errors.New("amp silent too long")
+
+	// ErrReadOnly means a command that could change the amp's
+	// state was refused because the Amp was created WithReadOnly.
+	ErrReadOnly = errors.New("amp is read-only")
+
+	// ErrInvalidCommand means a raw command was refused because
+	// the amp would not accept it; see Amp.Validate.
+	ErrInvalidCommand = errors.New("invalid command")
+
+	// ErrSessionTakenOver means the amp ended the connection soon
+	// after the Amp connected, as it does when another client
+	// connects; see WithReclaim.
+	ErrSessionTakenOver = errors.New("session taken over by another client")
+
+	// ErrNoAck is returned by SendCommand when WithAck is in effect
+	// and the amp never acknowledged the command.
+	ErrNoAck = errors.New("no acknowledgement from amp")
+)
+
+// timeoutErr wraps err with ErrTimeout if it was caused by a
+// deadline.
+func timeoutErr(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, ErrTimeout) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return err
+}
+
+// connErr returns the error from the last connection attempt, if
+// any.
+func (a *Amp) connErr() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
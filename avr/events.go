@@ -0,0 +1,287 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"time"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// An Event is something the amp reported. Its concrete type is one
+// of the types below.
+type Event interface {
+	event()
+}
+
+// VolumeChanged reports a new master volume.
+type VolumeChanged struct {
+	Volume float64 // in dB
+}
+
+// PowerChanged reports the amp powering on or entering standby.
+type PowerChanged struct {
+	On bool
+}
+
+// InputChanged reports a new input source.
+type InputChanged struct {
+	Input InputSource
+}
+
+// MuteChanged reports muting or unmuting.
+type MuteChanged struct {
+	Muted bool
+}
+
+// SurroundModeChanged reports a new surround mode.
+type SurroundModeChanged struct {
+	Mode SurroundMode
+}
+
+// ZoneEvent reports a change to zone 2 or 3. Event is a
+// VolumeChanged, PowerChanged, InputChanged or MuteChanged.
+type ZoneEvent struct {
+	Zone  int
+	Event Event
+}
+
+// NowPlayingChanged reports a change to what the network audio
+// display shows.
+type NowPlayingChanged struct {
+	NowPlaying NowPlaying
+}
+
+// SignalChanged reports a change to the main zone's input signal.
+type SignalChanged struct {
+	Signal SignalInfo
+}
+
+// HeadphonesChanged reports headphones being plugged into or
+// unplugged from the amp.
+type HeadphonesChanged struct {
+	Connected bool
+}
+
+// QuickSelectRecalled reports Quick Select memory N being recalled,
+// from the front panel, the remote or a command. It is sent each time
+// the amp reports the recall, even of the memory already active.
+type QuickSelectRecalled struct {
+	N int
+}
+
+// AllZoneStereoChanged reports the amp entering or leaving All Zone
+// Stereo mode. It is sent each time the amp reports the mode, as the
+// Amp doesn't cache it.
+type AllZoneStereoChanged struct {
+	On bool
+}
+
+// UpdateStatus reports the progress of a firmware update. The amp
+// restarts after installing an update, dropping the connection, so
+// a successful update ends with UpdateFinished, the Amp reconnecting,
+// then UpdateRestarted.
+type UpdateStatus struct {
+	State    UpdateState
+	Progress int // percent installed, while State is UpdateInstalling
+}
+
+// SessionTakenOver reports that the amp ended the Amp's control
+// session, as when another client such as the mobile app connected.
+// ReclaimAt is when the Amp will reconnect WithReclaim, and zero
+// otherwise.
+type SessionTakenOver struct {
+	ReclaimAt time.Time
+}
+
+// Resynced reports that the Amp reconnected to the amp and queried
+// its state afresh, as the amp may have changed, or rebooted, while
+// the connection was down. Subscribers should replace whatever they
+// derived from earlier events with Status. Status.Web is not filled
+// in.
+type Resynced struct {
+	Status Status
+}
+
+// Overflow reports that the Amp dropped Dropped events because the
+// subscriber fell behind. It is sent only to that subscriber, which
+// may want to call Status to catch up.
+type Overflow struct {
+	Dropped int
+}
+
+// RawLine is a line from the amp that isn't parsed into another
+// Event type.
+type RawLine struct {
+	Line string
+}
+
+func (VolumeChanged) event()        {}
+func (PowerChanged) event()         {}
+func (InputChanged) event()         {}
+func (MuteChanged) event()          {}
+func (SurroundModeChanged) event()  {}
+func (ZoneEvent) event()            {}
+func (NowPlayingChanged) event()    {}
+func (SignalChanged) event()        {}
+func (HeadphonesChanged) event()    {}
+func (QuickSelectRecalled) event()  {}
+func (AllZoneStereoChanged) event() {}
+func (UpdateStatus) event()         {}
+func (SessionTakenOver) event()     {}
+func (Resynced) event()             {}
+func (Overflow) event()             {}
+func (RawLine) event()              {}
+
+// eventBuffer is the capacity of each Subscribe channel. Events are
+// dropped for subscribers that fall this far behind.
+const eventBuffer = 64
+
+// Subscribe returns a channel of events from the amp. Typed events
+// are sent when the reported state differs from the cached state;
+// lines that aren't understood are sent as RawLine. Call cancel to
+// unsubscribe, which closes the channel; Close also closes it.
+// Events are dropped while the channel is full, followed by an
+// Overflow; use SubscribeWith for another policy.
+func (a *Amp) Subscribe() (events <-chan Event, cancel func()) {
+	return a.SubscribeWith(eventBuffer, DropNewest)
+}
+
+// lineBuffer is the capacity of each WatchLines channel.
+const lineBuffer = 256
+
+// WatchLines returns a channel of every line the amp sends, without
+// its carriage return, whether or not it is understood, as for
+// relaying the amp's reports verbatim. Lines are dropped for
+// watchers that fall lineBuffer lines behind. Call cancel to stop
+// watching, which closes the channel; Close also closes it.
+func (a *Amp) WatchLines() (lines <-chan string, cancel func()) {
+	ch := make(chan string, lineBuffer)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if a.lineWatchers == nil {
+		a.lineWatchers = make(map[chan string]bool)
+	}
+	a.lineWatchers[ch] = true
+	return ch, func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.lineWatchers[ch] { // else already closed
+			delete(a.lineWatchers, ch)
+			close(ch)
+		}
+	}
+}
+
+// publishLine sends l to every line watcher that has room for it.
+//
+// must be called with mu held
+func (a *Amp) publishLine(l string) {
+	for ch := range a.lineWatchers {
+		select {
+		case ch <- l:
+		default:
+		}
+	}
+}
+
+// publish sends ev to every subscriber according to its overflow
+// policy and records it in the event history and history log.
+//
+// must be called with mu held
+func (a *Amp) publish(ev Event) {
+	if _, raw := ev.(RawLine); !raw {
+		now := time.Now()
+		a.history.add(ev, now)
+		a.historyLog.log(ev, now)
+	}
+	for s := range a.subscribers {
+		s.send(ev)
+	}
+}
+
+// parseEvent parses amp line l.
+func parseEvent(l string) Event {
+	if ev := eventOf(proto.Parse(l)); ev != nil {
+		return ev
+	}
+	return RawLine{Line: l}
+}
+
+// eventOf returns the Event for m, or nil if there is none.
+func eventOf(m proto.Message) Event {
+	switch m := m.(type) {
+	case proto.Power:
+		return PowerChanged{On: m.On}
+	case proto.Volume:
+		return VolumeChanged{Volume: m.DB}
+	case proto.Input:
+		return InputChanged{Input: InputSource(m.Source)}
+	case proto.Surround:
+		return SurroundModeChanged{Mode: surroundMode(m)}
+	case proto.Mute:
+		return MuteChanged{Muted: m.On}
+	case proto.Headphones:
+		return HeadphonesChanged{Connected: m.Connected}
+	case proto.QuickSelect:
+		if !m.Memory {
+			return QuickSelectRecalled{N: m.N}
+		}
+	case proto.AllZoneStereo:
+		return AllZoneStereoChanged{On: m.On}
+	case proto.Update:
+		return updateStatus(m)
+	case proto.Zone:
+		if m.Zone == 2 || m.Zone == 3 {
+			return ZoneEvent{Zone: m.Zone, Event: eventOf(m.Msg)}
+		}
+	}
+	return nil
+}
+
+// listen calls f with each event from a new subscription, on its own
+// goroutine, until the returned func is called.
+func (a *Amp) listen(f func(Event)) (remove func()) {
+	events, cancel := a.Subscribe()
+	go func() {
+		for ev := range events {
+			f(ev)
+		}
+	}()
+	return cancel
+}
+
+// OnVolumeChange registers f to be called with the new master volume
+// in dB whenever it changes. The returned func unregisters f.
+func (a *Amp) OnVolumeChange(f func(db float64)) (remove func()) {
+	return a.listen(func(ev Event) {
+		if ev, ok := ev.(VolumeChanged); ok {
+			f(ev.Volume)
+		}
+	})
+}
+
+// OnPowerChange registers f to be called whenever the amp powers on
+// or enters standby. The returned func unregisters f.
+func (a *Amp) OnPowerChange(f func(on bool)) (remove func()) {
+	return a.listen(func(ev Event) {
+		if ev, ok := ev.(PowerChanged); ok {
+			f(ev.On)
+		}
+	})
+}
+
+// OnInputChange registers f to be called with the new main zone
+// input whenever it changes. The returned func unregisters f.
+func (a *Amp) OnInputChange(f func(InputSource)) (remove func()) {
+	return a.listen(func(ev Event) {
+		if ev, ok := ev.(InputChanged); ok {
+			f(ev.Input)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// FadeVolume moves the master volume to targetDB over d, in half
+// steps, or in larger steps if half steps would come faster than
+// commands may be sent. It returns once the amp has confirmed the
+// target volume, or when ctx is done, leaving the volume where the
+// fade had reached. A target above the WithVolumeLimit limit fades
+// to the limit and returns ErrVolumeLimited.
+func (a *Amp) FadeVolume(ctx context.Context, targetDB float64, d time.Duration) error {
+	if _, err := encodeVolume(targetDB); err != nil {
+		return err
+	}
+	limited := targetDB > a.volLimit
+	if limited {
+		targetDB = a.volLimit
+	}
+	qctx, cancel := a.timeoutContext(ctx)
+	l, err := a.query(qctx, "MV?", isVolumeLine)
+	cancel()
+	if err != nil {
+		return timeoutErr(err)
+	}
+	cur := parseEvent(l).(VolumeChanged).Volume
+
+	halves := int(math.Round((targetDB - cur) * 2))
+	dir := 0.5
+	if halves < 0 {
+		dir, halves = -0.5, -halves
+	}
+	if halves == 0 || d <= 0 {
+		return a.finishFade(ctx, targetDB, limited)
+	}
+	stride := 1 // half steps per command
+	if gap := max(a.gaps.Min, time.Millisecond); d/time.Duration(halves) < gap {
+		stride = int(math.Ceil(float64(gap) * float64(halves) / float64(d)))
+	}
+	steps := (halves + stride - 1) / stride
+	t := time.NewTicker(d / time.Duration(steps))
+	defer t.Stop()
+	for i := 1; i < steps; i++ {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		enc, err := encodeVolume(cur + dir*float64(i*stride))
+		if err != nil {
+			return err
+		}
+		// Don't wait for each step to be confirmed, or the fade
+		// would stutter on the amp's round trips.
+		if _, err := a.do(ctx, request{cmd: rawCmd, raw: "MV" + enc}); err != nil {
+			return err
+		}
+	}
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return a.finishFade(ctx, targetDB, limited)
+}
+
+func (a *Amp) finishFade(ctx context.Context, db float64, limited bool) error {
+	if err := a.setVolumeContext(ctx, db); err != nil {
+		return err
+	}
+	if limited {
+		return ErrVolumeLimited
+	}
+	return nil
+}
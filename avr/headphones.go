@@ -0,0 +1,41 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"time"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// headphoneTimeout is how long the headphone query waits for an
+// answer once written, as older models don't answer it at all.
+const headphoneTimeout = time.Second
+
+// Headphones reports whether headphones are plugged into the amp.
+// The amp also reports plugging and unplugging as they happen, as
+// HeadphonesChanged events. If the amp ignores the query, Headphones
+// returns ErrUnsupported. If the amp was on at the time, it does so
+// without asking until the Amp reconnects or the amp is next turned
+// on.
+func (a *Amp) Headphones(ctx context.Context) (bool, error) {
+	a.mu.Lock()
+	unsupported := a.noHeadphones
+	a.mu.Unlock()
+	if unsupported {
+		return false, ErrUnsupported
+	}
+	l, res, err := a.probeQuery(ctx, "SSHPD ?", is[proto.Headphones], headphoneTimeout)
+	if res == probeIgnored {
+		a.mu.Lock()
+		a.noHeadphones = a.cache.Power // in standby, the amp ignores it anyway
+		a.mu.Unlock()
+		return false, ErrUnsupported
+	}
+	if err != nil {
+		return false, err
+	}
+	return proto.Parse(l).(proto.Headphones).Connected, nil
+}
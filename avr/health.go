@@ -0,0 +1,55 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"time"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// Health describes how well the Amp is talking to the amp.
+type Health struct {
+	State       ConnState
+	Err         error         // why the Amp is Disconnected, if it is
+	LastCommand time.Time     // when a command was last sent; zero if never
+	LastLine    time.Time     // when the amp last sent a line; zero if never
+	Latency     time.Duration // round trip of a power query; 0 if it failed
+}
+
+// Healthcheck connects to the amp if needed, queries its power state
+// and reports the Amp's health. Unlike Ping, it fails unless the amp
+// answers, as when a powered-off amp's network stack still accepts
+// connections but not commands. The Health is returned even then.
+func (a *Amp) Healthcheck(ctx context.Context) (*Health, error) {
+	ctx, cancel := a.timeoutContext(ctx)
+	defer cancel()
+	start := time.Now()
+	_, err := a.query(ctx, "PW?", is[proto.Power])
+	h := a.health()
+	if err == nil {
+		h.Latency = time.Since(start)
+	}
+	return h, timeoutErr(err)
+}
+
+// health returns the Amp's health without querying the amp.
+func (a *Amp) health() *Health {
+	h := &Health{
+		LastCommand: unixTime(a.lastCmd.Load()),
+		LastLine:    unixTime(a.lastLine.Load()),
+	}
+	h.State, h.Err = a.ConnState()
+	return h
+}
+
+// unixTime returns the time ns nanoseconds after the Unix epoch, or
+// the zero time for 0.
+func unixTime(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
@@ -0,0 +1,82 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"time"
+)
+
+// heartbeatCmd is sent to an idle amp; every model answers it, even
+// in standby.
+const heartbeatCmd = "PW?"
+
+// WithHeartbeat makes the Amp query the amp's power state whenever
+// it has sent nothing for interval, and drop and remake the
+// connection once it has sent nothing for maxSilence. The connection
+// change reports ErrSilent. This notices a connection that died
+// silently, as when the amp loses power, in about maxSilence rather
+// than the minutes TCP takes. If maxSilence is zero, it is three
+// times interval. A zero interval, the default, disables heartbeats.
+func WithHeartbeat(interval, maxSilence time.Duration) Option {
+	return func(a *Amp) {
+		if maxSilence == 0 {
+			maxSilence = 3 * interval
+		}
+		a.hbInterval = interval
+		a.hbMaxSilence = maxSilence
+	}
+}
+
+// heartbeat tracks when the amp last sent a line. It is only used by
+// the loop goroutine.
+type heartbeat struct {
+	conn   uint64    // the connection lastRx is for
+	lastRx time.Time // when the connection last had a line from the amp
+	lastTx time.Time // when the last heartbeat was queued
+}
+
+// newHeartbeatTicker returns a ticker for checking on the amp, or
+// nil if heartbeats are disabled.
+func (a *Amp) newHeartbeatTicker() *time.Ticker {
+	if a.hbInterval <= 0 {
+		return nil
+	}
+	d := a.hbInterval / 2
+	if a.hbMaxSilence < a.hbInterval {
+		d = a.hbMaxSilence / 2
+	}
+	return time.NewTicker(d)
+}
+
+// checkHeartbeat queries an idle amp and drops the connection to a
+// silent one.
+//
+// run in loop goroutine
+func (a *Amp) checkHeartbeat(hb *heartbeat, pace *pacer) {
+	a.mu.Lock()
+	c := a.conn
+	a.mu.Unlock()
+	if c == nil {
+		return
+	}
+	now := time.Now()
+	if hb.conn != c.id {
+		hb.conn, hb.lastRx = c.id, now
+	}
+	silence := now.Sub(hb.lastRx)
+	switch {
+	case silence >= a.hbMaxSilence:
+		a.log.Error("avr: amp silent; reconnecting", "addr", a.addr, "silence", silence)
+		c.kill(ErrSilent)
+	case silence >= a.hbInterval && now.Sub(hb.lastTx) >= a.hbInterval:
+		hb.lastTx = now
+		pace.push(request{
+			ch:  make(chan *response, 1),
+			cmd: rawCmd,
+			raw: heartbeatCmd,
+			ctx: context.Background(),
+		})
+	}
+}
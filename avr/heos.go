@@ -0,0 +1,89 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+
+	"code.google.com/p/go-avr/avr/heos"
+)
+
+// HEOS returns a client for the amp's HEOS CLI, connecting on first
+// use to heos.DefaultPort on the amp's host. The client is shared by
+// callers and closed by Close. It is redialed if its connection has
+// failed.
+func (a *Amp) HEOS(ctx context.Context) (*heos.Client, error) {
+	if err := a.checkHEOS(); err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil, ErrClosed
+	}
+	c := a.heos
+	a.mu.Unlock()
+	if c != nil {
+		select {
+		case <-c.Done():
+		default:
+			return c, nil
+		}
+	}
+
+	d := a.dialer
+	hc, err := d.DialContext(ctx, "tcp", net.JoinHostPort(a.host(), strconv.Itoa(heos.DefaultPort)))
+	if err != nil {
+		return nil, err
+	}
+	nc := heos.NewClient(hc)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		nc.Close()
+		return nil, ErrClosed
+	}
+	if a.heos != c {
+		// Another caller redialed first.
+		nc.Close()
+		return a.heos, nil
+	}
+	if c != nil {
+		c.Close()
+	}
+	a.heos = nc
+	return nc, nil
+}
+
+// HEOSPlayer returns the amp's own player on its HEOS network,
+// found by its IP address. If the Amp was given a host name, any of
+// the name's addresses matches.
+func (a *Amp) HEOSPlayer(ctx context.Context) (*heos.Client, heos.Player, error) {
+	c, err := a.HEOS(ctx)
+	if err != nil {
+		return nil, heos.Player{}, err
+	}
+	ps, err := c.Players(ctx)
+	if err != nil {
+		return nil, heos.Player{}, err
+	}
+	ips := []string{a.host()}
+	if net.ParseIP(ips[0]) == nil {
+		ips, err = a.dialer.Resolver.LookupHost(ctx, ips[0])
+		if err != nil {
+			return nil, heos.Player{}, err
+		}
+	}
+	for _, p := range ps {
+		for _, ip := range ips {
+			if net.ParseIP(p.IP).Equal(net.ParseIP(ip)) {
+				return c, p, nil
+			}
+		}
+	}
+	return nil, heos.Player{}, errors.New("amp not found among HEOS players")
+}
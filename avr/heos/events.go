@@ -0,0 +1,71 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package heos
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// An Event is a change event from the device.
+type Event interface {
+	event()
+}
+
+// PlayStateChanged reports a player starting, pausing or stopping.
+type PlayStateChanged struct {
+	Player ID
+	State  PlayState
+}
+
+// NowPlayingChanged reports a new track or station on a player; call
+// NowPlaying for its details.
+type NowPlayingChanged struct {
+	Player ID
+}
+
+// QueueChanged reports a change to a player's queue.
+type QueueChanged struct {
+	Player ID
+}
+
+// PlayersChanged reports players joining or leaving the network.
+type PlayersChanged struct{}
+
+// GroupsChanged reports players being grouped or ungrouped.
+type GroupsChanged struct{}
+
+// OtherEvent is an event not parsed into another type, such as
+// "player_volume_changed".
+type OtherEvent struct {
+	Name    string
+	Message url.Values
+}
+
+func (PlayStateChanged) event()  {}
+func (NowPlayingChanged) event() {}
+func (QueueChanged) event()      {}
+func (PlayersChanged) event()    {}
+func (GroupsChanged) event()     {}
+func (OtherEvent) event()        {}
+
+// parseEvent parses an event, name being its command without the
+// "event/" prefix.
+func parseEvent(name string, msg url.Values) Event {
+	pid, err := strconv.Atoi(msg.Get("pid"))
+	hasPID := err == nil
+	switch {
+	case name == "player_state_changed" && hasPID:
+		return PlayStateChanged{Player: ID(pid), State: PlayState(msg.Get("state"))}
+	case name == "player_now_playing_changed" && hasPID:
+		return NowPlayingChanged{Player: ID(pid)}
+	case name == "player_queue_changed" && hasPID:
+		return QueueChanged{Player: ID(pid)}
+	case name == "players_changed":
+		return PlayersChanged{}
+	case name == "groups_changed":
+		return GroupsChanged{}
+	}
+	return OtherEvent{Name: name, Message: msg}
+}
@@ -0,0 +1,73 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package heos
+
+import (
+	"context"
+	"strconv"
+)
+
+// A Group is a set of players playing the same audio.
+type Group struct {
+	ID      ID            `json:"gid"`
+	Name    string        `json:"name"`
+	Players []GroupMember `json:"players"`
+}
+
+// A GroupMember is a player in a Group.
+type GroupMember struct {
+	ID   ID     `json:"pid"`
+	Name string `json:"name"`
+	Role string `json:"role"` // "leader" or "member"
+}
+
+// Groups returns the groups on the network.
+func (c *Client) Groups(ctx context.Context) ([]Group, error) {
+	r, err := c.Command(ctx, "group/get_groups")
+	if err != nil {
+		return nil, err
+	}
+	var gs []Group
+	return gs, r.decode(&gs)
+}
+
+// SetGroup groups members with leader, replacing leader's current
+// group. With no members it ungroups leader.
+func (c *Client) SetGroup(ctx context.Context, leader ID, members ...ID) error {
+	_, err := c.Command(ctx, "group/set_group", "pid", joinIDs(append([]ID{leader}, members...)))
+	return err
+}
+
+// Ungroup breaks up the group led by leader.
+func (c *Client) Ungroup(ctx context.Context, leader ID) error {
+	return c.SetGroup(ctx, leader)
+}
+
+// favoritesSource is the source id of HEOS Favorites.
+const favoritesSource = "1028"
+
+// A MediaItem is an entry in a music source such as the favorites.
+type MediaItem struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"` // such as "station" or "song"
+	MediaID  string `json:"mid"`
+	ImageURL string `json:"image_url"`
+	Playable string `json:"playable"` // "yes" or "no"
+}
+
+// Favorites returns the user's HEOS favorites, in preset order.
+func (c *Client) Favorites(ctx context.Context) ([]MediaItem, error) {
+	r, err := c.Command(ctx, "browse/browse", "sid", favoritesSource)
+	if err != nil {
+		return nil, err
+	}
+	var items []MediaItem
+	return items, r.decode(&items)
+}
+
+// PlayFavorite plays favorite n, counting from 1, on player.
+func (c *Client) PlayFavorite(ctx context.Context, player ID, n int) error {
+	_, err := c.Command(ctx, "browse/play_preset", "pid", player.String(), "preset", strconv.Itoa(n))
+	return err
+}
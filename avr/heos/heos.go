@@ -0,0 +1,294 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+// Package heos is a client for the HEOS CLI protocol spoken by newer
+// Denon and Marantz receivers and HEOS speakers on TCP port 1255.
+//
+// Commands are sent as "heos://group/command?args" lines and
+// answered with one JSON object per line. A Client sends one command
+// at a time.
+package heos
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultPort is the TCP port of the HEOS CLI.
+const DefaultPort = 1255
+
+// eventBuffer is the number of events buffered for Events before
+// further events are dropped.
+const eventBuffer = 64
+
+// ErrClosed is returned by commands on a closed Client.
+var ErrClosed = errors.New("heos client closed")
+
+// An Error is a command failure reported by the device.
+type Error struct {
+	Cmd  string
+	ID   int    // the eid the device returned
+	Text string // the device's description
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("heos %s: error %d: %s", e.Cmd, e.ID, e.Text)
+}
+
+// A Client is a connection to a HEOS device. It is safe for use by
+// multiple goroutines.
+type Client struct {
+	// Immutable:
+	c      net.Conn
+	events chan Event
+	done   chan struct{} // closed when the connection is finished
+	cmdmu  sync.Mutex    // held while a command is in flight
+
+	// Guarded by mu:
+	mu     sync.Mutex
+	waiter *waiter
+	err    error // why the connection finished
+}
+
+type waiter struct {
+	cmd string
+	ch  chan *Response // buffered
+}
+
+// A Response is a device's answer to a command.
+type Response struct {
+	Cmd     string     // such as "player/get_players"
+	Message url.Values // the parsed message
+	Payload json.RawMessage
+}
+
+type wireMessage struct {
+	HEOS struct {
+		Command string `json:"command"`
+		Result  string `json:"result"`
+		Message string `json:"message"`
+	} `json:"heos"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Dial connects to the HEOS CLI at addr, either a host or a
+// host:port.
+func Dial(ctx context.Context, addr string) (*Client, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, strconv.Itoa(DefaultPort))
+	}
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c), nil
+}
+
+// NewClient returns a Client using the connection c.
+func NewClient(c net.Conn) *Client {
+	cl := &Client{
+		c:      c,
+		events: make(chan Event, eventBuffer),
+		done:   make(chan struct{}),
+	}
+	go cl.readLoop()
+	return cl
+}
+
+// Close closes the connection. The Events channel is closed once the
+// connection is finished.
+func (c *Client) Close() error {
+	return c.c.Close()
+}
+
+// Done returns a channel that is closed once the connection has
+// finished, after which every command fails.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Events returns the channel of change events. The device only sends
+// them after RegisterForChangeEvents. Events are dropped if the
+// channel is full.
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// RegisterForChangeEvents turns the device's change events on or off.
+func (c *Client) RegisterForChangeEvents(ctx context.Context, on bool) error {
+	_, err := c.Command(ctx, "system/register_for_change_events", "enable", onOff(on))
+	return err
+}
+
+// HeartBeat checks that the device is answering.
+func (c *Client) HeartBeat(ctx context.Context) error {
+	_, err := c.Command(ctx, "system/heart_beat")
+	return err
+}
+
+// Command sends cmd, such as "player/get_players", with the given
+// alternating argument names and values, and waits for its answer.
+func (c *Client) Command(ctx context.Context, cmd string, args ...string) (*Response, error) {
+	if len(args)%2 != 0 {
+		return nil, fmt.Errorf("heos %s: odd number of arguments", cmd)
+	}
+	c.cmdmu.Lock()
+	defer c.cmdmu.Unlock()
+
+	w := &waiter{cmd: cmd, ch: make(chan *Response, 1)}
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return nil, err
+	}
+	c.waiter = w
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		if c.waiter == w {
+			c.waiter = nil
+		}
+		c.mu.Unlock()
+	}()
+
+	if _, err := c.c.Write([]byte(commandLine(cmd, args))); err != nil {
+		return nil, err
+	}
+	select {
+	case r := <-w.ch:
+		if r.Message.Has("eid") {
+			id, _ := strconv.Atoi(r.Message.Get("eid"))
+			return nil, &Error{Cmd: cmd, ID: id, Text: r.Message.Get("text")}
+		}
+		return r, nil
+	case <-c.done:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return nil, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// commandLine encodes a command with its arguments.
+func commandLine(cmd string, args []string) string {
+	var b strings.Builder
+	b.WriteString("heos://")
+	b.WriteString(cmd)
+	for i := 0; i < len(args); i += 2 {
+		if i == 0 {
+			b.WriteByte('?')
+		} else {
+			b.WriteByte('&')
+		}
+		b.WriteString(args[i])
+		b.WriteByte('=')
+		b.WriteString(escape(args[i+1]))
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// escape escapes the characters the CLI reserves in argument values.
+func escape(v string) string {
+	return strings.NewReplacer("%", "%25", "&", "%26", "=", "%3D").Replace(v)
+}
+
+func (c *Client) readLoop() {
+	br := bufio.NewReader(c.c)
+	var err error
+	for {
+		var line []byte
+		line, err = br.ReadBytes('\n')
+		if err != nil {
+			break
+		}
+		var m wireMessage
+		if json.Unmarshal(line, &m) != nil {
+			continue
+		}
+		c.handle(&m)
+	}
+	c.c.Close()
+	c.mu.Lock()
+	if errors.Is(err, net.ErrClosed) {
+		err = ErrClosed
+	}
+	c.err = err
+	c.mu.Unlock()
+	close(c.done)
+	close(c.events)
+}
+
+func (c *Client) handle(m *wireMessage) {
+	h := m.HEOS
+	msg, _ := url.ParseQuery(h.Message)
+	if strings.HasPrefix(h.Command, "event/") {
+		select {
+		case c.events <- parseEvent(strings.TrimPrefix(h.Command, "event/"), msg):
+		default:
+		}
+		return
+	}
+	// The device first answers slow commands with a "command under
+	// process" message, then with the result.
+	if strings.HasPrefix(h.Message, "command under process") {
+		return
+	}
+	r := &Response{Cmd: h.Command, Message: msg, Payload: m.Payload}
+	if h.Result == "fail" && !msg.Has("eid") {
+		r.Message.Set("eid", "-1")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if w := c.waiter; w != nil && w.cmd == h.Command {
+		w.ch <- r
+		c.waiter = nil
+	}
+}
+
+// decode decodes r's payload into v.
+func (r *Response) decode(v any) error {
+	if len(r.Payload) == 0 {
+		return fmt.Errorf("heos %s: no payload", r.Cmd)
+	}
+	if err := json.Unmarshal(r.Payload, v); err != nil {
+		return fmt.Errorf("heos %s: %w", r.Cmd, err)
+	}
+	return nil
+}
+
+// ID is a player, group or queue item id. The device sends ids as
+// either JSON numbers or strings.
+type ID int
+
+func (id *ID) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid id %s", b)
+	}
+	*id = ID(n)
+	return nil
+}
+
+func (id ID) String() string {
+	return strconv.Itoa(int(id))
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
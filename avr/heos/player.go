@@ -0,0 +1,168 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package heos
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// A Player is a HEOS device or receiver zone that plays audio.
+type Player struct {
+	ID      ID     `json:"pid"`
+	Name    string `json:"name"`
+	Model   string `json:"model"`
+	Version string `json:"version"`
+	IP      string `json:"ip"`
+	Network string `json:"network"` // "wired", "wifi" or "unknown"
+	Serial  string `json:"serial"`
+	GroupID *ID    `json:"gid"` // nil if not grouped
+}
+
+// PlayState is a player's play state.
+type PlayState string
+
+// Play states.
+const (
+	Playing PlayState = "play"
+	Paused  PlayState = "pause"
+	Stopped PlayState = "stop"
+)
+
+// NowPlaying is the media a player is playing.
+type NowPlaying struct {
+	Type     string `json:"type"` // "song" or "station"
+	Song     string `json:"song"`
+	Album    string `json:"album"`
+	Artist   string `json:"artist"`
+	Station  string `json:"station"`
+	ImageURL string `json:"image_url"`
+	MediaID  string `json:"mid"`
+	QueueID  ID     `json:"qid"`
+}
+
+// A QueueItem is an entry in a player's queue.
+type QueueItem struct {
+	ID       ID     `json:"qid"`
+	Song     string `json:"song"`
+	Album    string `json:"album"`
+	Artist   string `json:"artist"`
+	ImageURL string `json:"image_url"`
+	MediaID  string `json:"mid"`
+}
+
+// Players returns the players on the network.
+func (c *Client) Players(ctx context.Context) ([]Player, error) {
+	r, err := c.Command(ctx, "player/get_players")
+	if err != nil {
+		return nil, err
+	}
+	var ps []Player
+	return ps, r.decode(&ps)
+}
+
+// PlayState returns player's play state.
+func (c *Client) PlayState(ctx context.Context, player ID) (PlayState, error) {
+	r, err := c.Command(ctx, "player/get_play_state", "pid", player.String())
+	if err != nil {
+		return "", err
+	}
+	return PlayState(r.Message.Get("state")), nil
+}
+
+// SetPlayState plays, pauses or stops player.
+func (c *Client) SetPlayState(ctx context.Context, player ID, st PlayState) error {
+	_, err := c.Command(ctx, "player/set_play_state", "pid", player.String(), "state", string(st))
+	return err
+}
+
+// Play resumes playback on player.
+func (c *Client) Play(ctx context.Context, player ID) error {
+	return c.SetPlayState(ctx, player, Playing)
+}
+
+// Pause pauses player.
+func (c *Client) Pause(ctx context.Context, player ID) error {
+	return c.SetPlayState(ctx, player, Paused)
+}
+
+// Stop stops player.
+func (c *Client) Stop(ctx context.Context, player ID) error {
+	return c.SetPlayState(ctx, player, Stopped)
+}
+
+// Next skips to the next track on player.
+func (c *Client) Next(ctx context.Context, player ID) error {
+	_, err := c.Command(ctx, "player/play_next", "pid", player.String())
+	return err
+}
+
+// Previous goes back to the previous track on player.
+func (c *Client) Previous(ctx context.Context, player ID) error {
+	_, err := c.Command(ctx, "player/play_previous", "pid", player.String())
+	return err
+}
+
+// NowPlaying returns what player is playing.
+func (c *Client) NowPlaying(ctx context.Context, player ID) (*NowPlaying, error) {
+	r, err := c.Command(ctx, "player/get_now_playing_media", "pid", player.String())
+	if err != nil {
+		return nil, err
+	}
+	np := new(NowPlaying)
+	return np, r.decode(np)
+}
+
+// maxQueueRange is the most queue items the device returns at once.
+const maxQueueRange = 100
+
+// Queue returns player's queue.
+func (c *Client) Queue(ctx context.Context, player ID) ([]QueueItem, error) {
+	var all []QueueItem
+	for start := 0; ; start += maxQueueRange {
+		rng := strconv.Itoa(start) + "," + strconv.Itoa(start+maxQueueRange-1)
+		r, err := c.Command(ctx, "player/get_queue", "pid", player.String(), "range", rng)
+		if err != nil {
+			return nil, err
+		}
+		var items []QueueItem
+		if err := r.decode(&items); err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		if len(items) < maxQueueRange {
+			return all, nil
+		}
+	}
+}
+
+// PlayQueueItem plays the queue item with the given id on player.
+func (c *Client) PlayQueueItem(ctx context.Context, player, item ID) error {
+	_, err := c.Command(ctx, "player/play_queue", "pid", player.String(), "qid", item.String())
+	return err
+}
+
+// RemoveFromQueue removes the given items from player's queue.
+func (c *Client) RemoveFromQueue(ctx context.Context, player ID, items ...ID) error {
+	if len(items) == 0 {
+		return nil
+	}
+	_, err := c.Command(ctx, "player/remove_from_queue", "pid", player.String(), "qid", joinIDs(items))
+	return err
+}
+
+// ClearQueue empties player's queue.
+func (c *Client) ClearQueue(ctx context.Context, player ID) error {
+	_, err := c.Command(ctx, "player/clear_queue", "pid", player.String())
+	return err
+}
+
+func joinIDs(ids []ID) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = id.String()
+	}
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,53 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import "time"
+
+// A TimedEvent is an event and when the Amp published it.
+type TimedEvent struct {
+	Time  time.Time
+	Event Event
+}
+
+// WithEventHistory makes the Amp remember the last n events it
+// publishes, other than RawLine, for RecentEvents. By default it
+// remembers none.
+func WithEventHistory(n int) Option {
+	return func(a *Amp) {
+		a.history = history{events: make([]TimedEvent, 0, max(n, 0))}
+	}
+}
+
+// RecentEvents returns the events remembered WithEventHistory,
+// oldest first.
+func (a *Amp) RecentEvents() []TimedEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.history.list()
+}
+
+// history is a ring of the latest events.
+type history struct {
+	events []TimedEvent // the ring, up to its capacity
+	next   int          // index of the oldest once the ring is full
+}
+
+func (h *history) add(ev Event, t time.Time) {
+	switch {
+	case cap(h.events) == 0:
+	case len(h.events) < cap(h.events):
+		h.events = append(h.events, TimedEvent{t, ev})
+	default:
+		h.events[h.next] = TimedEvent{t, ev}
+		h.next = (h.next + 1) % len(h.events)
+	}
+}
+
+// list returns a copy of the ring's events, oldest first.
+func (h *history) list() []TimedEvent {
+	l := make([]TimedEvent, 0, len(h.events))
+	l = append(l, h.events[h.next:]...)
+	return append(l, h.events[:h.next]...)
+}
@@ -0,0 +1,155 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithHistoryLog makes the Amp append each event it publishes, other
+// than RawLine, to w as a line of JSON, as
+//
+//	{"time":"2011-05-01T20:04:05.123456Z","type":"VolumeChanged","event":{"Volume":-35}}
+//
+// for auditing automation. Use a RotatingFile to keep the log from
+// growing without bound. Writes to w that fail are ignored.
+func WithHistoryLog(w io.Writer) Option {
+	return func(a *Amp) {
+		if w == nil {
+			a.historyLog = nil
+			return
+		}
+		a.historyLog = &historyLog{w: w}
+	}
+}
+
+// A historyLog writes WithHistoryLog lines. A nil *historyLog
+// discards them.
+type historyLog struct {
+	w io.Writer
+}
+
+// log writes ev, published at t.
+//
+// must be called with mu held
+func (l *historyLog) log(ev Event, t time.Time) {
+	if l == nil {
+		return
+	}
+	b, err := json.Marshal(struct {
+		Time  time.Time `json:"time"`
+		Type  string    `json:"type"`
+		Event Event     `json:"event"`
+	}{t.UTC(), eventName(ev), ev})
+	if err != nil {
+		return
+	}
+	l.w.Write(append(b, '\n'))
+}
+
+// eventName returns the name of ev's type, such as "VolumeChanged".
+func eventName(ev Event) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", ev), "avr.")
+}
+
+// A RotatingFile is a file that is renamed once it reaches a maximum
+// size, with a new one started in its place. Path.1 is the most
+// recent old file, then path.2 and so on, up to a number kept. It is
+// safe for concurrent use.
+type RotatingFile struct {
+	path    string
+	maxSize int64
+	keep    int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenRotatingFile opens path for appending, creating it if needed.
+// Once a write would take it past maxSize bytes it is rotated,
+// keeping keep old files.
+func OpenRotatingFile(path string, maxSize int64, keep int) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, maxSize: maxSize, keep: max(keep, 0)}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write appends p to the file, rotating it first if it would grow
+// past the maximum size. A single write larger than that still goes
+// to one file.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return os.ErrClosed
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// must be called with mu held
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, fi.Size()
+	return nil
+}
+
+// must be called with mu held
+func (r *RotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	r.f = nil
+	if r.keep == 0 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return r.open()
+	}
+	for n := r.keep - 1; n >= 1; n-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", r.path, n), fmt.Sprintf("%s.%d", r.path, n+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return err
+	}
+	return r.open()
+}
@@ -0,0 +1,169 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// InputSource is an input source name as used by the SI command.
+// Sources not listed below are represented by the amp's own name.
+type InputSource string
+
+// Input sources. Not every model supports every source.
+const (
+	SourcePhono     InputSource = "PHONO"
+	SourceCD        InputSource = "CD"
+	SourceTuner     InputSource = "TUNER"
+	SourceDVD       InputSource = "DVD"
+	SourceBD        InputSource = "BD"
+	SourceTV        InputSource = "TV"
+	SourceSatCbl    InputSource = "SAT/CBL"
+	SourceSat       InputSource = "SAT"
+	SourceMediaPlay InputSource = "MPLAY"
+	SourceDVR       InputSource = "DVR"
+	SourceGame      InputSource = "GAME"
+	SourceGame2     InputSource = "GAME2"
+	SourceVAux      InputSource = "V.AUX"
+	SourceAux1      InputSource = "AUX1"
+	SourceAux2      InputSource = "AUX2"
+	SourceDock      InputSource = "DOCK"
+	SourceIPod      InputSource = "IPOD"
+	SourceNetUSB    InputSource = "NET/USB"
+	SourceNet       InputSource = "NET"
+	SourceUSB       InputSource = "USB/IPOD"
+	SourceBluetooth InputSource = "BT"
+	SourceServer    InputSource = "SERVER"
+	SourceIRadio    InputSource = "IRADIO"
+	SourceFavorites InputSource = "FAVORITES"
+	SourcePandora   InputSource = "PANDORA"
+	SourceLastFM    InputSource = "LASTFM"
+	SourceFlickr    InputSource = "FLICKR"
+	SourceNapster   InputSource = "NAPSTER"
+	SourceRhapsody  InputSource = "RHAPSODY"
+)
+
+// CommonSources are sources that most models have, for listing when
+// the amp's own list can't be had.
+var CommonSources = []InputSource{
+	SourcePhono, SourceCD, SourceTuner, SourceDVD, SourceBD, SourceTV,
+	SourceSatCbl, SourceMediaPlay, SourceGame, SourceAux1, SourceNet,
+	SourceBluetooth,
+}
+
+// SelectInput switches the main zone to src and waits for the amp
+// to confirm.
+func (a *Amp) SelectInput(src InputSource) error {
+	if src == "" {
+		return errors.New("empty input source")
+	}
+	if err := a.checkInput(src); err != nil {
+		return err
+	}
+	cmd := "SI" + string(src)
+	return a.setConfirm(cmd, is[proto.Input], cmd)
+}
+
+// CurrentInput returns the main zone's input source.
+func (a *Amp) CurrentInput() (InputSource, error) {
+	l, err := a.timeoutQuery("SI?", is[proto.Input])
+	if err != nil {
+		return "", err
+	}
+	return InputSource(l[len("SI"):]), nil
+}
+
+// An Input is an input source as set up on the amp.
+type Input struct {
+	Source InputSource
+	Name   string // the name the user gave the source, or Source
+	Hidden bool   // set to be deleted in the amp's setup menu
+}
+
+// inputListQuiet is how long an input list query waits for further
+// lines once the amp has started answering.
+const inputListQuiet = 300 * time.Millisecond
+
+// Inputs returns the amp's input sources with the names the user has
+// given them, such as "Apple TV" for SourceSatCbl. UIs should omit
+// Hidden sources. Models that don't answer SSFUN are asked over
+// their web interface if the Amp was created WithWebStatus; those
+// only list sources that aren't hidden.
+func (a *Amp) Inputs(ctx context.Context) ([]Input, error) {
+	ctx, cancel := a.timeoutContext(ctx)
+	defer cancel()
+	names, err := a.inputList(ctx, "SSFUN")
+	if errors.Is(err, ErrTimeout) && a.webClient != nil {
+		return a.webInputs(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Older models don't answer SSSOD, so treat every source as in
+	// use when it fails.
+	use, _ := a.inputList(ctx, "SSSOD")
+	ins := make([]Input, 0, len(names))
+	for _, kv := range names {
+		in := Input{Source: kv.src, Name: kv.val}
+		if in.Name == "" {
+			in.Name = string(kv.src)
+		}
+		for _, u := range use {
+			if u.src == kv.src {
+				in.Hidden = u.val == "DEL"
+			}
+		}
+		ins = append(ins, in)
+	}
+	return ins, nil
+}
+
+type sourceValue struct {
+	src InputSource
+	val string
+}
+
+// inputList issues cmd followed by " ?", to which the amp replies
+// with one line per source such as "SSFUNSAT/CBL Apple TV", then
+// cmd followed by " END".
+func (a *Amp) inputList(ctx context.Context, cmd string) ([]sourceValue, error) {
+	endLine := cmd + " END"
+	end := func(l string) bool { return l == endLine }
+	lines, err := a.queryLines(ctx, cmd+" ?", prefix(cmd), end, inputListQuiet)
+	if err != nil && len(lines) == 0 {
+		return nil, err
+	}
+	var svs []sourceValue
+	for _, l := range lines {
+		if l == endLine {
+			continue
+		}
+		src, val, _ := strings.Cut(l[len(cmd):], " ")
+		if src == "" {
+			continue
+		}
+		svs = append(svs, sourceValue{InputSource(src), strings.TrimSpace(val)})
+	}
+	return svs, nil
+}
+
+// webInputs returns the inputs listed by the web interface.
+func (a *Amp) webInputs(ctx context.Context) ([]Input, error) {
+	ws, err := a.WebStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ins := make([]Input, 0, len(ws.InputNames))
+	for _, src := range slices.Sorted(maps.Keys(ws.InputNames)) {
+		ins = append(ins, Input{Source: src, Name: ws.InputNames[src]})
+	}
+	return ins, nil
+}
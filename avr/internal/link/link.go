@@ -0,0 +1,440 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+// Package link manages the control connection of the drivers for
+// other brands' receivers: connecting on demand, matching replies to
+// commands and publishing events.
+package link
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"code.google.com/p/go-avr/avr"
+	"code.google.com/p/go-avr/avr/internal/pace"
+)
+
+// A Protocol describes a receiver's control protocol.
+type Protocol struct {
+	// Write writes command cmd to w.
+	Write func(w io.Writer, cmd string) error
+
+	// Read reads the next message from r.
+	Read func(r *bufio.Reader) (string, error)
+
+	// Split splits a message into the parameter it reports on and
+	// its value. Messages for which ok is false answer no command.
+	Split func(msg string) (param, value string, ok bool)
+
+	// Error returns the error reported by msg, if msg is an error
+	// reply that isn't specific to a parameter. Such a reply fails
+	// the oldest command waiting for one. Error may be nil.
+	Error func(msg string) error
+
+	// Event returns the event for a parameter's value, or nil.
+	Event func(param, value string) avr.Event
+
+	// Gaps are the minimum times between commands, which the
+	// receiver may drop or misread if sent closer together.
+	Gaps pace.Gaps
+}
+
+// eventBuffer is the capacity of each subscriber's channel. Events
+// are dropped for subscribers that fall this far behind.
+const eventBuffer = 64
+
+// A Client is a connection to a receiver. It connects on first use
+// and, once the connection breaks, on the next command or, while
+// anything is subscribed, in the background with backoff. It is safe
+// for concurrent use.
+type Client struct {
+	addr    string
+	timeout time.Duration
+	proto   Protocol
+	ctx     context.Context // done once closed
+	cancel  context.CancelFunc
+	spacer  *pace.Spacer
+	wg      sync.WaitGroup
+
+	mu        sync.Mutex
+	closed    bool
+	conn      net.Conn
+	dialing   chan struct{} // closed when the dial under way ends; nil if none
+	redialing bool          // redial is running
+	waiters   []*waiter     // oldest first
+	subs      map[chan avr.Event]bool
+	last      map[string]string // last value reported for each parameter
+}
+
+// Background redials wait minRedial after the first failure, doubling
+// after each one up to maxRedial.
+const (
+	minRedial = time.Second
+	maxRedial = time.Minute
+)
+
+type waiter struct {
+	param string
+	ch    chan reply
+}
+
+type reply struct {
+	msg   string
+	value string
+	err   error
+}
+
+// New returns a Client for the receiver at addr, which waits up to
+// timeout for connections and replies.
+func New(addr string, timeout time.Duration, p Protocol) *Client {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Client{
+		addr:    addr,
+		timeout: timeout,
+		proto:   p,
+		ctx:     ctx,
+		cancel:  cancel,
+		spacer:  pace.NewSpacer(p.Gaps),
+		subs:    make(map[chan avr.Event]bool),
+		last:    make(map[string]string),
+	}
+}
+
+// Close closes the connection and every subscription channel.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		c.cancel()
+		if c.conn != nil {
+			c.conn.Close()
+		}
+		for ch := range c.subs {
+			close(ch)
+		}
+		c.subs = nil
+	}
+	c.mu.Unlock()
+	c.wg.Wait()
+	return nil
+}
+
+// Subscribe returns a channel of the receiver's events and connects
+// in the background if needed, retrying until it succeeds or the last
+// subscriber cancels. Typed events are sent when the receiver reports
+// a change; other messages are sent as avr.RawLine.
+func (c *Client) Subscribe() (events <-chan avr.Event, cancel func()) {
+	ch := make(chan avr.Event, eventBuffer)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	c.subs[ch] = true
+	c.startRedial()
+	return ch, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.subs[ch] {
+			delete(c.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// RoundTrip sends cmd and returns the value of the first message
+// for param that arrives, connecting first if needed.
+func (c *Client) RoundTrip(cmd, param string) (string, error) {
+	r, err := c.roundTrip(context.Background(), cmd, param)
+	return r.value, err
+}
+
+// Query is like RoundTrip but gives up when ctx is done too, and
+// returns the whole reply message. An empty param matches the next
+// message Split accepts.
+func (c *Client) Query(ctx context.Context, cmd, param string) (string, error) {
+	r, err := c.roundTrip(ctx, cmd, param)
+	return r.msg, err
+}
+
+// Send sends cmd without waiting for a reply, connecting first if
+// needed. Like the other commands, it waits its turn to keep the
+// protocol's gaps.
+func (c *Client) Send(ctx context.Context, cmd string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.wait(ctx, cmd); err != nil {
+		return err
+	}
+	return c.write(conn, cmd)
+}
+
+func (c *Client) roundTrip(ctx context.Context, cmd, param string) (reply, error) {
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return reply{}, err
+	}
+	if err := c.wait(ctx, cmd); err != nil {
+		return reply{}, err
+	}
+	w := &waiter{param: param, ch: make(chan reply, 1)}
+	c.mu.Lock()
+	if c.conn != conn {
+		c.mu.Unlock()
+		return reply{}, fmt.Errorf("%w: connection lost", avr.ErrNotConnected)
+	}
+	c.waiters = append(c.waiters, w)
+	c.mu.Unlock()
+	defer c.drop(w)
+
+	if err := c.write(conn, cmd); err != nil {
+		return reply{}, err
+	}
+	t := time.NewTimer(c.timeout)
+	defer t.Stop()
+	select {
+	case r := <-w.ch:
+		return r, r.err
+	case <-t.C:
+		return reply{}, fmt.Errorf("%w: no reply to %q", avr.ErrTimeout, cmd)
+	case <-ctx.Done():
+		return reply{}, ctx.Err()
+	case <-c.ctx.Done():
+		return reply{}, avr.ErrClosed
+	}
+}
+
+// write writes cmd to conn, closing conn if that fails.
+func (c *Client) write(conn net.Conn, cmd string) error {
+	conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	if err := c.proto.Write(conn, cmd); err != nil {
+		conn.Close()
+		return err
+	}
+	return nil
+}
+
+// drop removes w from the waiters, if it is still there.
+func (c *Client) drop(w *waiter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, o := range c.waiters {
+		if o == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// connect returns the connection, dialing it if there is none, or
+// waiting for the dial under way. It dials without holding mu, and
+// gives up after the timeout, once ctx is done or once the Client is
+// closed.
+func (c *Client) connect(ctx context.Context) (net.Conn, error) {
+	for {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return nil, avr.ErrClosed
+		}
+		if c.conn != nil {
+			conn := c.conn
+			c.mu.Unlock()
+			return conn, nil
+		}
+		if dialing := c.dialing; dialing != nil {
+			c.mu.Unlock()
+			select {
+			case <-dialing:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-c.ctx.Done():
+				return nil, avr.ErrClosed
+			}
+		}
+		dialing := make(chan struct{})
+		c.dialing = dialing
+		c.mu.Unlock()
+
+		conn, err := c.dial(ctx)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.dialing = nil
+		close(dialing)
+		switch {
+		case c.closed:
+			if conn != nil {
+				conn.Close()
+			}
+			return nil, avr.ErrClosed
+		case err != nil:
+			return nil, fmt.Errorf("%w: %w", avr.ErrNotConnected, err)
+		}
+		c.conn = conn
+		c.wg.Add(1)
+		go c.read(conn)
+		return conn, nil
+	}
+}
+
+// wait waits until it is cmd's turn to be sent, or until ctx is done
+// or the Client is closed.
+func (c *Client) wait(ctx context.Context, cmd string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(c.ctx, cancel)
+	defer stop()
+	if err := c.spacer.Wait(ctx, cmd); err != nil {
+		if c.ctx.Err() != nil {
+			return avr.ErrClosed
+		}
+		return err
+	}
+	return nil
+}
+
+// dial dials the receiver, giving up after the timeout, once ctx is
+// done or once the Client is closed.
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	stop := context.AfterFunc(c.ctx, cancel)
+	defer stop()
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", c.addr)
+}
+
+// startRedial starts redial unless it is running or there is a
+// connection.
+//
+// must be called with mu held
+func (c *Client) startRedial() {
+	if c.redialing || c.conn != nil {
+		return
+	}
+	c.redialing = true
+	c.wg.Add(1)
+	go c.redial()
+}
+
+// redial connects in the background, so that subscribers keep getting
+// events after the connection breaks. It retries with backoff until
+// there is a connection, nothing is subscribed or the Client is
+// closed.
+func (c *Client) redial() {
+	defer c.wg.Done()
+	var delay time.Duration
+	for {
+		if delay > 0 {
+			t := time.NewTimer(delay)
+			select {
+			case <-t.C:
+			case <-c.ctx.Done():
+				t.Stop()
+			}
+		}
+		c.mu.Lock()
+		if c.closed || c.conn != nil || len(c.subs) == 0 {
+			c.redialing = false
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+		if _, err := c.connect(c.ctx); err != nil {
+			delay = min(max(2*delay, minRedial), maxRedial)
+		} else {
+			// Check again shortly, in case the receiver hangs up at
+			// once.
+			delay = minRedial
+		}
+	}
+}
+
+// read delivers the messages arriving on conn until it fails.
+func (c *Client) read(conn net.Conn) {
+	defer c.wg.Done()
+	br := bufio.NewReader(conn)
+	var err error
+	for {
+		var msg string
+		if msg, err = c.proto.Read(br); err != nil {
+			break
+		}
+		c.dispatch(msg)
+	}
+	conn.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != conn {
+		return
+	}
+	c.conn = nil
+	for _, w := range c.waiters {
+		w.ch <- reply{err: fmt.Errorf("%w: %w", avr.ErrNotConnected, err)}
+	}
+	c.waiters = nil
+	if !c.closed && len(c.subs) > 0 {
+		c.startRedial()
+	}
+}
+
+// dispatch answers the commands waiting for msg and publishes its
+// event if msg reports a change.
+func (c *Client) dispatch(msg string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.proto.Error != nil {
+		if err := c.proto.Error(msg); err != nil {
+			if len(c.waiters) > 0 {
+				c.waiters[0].ch <- reply{err: err}
+				c.waiters = c.waiters[1:]
+			}
+			return
+		}
+	}
+	param, value, ok := c.proto.Split(msg)
+	if !ok {
+		c.publish(avr.RawLine{Line: msg})
+		return
+	}
+	kept := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.param == param || w.param == "" {
+			w.ch <- reply{msg: msg, value: value}
+		} else {
+			kept = append(kept, w)
+		}
+	}
+	c.waiters = kept
+	ev := c.proto.Event(param, value)
+	if ev == nil {
+		ev = avr.RawLine{Line: msg}
+	} else if v, ok := c.last[param]; ok && v == value {
+		return
+	}
+	c.last[param] = value
+	c.publish(ev)
+}
+
+// must be called with mu held
+func (c *Client) publish(ev avr.Event) {
+	for ch := range c.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
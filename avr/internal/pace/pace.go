@@ -0,0 +1,71 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+// Package pace spaces out the commands sent to a receiver, which may
+// drop or misread commands sent too close together.
+package pace
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Gaps are the minimum times to wait after sending a command.
+type Gaps struct {
+	Min    time.Duration            // after every command
+	Prefix map[string]time.Duration // after commands with these prefixes
+}
+
+// After returns how long to wait after sending cmd.
+func (g Gaps) After(cmd string) time.Duration {
+	gap := g.Min
+	for prefix, d := range g.Prefix {
+		if strings.HasPrefix(cmd, prefix) && d > gap {
+			gap = d
+		}
+	}
+	return gap
+}
+
+// A Spacer makes commands sent from several goroutines take turns,
+// each waiting out the gap after the one before. It is safe for
+// concurrent use.
+type Spacer struct {
+	gaps Gaps
+
+	mu   sync.Mutex
+	next time.Time // earliest time for the next command
+}
+
+// NewSpacer returns a Spacer keeping commands the given gaps apart.
+func NewSpacer(g Gaps) *Spacer {
+	return &Spacer{gaps: g}
+}
+
+// Wait waits until it is cmd's turn to be sent. It returns ctx.Err()
+// if ctx is done first; the turn is lost then.
+func (s *Spacer) Wait(ctx context.Context, cmd string) error {
+	s.mu.Lock()
+	now := time.Now()
+	turn := s.next
+	if turn.Before(now) {
+		turn = now
+	}
+	s.next = turn.Add(s.gaps.After(cmd))
+	s.mu.Unlock()
+
+	d := turn.Sub(now)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"code.google.com/p/go-avr/avr/avrtest"
+)
+
+// TestShutdownDuringProbe checks that Shutdown waits for every
+// goroutine the Amp started, including a probe's sentinel query still
+// waiting for its answer.
+func TestShutdownDuringProbe(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	sim, err := avrtest.NewSimulator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Close()
+	// Leave the sentinel unanswered, so that it is under way at Close.
+	sim.Respond(func(cmd string) ([]string, bool) {
+		return nil, cmd == probeSentinel
+	})
+	d := &simDialer{addr: sim.Addr()}
+	a := New("amp", WithDialer(d.dial), WithExclusiveConnection(), WithLogger(NopLogger))
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !slices.Contains(sim.Received(), probeSentinel) {
+		if time.Now().After(deadline) {
+			t.Fatal("no probe sentinel sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := a.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	sim.Close()
+}
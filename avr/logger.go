@@ -0,0 +1,43 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import "log/slog"
+
+// A Logger receives an Amp's diagnostic messages. Arguments after
+// msg are alternating keys and values, as with log/slog. A
+// *slog.Logger is a Logger.
+//
+// Lines from the amp that no query was waiting for and every
+// connection attempt are logged at debug level; problems within the
+// package are logged as errors. WithWireLog records every line in
+// both directions.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// WithLogger sets the Amp's Logger. The default is slog.Default(),
+// which discards debug messages unless configured otherwise.
+func WithLogger(l Logger) Option {
+	return func(a *Amp) {
+		if l == nil {
+			l = NopLogger
+		}
+		a.log = l
+	}
+}
+
+// SlogLogger returns a Logger writing to the given slog handler.
+func SlogLogger(h slog.Handler) Logger {
+	return slog.New(h)
+}
+
+// NopLogger is a Logger that discards everything.
+var NopLogger Logger = nopLogger{}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any) {}
+func (nopLogger) Error(string, ...any) {}
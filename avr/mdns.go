@@ -0,0 +1,221 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// mdnsAddr is the mDNS multicast group.
+const mdnsAddr = "224.0.0.251:5353"
+
+// Services advertised by Denon and Marantz receivers.
+var mdnsServices = []string{"_raop._tcp.local.", "_heos-audio._tcp.local."}
+
+// dmOUIs are the Ethernet address prefixes of D&M Holdings, maker of
+// Denon and Marantz receivers.
+var dmOUIs = [][3]byte{{0x00, 0x05, 0xcd}, {0x00, 0x06, 0x78}}
+
+// mdnsInstance is a service instance found by mDNS.
+type mdnsInstance struct {
+	service string
+	name    string // instance name, without service and domain
+	host    string // SRV target
+	txt     map[string]string
+}
+
+// mdnsSearch queries for mdnsServices from an ephemeral port, so
+// responders answer by unicast, and returns the receivers found
+// until ctx is done.
+func mdnsSearch(ctx context.Context) ([]DiscoveredAmp, error) {
+	c, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	dst, err := net.ResolveUDPAddr("udp4", mdnsAddr)
+	if err != nil {
+		return nil, err
+	}
+	q, err := mdnsQuery()
+	if err != nil {
+		return nil, err
+	}
+	for range 2 {
+		if _, err := c.WriteTo(q, dst); err != nil {
+			return nil, err
+		}
+	}
+
+	stop := context.AfterFunc(ctx, func() { c.SetReadDeadline(time.Now()) })
+	defer stop()
+	instances := make(map[string]*mdnsInstance) // by full name
+	addrs := make(map[string]net.IP)            // host name to IPv4 address
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := c.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return nil, err
+		}
+		parseMDNS(buf[:n], instances, addrs)
+	}
+
+	var amps []DiscoveredAmp
+	for _, in := range instances {
+		if d, ok := in.amp(addrs); ok {
+			amps = append(amps, d)
+		}
+	}
+	return amps, nil
+}
+
+func mdnsQuery() ([]byte, error) {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	for _, s := range mdnsServices {
+		name, err := dnsmessage.NewName(s)
+		if err != nil {
+			return nil, err
+		}
+		if err := b.Question(dnsmessage.Question{Name: name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}); err != nil {
+			return nil, err
+		}
+	}
+	return b.Finish()
+}
+
+// parseMDNS adds the service instances and addresses in the answer
+// and additional records of mDNS response msg.
+func parseMDNS(msg []byte, instances map[string]*mdnsInstance, addrs map[string]net.IP) {
+	var m dnsmessage.Message
+	if m.Unpack(msg) != nil || !m.Header.Response {
+		return
+	}
+	instance := func(full string) *mdnsInstance {
+		for _, s := range mdnsServices {
+			name, ok := strings.CutSuffix(full, "."+s)
+			if !ok {
+				continue
+			}
+			in := instances[full]
+			if in == nil {
+				in = &mdnsInstance{service: s, name: unescapeDNS(name)}
+				instances[full] = in
+			}
+			return in
+		}
+		return nil
+	}
+	for _, r := range append(m.Answers, m.Additionals...) {
+		switch body := r.Body.(type) {
+		case *dnsmessage.PTRResource:
+			instance(body.PTR.String())
+		case *dnsmessage.SRVResource:
+			if in := instance(r.Header.Name.String()); in != nil {
+				in.host = body.Target.String()
+			}
+		case *dnsmessage.TXTResource:
+			if in := instance(r.Header.Name.String()); in != nil {
+				in.txt = make(map[string]string)
+				for _, kv := range body.TXT {
+					k, v, _ := strings.Cut(kv, "=")
+					in.txt[strings.ToLower(k)] = v
+				}
+			}
+		case *dnsmessage.AResource:
+			addrs[r.Header.Name.String()] = net.IP(body.A[:])
+		}
+	}
+}
+
+// amp returns the receiver advertising in, if it looks like one.
+func (in *mdnsInstance) amp(addrs map[string]net.IP) (DiscoveredAmp, bool) {
+	ip := addrs[in.host]
+	if ip == nil {
+		return DiscoveredAmp{}, false
+	}
+	d := DiscoveredAmp{
+		Addr:         net.JoinHostPort(ip.String(), controlPort),
+		FriendlyName: in.name,
+		ModelName:    in.txt["am"],
+	}
+	if in.service == "_raop._tcp.local." {
+		// AirPlay instance names are the Ethernet address, an @, and
+		// the device name.
+		mac, name, ok := strings.Cut(in.name, "@")
+		if !ok {
+			return DiscoveredAmp{}, false
+		}
+		b, err := hex.DecodeString(mac)
+		if err != nil || len(b) != 6 || !isDMOUI(b) {
+			return DiscoveredAmp{}, false
+		}
+		d.MAC = net.HardwareAddr(b)
+		d.FriendlyName = name
+		return d, true
+	}
+	if m := in.txt["model"]; m != "" {
+		d.ModelName = m
+	}
+	// HEOS speakers advertise the same service but have no control
+	// port.
+	if strings.HasPrefix(strings.ToUpper(d.ModelName), "HEOS") {
+		return DiscoveredAmp{}, false
+	}
+	return d, true
+}
+
+func isDMOUI(mac []byte) bool {
+	for _, oui := range dmOUIs {
+		if mac[0] == oui[0] && mac[1] == oui[1] && mac[2] == oui[2] {
+			return true
+		}
+	}
+	return false
+}
+
+// unescapeDNS removes the backslash escapes of a DNS label, as in
+// "Living\ Room".
+func unescapeDNS(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+3 < len(s) && isDecimal(s[i+1:i+4]) {
+			n := int(s[i+1]-'0')*100 + int(s[i+2]-'0')*10 + int(s[i+3]-'0')
+			b.WriteByte(byte(n))
+			i += 3
+			continue
+		}
+		i++
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isDecimal(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
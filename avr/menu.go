@@ -0,0 +1,140 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// A Menu navigates the amp's on-screen menus, including the network
+// audio browser, as with the remote's cursor buttons.
+type Menu struct {
+	a *Amp
+}
+
+// Menu returns the amp's on-screen menu.
+func (a *Amp) Menu() *Menu { return &Menu{a: a} }
+
+// CursorUp presses the remote's up button.
+func (m *Menu) CursorUp() error { return m.a.SendCommand("MNCUP") }
+
+// CursorDown presses the remote's down button.
+func (m *Menu) CursorDown() error { return m.a.SendCommand("MNCDN") }
+
+// CursorLeft presses the remote's left button.
+func (m *Menu) CursorLeft() error { return m.a.SendCommand("MNCLT") }
+
+// CursorRight presses the remote's right button.
+func (m *Menu) CursorRight() error { return m.a.SendCommand("MNCRT") }
+
+// Enter presses the remote's enter button.
+func (m *Menu) Enter() error { return m.a.SendCommand("MNENT") }
+
+// Return presses the remote's back button.
+func (m *Menu) Return() error { return m.a.SendCommand("MNRTN") }
+
+// Info presses the remote's info button.
+func (m *Menu) Info() error { return m.a.SendCommand("MNINF") }
+
+// Option presses the remote's option button.
+func (m *Menu) Option() error { return m.a.SendCommand("MNOPT") }
+
+// Setup opens or closes the setup menu.
+func (m *Menu) Setup(open bool) error {
+	if open {
+		return m.a.SendCommand("MNMEN ON")
+	}
+	return m.a.SendCommand("MNMEN OFF")
+}
+
+// A MenuPage is a page of the network audio browser.
+type MenuPage struct {
+	Title    string
+	Items    []MenuItem
+	Selected int // index in Items of the cursor, or -1
+}
+
+// A MenuItem is an entry on a MenuPage.
+type MenuItem struct {
+	Text     string
+	Playable bool // a track or station
+	Folder   bool // opens another page
+}
+
+// Attribute bits of the first byte of a display row.
+const (
+	rowPlayable = 0x01
+	rowFolder   = 0x02
+	rowCursor   = 0x08
+)
+
+// Page asks the amp for its network audio display and returns it as
+// a menu page.
+func (m *Menu) Page(ctx context.Context) (*MenuPage, error) {
+	rows, err := m.a.displayRows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return parseMenuPage(rows), nil
+}
+
+// parseMenuPage parses display rows into a page. Row 0 is the title
+// and each later row is an item, led by a byte of attribute bits.
+func parseMenuPage(rows [displayRows]string) *MenuPage {
+	p := &MenuPage{Title: cleanDisplayRow(rows[0]), Selected: -1}
+	for _, row := range rows[1:] {
+		text := cleanDisplayRow(row)
+		if text == "" {
+			continue
+		}
+		var attr byte
+		if row != "" && row[0] < ' ' {
+			attr = row[0]
+		}
+		if attr&rowCursor != 0 {
+			p.Selected = len(p.Items)
+		}
+		p.Items = append(p.Items, MenuItem{
+			Text:     text,
+			Playable: attr&rowPlayable != 0,
+			Folder:   attr&rowFolder != 0,
+		})
+	}
+	return p
+}
+
+// Choose moves the cursor from its position on page p to the item
+// whose text is text, ignoring case, and presses enter.
+func (m *Menu) Choose(p *MenuPage, text string) error {
+	for i, it := range p.Items {
+		if strings.EqualFold(it.Text, text) {
+			return m.Select(p, i)
+		}
+	}
+	return fmt.Errorf("no menu item %q", text)
+}
+
+// Select moves the cursor from its position on page p to item i and
+// presses enter.
+func (m *Menu) Select(p *MenuPage, i int) error {
+	if i < 0 || i >= len(p.Items) {
+		return fmt.Errorf("menu item %d out of range [0, %d)", i, len(p.Items))
+	}
+	if p.Selected < 0 {
+		return fmt.Errorf("menu page %q has no cursor", p.Title)
+	}
+	move := m.CursorDown
+	n := i - p.Selected
+	if n < 0 {
+		move, n = m.CursorUp, -n
+	}
+	for range n {
+		if err := move(); err != nil {
+			return err
+		}
+	}
+	return m.Enter()
+}
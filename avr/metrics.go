@@ -0,0 +1,42 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import "time"
+
+// Metrics receives measurements of an Amp's activity, for instance
+// to export them to a monitoring system. One Metrics may serve
+// several Amps. Its methods are called from the Amp's goroutines and
+// must not block.
+type Metrics interface {
+	// DialDone is called after each attempt to connect to the amp,
+	// with the attempt's error.
+	DialDone(a *Amp, err error)
+
+	// CommandSent is called with each line written to the amp,
+	// without its trailing carriage return.
+	CommandSent(a *Amp, cmd string)
+
+	// QueryDone is called when a query is answered or fails, with
+	// the time since its command was written. It isn't called for
+	// queries whose callers gave up first, nor for queries that
+	// collect several reply lines.
+	QueryDone(a *Amp, query string, latency time.Duration, err error)
+}
+
+// WithMetrics makes the Amp report its activity to m.
+func WithMetrics(m Metrics) Option {
+	return func(a *Amp) {
+		if m == nil {
+			m = nopMetrics{}
+		}
+		a.metrics = m
+	}
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) DialDone(*Amp, error)                         {}
+func (nopMetrics) CommandSent(*Amp, string)                     {}
+func (nopMetrics) QueryDone(*Amp, string, time.Duration, error) {}
@@ -0,0 +1,25 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import "code.google.com/p/go-avr/avr/proto"
+
+// Mute mutes or unmutes the main zone. It returns once the amp has
+// acknowledged with MUON or MUOFF.
+func (a *Amp) Mute(on bool) error {
+	cmd := "MUOFF"
+	if on {
+		cmd = "MUON"
+	}
+	return a.setConfirm(cmd, is[proto.Mute], cmd)
+}
+
+// IsMuted reports whether the main zone is muted.
+func (a *Amp) IsMuted() (bool, error) {
+	l, err := a.timeoutQuery("MU?", is[proto.Mute])
+	if err != nil {
+		return false, err
+	}
+	return proto.Parse(l).(proto.Mute).On, nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// NowPlaying is what the amp's network audio display shows for the
+// NET input, parsed from its rows. Fields the display doesn't show
+// are empty.
+type NowPlaying struct {
+	Source  string // such as "Spotify" or "Internet Radio"
+	Track   string
+	Artist  string
+	Album   string
+	Station string        // for radio sources
+	Elapsed time.Duration // -1 if not shown
+}
+
+// displayRows is the number of rows of the network audio display.
+const displayRows = 9
+
+// display is the state of the network audio display.
+type display struct {
+	rows       [displayRows]string
+	refreshing bool // rows 0 through 8 are arriving
+	np         NowPlaying
+}
+
+// NowPlaying asks the amp for its network audio display and returns
+// what it shows.
+func (a *Amp) NowPlaying(ctx context.Context) (NowPlaying, error) {
+	rows, err := a.displayRows(ctx)
+	if err != nil {
+		return NowPlaying{}, err
+	}
+	return parseNowPlaying(rows), nil
+}
+
+// displayRows asks the amp for the rows of its network audio
+// display, as sent.
+func (a *Amp) displayRows(ctx context.Context) (rows [displayRows]string, err error) {
+	ctx, cancel := a.timeoutContext(ctx)
+	defer cancel()
+	end := func(l string) bool {
+		d, ok := proto.Parse(l).(proto.Display)
+		return ok && d.Row == displayRows-1
+	}
+	lines, err := a.queryLines(ctx, "NSE", is[proto.Display], end, displayQuiet)
+	if err != nil && len(lines) == 0 {
+		return rows, err
+	}
+	for _, l := range lines {
+		d := proto.Parse(l).(proto.Display)
+		rows[d.Row] = d.Text
+	}
+	return rows, nil
+}
+
+// displayQuiet is how long NowPlaying waits for further display rows
+// once the amp has started answering.
+const displayQuiet = 300 * time.Millisecond
+
+// updateDisplay applies a display line to the tracked display and
+// publishes NowPlayingChanged once a refresh is complete or a single
+// row changes.
+//
+// must be called with mu held
+func (a *Amp) updateDisplay(d proto.Display) {
+	ds := &a.display
+	ds.rows[d.Row] = d.Text
+	switch d.Row {
+	case 0:
+		ds.refreshing = true
+	case displayRows - 1:
+		ds.refreshing = false
+	}
+	if ds.refreshing {
+		return
+	}
+	if np := parseNowPlaying(ds.rows); np != ds.np {
+		ds.np = np
+		a.publish(NowPlayingChanged{NowPlaying: np})
+	}
+}
+
+// parseNowPlaying interprets the display rows. Row 0 is the title,
+// "Now Playing" and the source. For radio, rows 1 and 2 are the
+// station and track; otherwise rows 1, 2 and 4 are the track, artist
+// and album. The elapsed time is on a later row.
+func parseNowPlaying(rows [displayRows]string) NowPlaying {
+	var r [displayRows]string
+	for i, row := range rows {
+		r[i] = cleanDisplayRow(row)
+	}
+	np := NowPlaying{Elapsed: -1}
+	src, ok := strings.CutPrefix(r[0], "Now Playing")
+	if !ok {
+		return np // browsing, not playing
+	}
+	np.Source = strings.TrimSpace(src)
+	if strings.Contains(strings.ToLower(np.Source), "radio") {
+		np.Station, np.Track = r[1], r[2]
+	} else {
+		np.Track, np.Artist, np.Album = r[1], r[2], r[4]
+	}
+	for _, row := range r[5:] {
+		if d, ok := parseElapsed(row); ok {
+			np.Elapsed = d
+			break
+		}
+	}
+	return np
+}
+
+// cleanDisplayRow removes the cursor and attribute characters the
+// amp puts before a row's text, and surrounding spaces.
+func cleanDisplayRow(s string) string {
+	s = strings.TrimLeftFunc(s, func(r rune) bool { return r < ' ' || r == 0x7f })
+	return strings.TrimSpace(s)
+}
+
+// parseElapsed parses a row beginning with a time such as "1:23" or
+// "1:02:03", optionally followed by a progress percentage.
+func parseElapsed(row string) (time.Duration, bool) {
+	f := strings.Fields(row)
+	if len(f) == 0 {
+		return 0, false
+	}
+	parts := strings.Split(f[0], ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	var d time.Duration
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || (i > 0 && (len(p) != 2 || n > 59)) {
+			return 0, false
+		}
+		d = d*60 + time.Duration(n)
+	}
+	return d * time.Second, true
+}
@@ -0,0 +1,79 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Defaults for the corresponding options.
+const (
+	DefaultDialTimeout    = 10 * time.Second
+	DefaultCommandTimeout = 5 * time.Second
+)
+
+// An Option configures an Amp in New.
+type Option func(*Amp)
+
+// WithDialTimeout limits how long each connection attempt may take.
+// Zero means no limit.
+func WithDialTimeout(d time.Duration) Option {
+	return func(a *Amp) { a.dialer.Timeout = d }
+}
+
+// WithCommandTimeout sets how long methods without a context wait
+// for the amp to answer, and how long Query waits when its context
+// has no deadline.
+func WithCommandTimeout(d time.Duration) Option {
+	return func(a *Amp) { a.cmdTimeout = d }
+}
+
+// WithKeepAlive sets the TCP keep-alive period of the connection to
+// the amp. Zero uses the system default and a negative duration
+// disables keep-alives.
+func WithKeepAlive(d time.Duration) Option {
+	return func(a *Amp) { a.dialer.KeepAlive = d }
+}
+
+// WithLocalAddr makes the Amp connect to the amp from local IP
+// address ip, pinning the connection to the interface with that
+// address, as when the amp is only reachable on one VLAN. It has no
+// effect on connections made WithDialer or with a Transport.
+func WithLocalAddr(ip net.IP) Option {
+	return func(a *Amp) {
+		if ip == nil {
+			a.dialer.LocalAddr = nil
+			return
+		}
+		a.dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+}
+
+// WithResolver makes the Amp look up the amp's host name with r,
+// such as a Resolver using the installation's own DNS server. The
+// default is the system resolver.
+func WithResolver(r *net.Resolver) Option {
+	return func(a *Amp) { a.dialer.Resolver = r }
+}
+
+// WithFallbackDelay sets how long the Amp waits for a connection to
+// the amp's first address before also trying an address of the other
+// family, when its host name has both IPv6 and IPv4 addresses. Zero
+// uses the net package's default of 300ms and a negative duration
+// tries the addresses one after the other.
+func WithFallbackDelay(d time.Duration) Option {
+	return func(a *Amp) { a.dialer.FallbackDelay = d }
+}
+
+// WithDialer makes the Amp connect with dial instead of a TCP dial
+// to its address, for instance to go through an SSH tunnel or SOCKS
+// proxy, or to connect to a fake amp. dial is called with network
+// "tcp" and the address given to New. Its context is done when the
+// dial timeout expires or the Amp is closed. WithKeepAlive has no
+// effect on connections made by dial.
+func WithDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) Option {
+	return func(a *Amp) { a.dial = dial }
+}
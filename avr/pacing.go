@@ -0,0 +1,193 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.google.com/p/go-avr/avr/internal/pace"
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// DefaultCommandGap is the default minimum time between commands.
+// The amp may drop or misread commands sent closer together.
+const DefaultCommandGap = 50 * time.Millisecond
+
+// defaultCommandGaps returns the gaps needed after commands the amp
+// is slow to act on, by command prefix.
+func defaultCommandGaps() map[string]time.Duration {
+	return map[string]time.Duration{
+		"PWON": time.Second,
+		"ZMON": time.Second,
+	}
+}
+
+// WithCommandGap sets the minimum time between commands sent to the
+// amp.
+func WithCommandGap(d time.Duration) Option {
+	return func(a *Amp) { a.gaps.Min = d }
+}
+
+// WithSlowCommand makes the Amp wait at least d after sending any
+// command beginning with prefix before sending the next one. By
+// default PWON and ZMON wait one second.
+func WithSlowCommand(prefix string, d time.Duration) Option {
+	return func(a *Amp) { a.gaps.Prefix[prefix] = d }
+}
+
+// QueueDepth returns the number of commands waiting for their turn
+// to be sent to the amp.
+func (a *Amp) QueueDepth() int {
+	return int(a.queueDepth.Load())
+}
+
+// A pacer holds requests that write to the amp until it is their
+// turn. Power commands jump ahead of the others, and a command setting
+// a volume replaces any queued command setting the same volume. It is
+// only used by the loop goroutine.
+type pacer struct {
+	gaps  pace.Gaps
+	next  time.Time // earliest time for the next write
+	queue []request
+	timer *time.Timer // non-nil while waiting for next
+}
+
+func newPacer(gaps pace.Gaps) *pacer {
+	return &pacer{gaps: gaps}
+}
+
+// maxQueue is the most requests a pacer holds before failing new
+// ones with ErrBusy.
+const maxQueue = 256
+
+func (p *pacer) push(req request) {
+	m := proto.Parse(strings.TrimSuffix(req.raw, "\r"))
+	if key := coalesceKey(m); key != "" {
+		for i, q := range p.queue {
+			if coalesceKey(proto.Parse(strings.TrimSuffix(q.raw, "\r"))) == key {
+				supersede(q)
+				p.queue[i] = req
+				return
+			}
+		}
+	}
+	if len(p.queue) >= maxQueue {
+		req.ch <- &response{err: ErrBusy}
+		return
+	}
+	if !isPowerCommand(m) {
+		p.queue = append(p.queue, req)
+		return
+	}
+	i := 0 // after any queued power commands
+	for i < len(p.queue) && isPowerCommand(proto.Parse(strings.TrimSuffix(p.queue[i].raw, "\r"))) {
+		i++
+	}
+	p.queue = slices.Insert(p.queue, i, req)
+}
+
+// Len returns the number of queued requests.
+func (p *pacer) Len() int {
+	return len(p.queue)
+}
+
+// supersede fails req, replaced in the queue by a later request,
+// with ErrSuperseded, whether or not it was waiting for a reply.
+func supersede(req request) {
+	select {
+	case req.ch <- &response{err: ErrSuperseded}:
+	default:
+	}
+}
+
+// coalesceKey returns the parameter that command m sets to an
+// absolute volume, such as "MV" or "Z2", or "" if it doesn't.
+func coalesceKey(m proto.Message) string {
+	switch m := m.(type) {
+	case proto.Volume:
+		return "MV"
+	case proto.Zone:
+		if _, ok := m.Msg.(proto.Volume); ok {
+			return "Z" + strconv.Itoa(m.Zone)
+		}
+	case proto.ChannelLevel:
+		return "CV" + m.Channel
+	}
+	return ""
+}
+
+// isPowerCommand reports whether command m turns the amp or a zone
+// on or off.
+func isPowerCommand(m proto.Message) bool {
+	switch m := m.(type) {
+	case proto.Power:
+		return true
+	case proto.Zone:
+		_, ok := m.Msg.(proto.Power)
+		return ok
+	}
+	return false
+}
+
+// C returns a channel that receives when the pacer is waiting for
+// its next turn, or nil.
+func (p *pacer) C() <-chan time.Time {
+	if p.timer == nil {
+		return nil
+	}
+	return p.timer.C
+}
+
+// hold makes the next request wait until t, when the amp is busy.
+func (p *pacer) hold(t time.Time) {
+	if t.After(p.next) {
+		p.next = t
+	}
+}
+
+// fired must be called after receiving from C.
+func (p *pacer) fired() {
+	p.timer = nil
+}
+
+// pop returns the next request if it is its turn at time now. It
+// skips requests whose callers have given up, and arms the timer if
+// the next request must wait.
+func (p *pacer) pop(now time.Time) (request, bool) {
+	for len(p.queue) > 0 {
+		if now.Before(p.next) {
+			if p.timer == nil {
+				p.timer = time.NewTimer(p.next.Sub(now))
+			}
+			return request{}, false
+		}
+		req := p.queue[0]
+		p.queue[0] = request{}
+		p.queue = p.queue[1:]
+		if req.ctx != nil && req.ctx.Err() != nil {
+			continue
+		}
+		p.next = now.Add(p.gaps.After(req.raw))
+		return req, true
+	}
+	return request{}, false
+}
+
+// fail answers every queued request with err.
+func (p *pacer) fail(err error) {
+	for _, req := range p.queue {
+		select {
+		case req.ch <- &response{err: err}:
+		default:
+		}
+	}
+	p.queue = nil
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Many settings share a common form: a parameter name such as
+// "PSDYNEQ " or "PSMULTEQ:", including its separator, followed by a
+// value. The amp answers "PSDYNEQ ?" with the current line such as
+// "PSDYNEQ ON".
+
+// setParam sets parameter p to v and waits for the amp to confirm.
+func (a *Amp) setParam(p, v string) error {
+	cmd := p + v
+	return a.setConfirm(cmd, prefix(p), cmd)
+}
+
+// param returns the value of parameter p.
+func (a *Amp) param(p string) (string, error) {
+	l, err := a.timeoutQuery(strings.TrimSpace(p)+" ?", prefix(p))
+	if err != nil {
+		return "", err
+	}
+	return l[len(p):], nil
+}
+
+// setOnOff sets parameter p to ON or OFF.
+func (a *Amp) setOnOff(p string, on bool) error {
+	if on {
+		return a.setParam(p, "ON")
+	}
+	return a.setParam(p, "OFF")
+}
+
+// onOff reports whether parameter p is ON.
+func (a *Amp) onOff(p string) (bool, error) {
+	v, err := a.param(p)
+	if err != nil {
+		return false, err
+	}
+	return parseOnOff(p, v)
+}
+
+func parseOnOff(p, v string) (bool, error) {
+	switch v {
+	case "ON":
+		return true, nil
+	case "OFF":
+		return false, nil
+	}
+	return false, fmt.Errorf("unexpected %s value %q", strings.TrimSpace(p), v)
+}
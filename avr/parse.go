@@ -0,0 +1,38 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"fmt"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// parseAs parses amp line l, which must be a message of type T.
+func parseAs[T proto.Message](l string) (T, error) {
+	m, ok := proto.Parse(l).(T)
+	if !ok {
+		return m, fmt.Errorf("unexpected reply %q", l)
+	}
+	return m, nil
+}
+
+// is is a query match func for lines that parse as type T.
+func is[T proto.Message](l string) bool {
+	_, ok := proto.Parse(l).(T)
+	return ok
+}
+
+// zoneIs returns a query match func for lines about zone n that
+// parse as a proto.Zone holding a message of type T.
+func zoneIs[T proto.Message](n int) func(string) bool {
+	return func(l string) bool {
+		z, ok := proto.Parse(l).(proto.Zone)
+		if !ok || z.Zone != n {
+			return false
+		}
+		_, ok = z.Msg.(T)
+		return ok
+	}
+}
@@ -0,0 +1,358 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+// Package pioneer controls Pioneer and Pioneer Elite receivers over
+// their IP control protocol, served on TCP port 8102 and, on older
+// models, port 23. A Receiver implements avr.Receiver, so code
+// written against that interface works with Pioneer amps as well as
+// Denon, Marantz, Onkyo and Yamaha ones.
+//
+// Commands are short codes such as "PO" (power on) or "?V" (query
+// volume); the receiver answers with reports such as "PWR0" or
+// "VOL121", each ended by a carriage return.
+package pioneer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.google.com/p/go-avr/avr"
+	"code.google.com/p/go-avr/avr/internal/link"
+	"code.google.com/p/go-avr/avr/internal/pace"
+)
+
+// DefaultPort is the TCP port receivers serve IP control on.
+const DefaultPort = 8102
+
+// DefaultTimeout is how long commands wait for the receiver to
+// answer.
+const DefaultTimeout = 5 * time.Second
+
+// Errors the receiver reports.
+var (
+	// ErrNotAvailable means the receiver answered "E02": the command
+	// isn't available in its current state.
+	ErrNotAvailable = errors.New("not available now")
+
+	// ErrInvalid means the receiver answered "E03", "E04" or "E06":
+	// its model has no such command or parameter.
+	ErrInvalid = errors.New("invalid command")
+
+	// ErrBusy means the receiver answered "B00": it is busy, as just
+	// after powering on.
+	ErrBusy = errors.New("receiver busy")
+)
+
+// A Receiver is a Pioneer receiver. It connects on first use and
+// reconnects after the connection breaks. It is safe for concurrent
+// use.
+type Receiver struct {
+	c *link.Client
+}
+
+// The package registers brand "pioneer" for avr.LoadConfig.
+func init() {
+	open := func(addr string) avr.Receiver { return New(addr) }
+	avr.RegisterBrand("pioneer", open)
+}
+
+// New returns a Receiver for the receiver at addr, which defaults to
+// DefaultPort if it has no port.
+func New(addr string) *Receiver {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, strconv.Itoa(DefaultPort))
+	}
+	return &Receiver{c: link.New(addr, DefaultTimeout, protocol)}
+}
+
+// protocol is Pioneer IP control for link.Client.
+var protocol = link.Protocol{
+	// Receivers in standby drop the first command they are sent
+	// while waking, so each command is preceded by an empty one.
+	Write: func(w io.Writer, cmd string) error {
+		_, err := io.WriteString(w, "\r"+cmd+"\r")
+		return err
+	},
+	Read:  readLine,
+	Split: split,
+	Error: replyError,
+	Event: event,
+	Gaps: pace.Gaps{
+		Min: avr.DefaultCommandGap,
+		// The receiver ignores commands while powering on.
+		Prefix: map[string]time.Duration{"PO": time.Second},
+	},
+}
+
+// maxLineLen bounds the lines readLine accepts.
+const maxLineLen = 1024
+
+// readLine reads a report and returns it without its line ending.
+// Empty lines are skipped.
+func readLine(r *bufio.Reader) (string, error) {
+	for {
+		l, err := r.ReadString('\r')
+		if err != nil {
+			return "", err
+		}
+		if len(l) > maxLineLen {
+			return "", fmt.Errorf("report too long (%d bytes)", len(l))
+		}
+		if l = strings.Trim(l, "\r\n"); l != "" {
+			return l, nil
+		}
+	}
+}
+
+// split splits a report such as "VOL121" into its parameter, the
+// leading letters "VOL", and its value, "121".
+func split(l string) (p, v string, ok bool) {
+	i := 0
+	for i < len(l) && 'A' <= l[i] && l[i] <= 'Z' {
+		i++
+	}
+	if i == 0 {
+		return "", "", false
+	}
+	return l[:i], l[i:], true
+}
+
+// replyError returns the error for an error reply, or nil.
+func replyError(l string) error {
+	switch l {
+	case "E02":
+		return ErrNotAvailable
+	case "E03", "E04", "E06":
+		return ErrInvalid
+	case "B00":
+		return ErrBusy
+	}
+	return nil
+}
+
+// Close closes the connection and every subscription channel.
+func (r *Receiver) Close() error { return r.c.Close() }
+
+// Volume levels. Receivers step volume by half a decibel from level
+// 1, -80dB, to level 185, +12dB; level 0 is silence.
+const (
+	levelZero = 161
+	maxLevel  = 185
+)
+
+// PowerOn turns the receiver on.
+func (r *Receiver) PowerOn() error { return r.set("PO", "?P", "PWR") }
+
+// PowerOff puts the receiver in standby.
+func (r *Receiver) PowerOff() error { return r.set("PF", "?P", "PWR") }
+
+// PowerState reports whether the receiver is on.
+func (r *Receiver) PowerState() (on bool, err error) {
+	v, err := r.c.RoundTrip("?P", "PWR")
+	return v == "0", err
+}
+
+// SetVolume sets the master volume to db, rounded to the nearest
+// half step.
+func (r *Receiver) SetVolume(db float64) error {
+	level := int(math.Round(db*2)) + levelZero
+	if db <= avr.MinVolume {
+		level = 0
+	}
+	if level < 0 || level > maxLevel {
+		return fmt.Errorf("volume %vdB out of range [%v, %v]", db, avr.MinVolume, float64(maxLevel-levelZero)/2)
+	}
+	return r.set(fmt.Sprintf("%03dVL", level), "?V", "VOL")
+}
+
+// GetVolume returns the master volume in dB.
+func (r *Receiver) GetVolume() (db float64, err error) {
+	v, err := r.c.RoundTrip("?V", "VOL")
+	if err != nil {
+		return 0, err
+	}
+	return parseVolume(v)
+}
+
+// VolumeUp raises the master volume by half a decibel.
+func (r *Receiver) VolumeUp() error { return r.set("VU", "?V", "VOL") }
+
+// VolumeDown lowers the master volume by half a decibel.
+func (r *Receiver) VolumeDown() error { return r.set("VD", "?V", "VOL") }
+
+// Mute mutes or unmutes the receiver.
+func (r *Receiver) Mute(on bool) error {
+	if on {
+		return r.set("MO", "?M", "MUT")
+	}
+	return r.set("MF", "?M", "MUT")
+}
+
+// IsMuted reports whether the receiver is muted.
+func (r *Receiver) IsMuted() (bool, error) {
+	v, err := r.c.RoundTrip("?M", "MUT")
+	return v == "0", err
+}
+
+// SelectInput switches to src, which may also be "FN" followed by a
+// Pioneer input number, as CurrentInput returns for inputs such as
+// HDMI 1, "FN19". Other sources without a Pioneer input number
+// return avr.ErrUnsupported.
+func (r *Receiver) SelectInput(src avr.InputSource) error {
+	n, ok := inputNumbers[src]
+	if s, found := strings.CutPrefix(string(src), "FN"); found && len(s) == 2 {
+		n, ok = s, true
+	}
+	if !ok {
+		return fmt.Errorf("input %s: %w", src, avr.ErrUnsupported)
+	}
+	return r.set(n+"FN", "?F", "FN")
+}
+
+// CurrentInput returns the selected source.
+func (r *Receiver) CurrentInput() (avr.InputSource, error) {
+	v, err := r.c.RoundTrip("?F", "FN")
+	if err != nil {
+		return "", err
+	}
+	return inputSource(v), nil
+}
+
+// Subscribe implements avr.Receiver. Typed events are sent when the
+// receiver reports a change to power, volume, muting or the input;
+// other reports are sent as avr.RawLine. Subscribe connects to the
+// receiver if needed.
+func (r *Receiver) Subscribe() (events <-chan avr.Event, cancel func()) {
+	return r.c.Subscribe()
+}
+
+// SendRaw implements avr.Receiver. cmd is a command such as "VU";
+// SendRaw adds the carriage returns.
+func (r *Receiver) SendRaw(ctx context.Context, cmd string) error {
+	return r.c.Send(ctx, cmd)
+}
+
+// QueryRaw implements avr.Receiver. cmd is a command such as "?V".
+// Pioneer reports don't name the command they answer, so the reply
+// is simply the next report the receiver sends, such as "VOL121";
+// if the receiver reports an unrelated change at the same moment,
+// that report may be returned instead. Error replies are returned
+// as ErrNotAvailable, ErrInvalid or ErrBusy.
+func (r *Receiver) QueryRaw(ctx context.Context, cmd string) (string, error) {
+	return r.c.Query(ctx, cmd, "")
+}
+
+// set sends cmd and waits for the receiver to report parameter p.
+// Receivers don't always report a parameter that cmd leaves
+// unchanged, so set sends query q too.
+func (r *Receiver) set(cmd, q, p string) error {
+	_, err := r.c.RoundTrip(cmd+"\r"+q, p)
+	if errors.Is(err, ErrNotAvailable) || errors.Is(err, ErrInvalid) || errors.Is(err, ErrBusy) {
+		return fmt.Errorf("%s: %w", cmd, err)
+	}
+	return err
+}
+
+// event returns the event for a report with parameter p and value
+// v, or nil.
+func event(p, v string) avr.Event {
+	switch p {
+	case "PWR":
+		if v == "0" || v == "1" || v == "2" {
+			return avr.PowerChanged{On: v == "0"}
+		}
+	case "MUT":
+		if v == "0" || v == "1" {
+			return avr.MuteChanged{Muted: v == "0"}
+		}
+	case "VOL":
+		if db, err := parseVolume(v); err == nil {
+			return avr.VolumeChanged{Volume: db}
+		}
+	case "FN":
+		if len(v) == 2 {
+			return avr.InputChanged{Input: inputSource(v)}
+		}
+	}
+	return nil
+}
+
+// parseVolume parses a VOL value, a three digit level, into dB.
+func parseVolume(v string) (float64, error) {
+	n, err := strconv.Atoi(v)
+	if err != nil || len(v) != 3 || n < 0 || n > maxLevel {
+		return 0, fmt.Errorf("invalid volume %q", v)
+	}
+	if n == 0 {
+		return avr.MinVolume, nil
+	}
+	return float64(n-levelZero) / 2, nil
+}
+
+// inputNumbers maps input sources to Pioneer input numbers.
+var inputNumbers = map[avr.InputSource]string{
+	avr.SourcePhono:     "00",
+	avr.SourceCD:        "01",
+	avr.SourceTuner:     "02",
+	avr.SourceDVD:       "04",
+	avr.SourceTV:        "05",
+	avr.SourceSatCbl:    "06",
+	avr.SourceSat:       "06",
+	avr.SourceAux1:      "10",
+	avr.SourceAux2:      "14",
+	avr.SourceDVR:       "15",
+	avr.SourceUSB:       "17",
+	avr.SourceIPod:      "17",
+	avr.SourceBD:        "25",
+	avr.SourceNet:       "26",
+	avr.SourceNetUSB:    "26",
+	avr.SourceBluetooth: "33",
+	avr.SourceIRadio:    "38",
+	avr.SourcePandora:   "41",
+	avr.SourceServer:    "44",
+	avr.SourceFavorites: "45",
+	avr.SourceGame:      "49",
+}
+
+// inputSources maps Pioneer input numbers to the input sources
+// reported for them.
+var inputSources = map[string]avr.InputSource{
+	"00": avr.SourcePhono,
+	"01": avr.SourceCD,
+	"02": avr.SourceTuner,
+	"04": avr.SourceDVD,
+	"05": avr.SourceTV,
+	"06": avr.SourceSatCbl,
+	"10": avr.SourceAux1,
+	"14": avr.SourceAux2,
+	"15": avr.SourceDVR,
+	"17": avr.SourceUSB,
+	"25": avr.SourceBD,
+	"26": avr.SourceNet,
+	"33": avr.SourceBluetooth,
+	"38": avr.SourceIRadio,
+	"41": avr.SourcePandora,
+	"44": avr.SourceServer,
+	"45": avr.SourceFavorites,
+	"49": avr.SourceGame,
+}
+
+// inputSource returns the input source for input number v; numbers
+// without one, such as the HDMI inputs from 19, are returned as "FN"
+// followed by the number.
+func inputSource(v string) avr.InputSource {
+	if src, ok := inputSources[v]; ok {
+		return src
+	}
+	return avr.InputSource("FN" + v)
+}
+
+var _ avr.Receiver = (*Receiver)(nil)
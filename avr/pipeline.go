@@ -0,0 +1,95 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// A Pipeline queues commands for the amp without waiting for each to
+// be written before queueing the next, keeping at most a fixed number
+// in flight. It is meant for pushing a series of commands, such as a
+// scene's, from one goroutine. Queued commands go out in order at the
+// usual pace, except that power commands go first and a volume
+// command replaces a queued one for the same volume, which then
+// fails with ErrSuperseded. Err doesn't report that failure, as the
+// later command carries out the replaced one's intent.
+type Pipeline struct {
+	a   *Amp
+	sem chan struct{} // one token per command in flight
+	wg  sync.WaitGroup
+
+	mu  sync.Mutex
+	err error // first error from a command
+}
+
+// Pipeline returns a Pipeline keeping up to window commands in
+// flight. A window below 1 is treated as 1.
+func (a *Amp) Pipeline(window int) *Pipeline {
+	return &Pipeline{a: a, sem: make(chan struct{}, max(window, 1))}
+}
+
+// Send queues cmd after the commands sent before it, waiting only
+// if the window is full; see Pipeline for the order they go out in.
+// It returns the first error of an earlier command, if any, without
+// sending cmd. Commands are sent as with SendCommand, but WithAck is
+// not applied.
+func (p *Pipeline) Send(ctx context.Context, cmd string) error {
+	if err := p.Err(); err != nil {
+		return err
+	}
+	if err := p.a.checkStrict(cmd); err != nil {
+		return err
+	}
+	if err := p.a.checkRawVolume(cmd); err != nil {
+		return err
+	}
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	req := request{ch: make(chan *response, 1), cmd: rawCmd, raw: cmd}
+	if err := p.a.submit(ctx, &req); err != nil {
+		<-p.sem
+		return err
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer func() { <-p.sem }()
+		var err error
+		select {
+		case res := <-req.ch:
+			err = res.err
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err != nil && !errors.Is(err, ErrSuperseded) {
+			p.mu.Lock()
+			if p.err == nil {
+				p.err = err
+			}
+			p.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+// Err returns the first error of a command sent so far, other than
+// ErrSuperseded, if any.
+func (p *Pipeline) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// Wait waits for every command sent to be written to the amp and
+// returns the first error, if any.
+func (p *Pipeline) Wait() error {
+	p.wg.Wait()
+	return p.Err()
+}
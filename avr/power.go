@@ -0,0 +1,72 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"fmt"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// A StateError is returned when the amp answers a command by
+// reporting a state other than the one requested.
+type StateError struct {
+	Cmd  string // command sent, such as "PWON"
+	Want string // reply expected, such as "PWON"
+	Got  string // reply received
+}
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("%s: amp reported %q; want %q", e.Cmd, e.Got, e.Want)
+}
+
+// PowerOn turns the amp on and waits for it to confirm.
+func (a *Amp) PowerOn() error {
+	return a.setConfirm("PWON", is[proto.Power], "PWON")
+}
+
+// PowerOff puts the amp into standby and waits for it to confirm.
+func (a *Amp) PowerOff() error {
+	return a.setConfirm("PWSTANDBY", is[proto.Power], "PWSTANDBY")
+}
+
+// poweredOn notes that the amp has come out of standby, and so
+// answers queries it ignored while off.
+//
+// must be called with mu held
+func (a *Amp) poweredOn() {
+	a.startDetect()
+	a.noSignalInfo = false
+	a.noHeadphones = false
+}
+
+// PowerState reports whether the amp is on.
+func (a *Amp) PowerState() (on bool, err error) {
+	l, err := a.timeoutQuery("PW?", is[proto.Power])
+	if err != nil {
+		return false, err
+	}
+	return proto.Parse(l).(proto.Power).On, nil
+}
+
+// setConfirm sends cmd and waits for the amp's reply line accepted
+// by match, returning a *StateError unless the reply is want.
+func (a *Amp) setConfirm(cmd string, match func(string) bool, want string) error {
+	return a.setConfirmContext(context.Background(), cmd, match, want)
+}
+
+// setConfirmContext is like setConfirm but gives up once ctx is done.
+func (a *Amp) setConfirmContext(ctx context.Context, cmd string, match func(string) bool, want string) error {
+	ctx, cancel := a.commandContext(ctx, cmd)
+	defer cancel()
+	l, err := a.query(ctx, cmd, match)
+	if err != nil {
+		return timeoutErr(err)
+	}
+	if l != want {
+		return &StateError{Cmd: cmd, Want: want, Got: l}
+	}
+	return nil
+}
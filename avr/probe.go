@@ -0,0 +1,102 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"time"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// A probeResult is what probing the amp with a query found out.
+type probeResult int
+
+const (
+	probeUnknown  probeResult = iota // the amp answered nothing in time
+	probeAnswered                    // the amp answered the query
+	probeIgnored                     // the amp answered a later query but not this one
+)
+
+// probeSentinel is the query sent after a probe goes unanswered. Every
+// model answers it, and the amp answers queries in order, so if it
+// answers the sentinel but not the probe, it ignored the probe rather
+// than being slow to answer.
+const probeSentinel = "PW?"
+
+// probeQuery sends query cmd, for a feature that some models lack,
+// and waits up to wait from when it is written, rather than from
+// when it is queued, for a line accepted by match. If none arrives,
+// it asks probeSentinel to tell whether the amp ignored cmd: the
+// result is probeIgnored only if the amp answered the sentinel, and
+// probeUnknown, with an error, if it answered neither.
+func (a *Amp) probeQuery(ctx context.Context, cmd string, match func(string) bool, wait time.Duration) (string, probeResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // drops the query if unanswered
+	req := request{
+		ch:      make(chan *response, 1),
+		cmd:     queryCmd,
+		raw:     cmd,
+		match:   match,
+		written: make(chan struct{}),
+	}
+	if err := a.submit(ctx, &req); err != nil {
+		return "", probeUnknown, err
+	}
+	select {
+	case <-req.written:
+	case res := <-req.ch: // failed to write
+		return "", probeUnknown, res.err
+	case <-ctx.Done():
+		return "", probeUnknown, ctx.Err()
+	}
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case res := <-req.ch:
+		return probeAnswer(res)
+	case <-t.C:
+	case <-ctx.Done():
+		return "", probeUnknown, ctx.Err()
+	}
+
+	sctx, scancel := a.timeoutContext(ctx)
+	defer scancel()
+	sentinel := make(chan error, 1)
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return "", probeUnknown, ErrClosed
+	}
+	a.wg.Add(1)
+	a.mu.Unlock()
+	go func() {
+		defer a.wg.Done()
+		_, err := a.query(sctx, probeSentinel, is[proto.Power])
+		sentinel <- err
+	}()
+	select {
+	case res := <-req.ch:
+		return probeAnswer(res)
+	case err := <-sentinel:
+		select {
+		case res := <-req.ch: // answered just before the sentinel
+			return probeAnswer(res)
+		default:
+		}
+		if err != nil {
+			return "", probeUnknown, timeoutErr(err)
+		}
+		return "", probeIgnored, nil
+	}
+}
+
+// probeAnswer returns probeQuery's results for res.
+func probeAnswer(res *response) (string, probeResult, error) {
+	if res.err != nil {
+		return "", probeUnknown, res.err
+	}
+	return res.line, probeAnswered, nil
+}
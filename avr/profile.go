@@ -0,0 +1,87 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Profile describes how a brand's amps present the shared
+// protocol to their users. Denon and Marantz amps accept the same
+// commands, with the same volume encoding, ranges and zone commands,
+// so the Amp sends and parses them the same way for either; they
+// differ only in how the front panel and on-screen display show
+// volume, which a Profile converts to and from.
+type Profile struct {
+	Brand string
+	Scale VolumeScale // the scale the amp's own displays use
+}
+
+// VolumeScale is a way of showing the master volume.
+type VolumeScale int
+
+const (
+	// VolumeAbsolute shows volume as a level from 0 to 98, where 80
+	// is 0dB, as Denon amps do by default.
+	VolumeAbsolute VolumeScale = iota
+
+	// VolumeRelative shows volume in dB from -80 to +18, as
+	// Marantz amps do.
+	VolumeRelative
+)
+
+// Profiles of the supported brands.
+var (
+	DenonProfile   = Profile{Brand: "Denon", Scale: VolumeAbsolute}
+	MarantzProfile = Profile{Brand: "Marantz", Scale: VolumeRelative}
+)
+
+// Profile returns the amp's profile: MarantzProfile for amps
+// detected as Marantz and DenonProfile for any other.
+func (a *Amp) Profile() Profile {
+	if a.Capabilities().Brand == MarantzProfile.Brand {
+		return MarantzProfile
+	}
+	return DenonProfile
+}
+
+// FormatVolume formats db as the amp's displays would show it, such
+// as "45.5" for -34.5dB on VolumeAbsolute and "-34.5dB" on
+// VolumeRelative. MinVolume is "---" on either.
+func (p Profile) FormatVolume(db float64) string {
+	if db <= MinVolume {
+		return "---"
+	}
+	if p.Scale == VolumeAbsolute {
+		db += zeroLevel
+		return strconv.FormatFloat(db, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(db, 'f', 1, 64) + "dB"
+}
+
+// ParseVolume parses a volume shown on the amp's displays, as
+// formatted by FormatVolume, into dB. On VolumeRelative the "dB"
+// suffix is optional.
+func (p Profile) ParseVolume(s string) (db float64, err error) {
+	s = strings.TrimSpace(s)
+	if s == "---" {
+		return MinVolume, nil
+	}
+	if p.Scale == VolumeRelative {
+		s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(s, "dB"), "DB"))
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid volume %q", s)
+	}
+	if p.Scale == VolumeAbsolute {
+		v -= zeroLevel
+	}
+	if v < MinVolume || v > MaxVolume {
+		return 0, fmt.Errorf("volume %q out of range", s)
+	}
+	return v, nil
+}
@@ -0,0 +1,422 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+// Package proto parses the lines a Denon AVR sends on its control
+// connection, such as "MV45" or "SISAT/CBL", into typed messages.
+package proto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Message is a parsed amp line. Its concrete type is one of the
+// types below.
+type Message interface {
+	message()
+}
+
+// Power is a "PWON" or "PWSTANDBY" line, or, within a Zone message,
+// a zone's "ON" or "OFF".
+type Power struct {
+	On bool
+}
+
+// Volume is a master volume line such as "MV45", or, within a Zone
+// message, a zone's volume.
+type Volume struct {
+	DB float64
+}
+
+// MaxVolume is a volume limit line such as "MVMAX 98".
+type MaxVolume struct {
+	DB float64
+}
+
+// Mute is a "MUON" or "MUOFF" line, or a zone's "MUON" or "MUOFF".
+type Mute struct {
+	On bool
+}
+
+// Input is an input source line such as "SISAT/CBL", or a zone's
+// source.
+type Input struct {
+	Source string
+}
+
+// Surround is a surround mode line such as "MSDOLBY D+PL2X C". Mode
+// has runs of spaces collapsed.
+type Surround struct {
+	Mode string
+}
+
+// Zone is a line about a zone, such as "Z2ON" or "ZMOFF". Msg is a
+// Power, Volume, Mute or Input message. ZM lines, which control the
+// main zone's power, are zone 1.
+type Zone struct {
+	Zone int
+	Msg  Message
+}
+
+// QuickSelect is a line such as "MSQUICK1" or "MSQUICK1 MEMORY".
+// N is zero if no Quick Select memory is active.
+type QuickSelect struct {
+	N      int
+	Memory bool
+}
+
+// ChannelLevel is a channel level line such as "CVFL 505".
+type ChannelLevel struct {
+	Channel string
+	DB      float64
+}
+
+// ChannelEnd is the "CVEND" line ending a channel level report.
+type ChannelEnd struct{}
+
+// Frequency is a tuner frequency line such as "TFAN010500".
+type Frequency struct {
+	KHz int
+}
+
+// Sleep is a sleep timer line such as "SLP010" or "SLPOFF". Minutes
+// is zero if the timer is off.
+type Sleep struct {
+	Minutes int
+}
+
+// Display is a line of the network audio on-screen display, "NSE0"
+// through "NSE8", or its "NSA" equivalent on newer models. Text is
+// the line's content as sent.
+type Display struct {
+	Row  int
+	Alt  bool // an NSA line
+	Text string
+}
+
+// Signal is an input signal information line such as
+// "SSINFAISFSV 48K". Param is the parameter after "SSINF", such as
+// "AISFSV", and Value the rest of the line.
+type Signal struct {
+	Param string
+	Value string
+}
+
+// Headphones is a headphone jack report, "SSHPD ON" when
+// headphones are plugged in or "SSHPD OFF".
+type Headphones struct {
+	Connected bool
+}
+
+// AllZoneStereo is an All Zone Stereo report, "MNZST ON" or
+// "MNZST OFF", or "PSZST ON" or "PSZST OFF" on older models.
+type AllZoneStereo struct {
+	On bool
+}
+
+// Update is a firmware update report, "UGSTS " followed by NONE,
+// AVAILABLE, START, a three-digit percentage such as "045" while the
+// update is installing, END or ERROR. Percent is the percentage, or
+// -1 for the other reports; State is "PROGRESS" for percentages and
+// the report otherwise.
+type Update struct {
+	State   string
+	Percent int
+}
+
+// Unknown is a line that isn't parsed into another Message type.
+type Unknown struct {
+	Line string
+}
+
+func (Power) message()         {}
+func (Volume) message()        {}
+func (MaxVolume) message()     {}
+func (Mute) message()          {}
+func (Input) message()         {}
+func (Surround) message()      {}
+func (Zone) message()          {}
+func (QuickSelect) message()   {}
+func (ChannelLevel) message()  {}
+func (ChannelEnd) message()    {}
+func (Frequency) message()     {}
+func (Sleep) message()         {}
+func (Display) message()       {}
+func (Signal) message()        {}
+func (AllZoneStereo) message() {}
+func (Headphones) message()    {}
+func (Update) message()        {}
+func (Unknown) message()       {}
+
+// MaxLineLen is the length of the longest line Parse parses. Amp
+// lines are much shorter; longer ones are garbage.
+const MaxLineLen = 1024
+
+// Parse parses l, an amp line without its trailing carriage return.
+// Lines that aren't understood, including malformed lines of known
+// kinds and lines longer than MaxLineLen, are returned as Unknown.
+// Parse accepts arbitrary bytes: it never panics, and allocates no
+// more than in proportion to len(l).
+func Parse(l string) Message {
+	if len(l) > MaxLineLen {
+		return Unknown{Line: l}
+	}
+	if m := parse(l); m != nil {
+		return m
+	}
+	return Unknown{Line: l}
+}
+
+// parse returns nil for lines it doesn't understand.
+func parse(l string) Message {
+	switch {
+	case l == "PWON":
+		return Power{On: true}
+	case l == "PWSTANDBY":
+		return Power{On: false}
+	case strings.HasPrefix(l, "MVMAX"):
+		db, err := ParseVolume(strings.TrimSpace(l[len("MVMAX"):]))
+		if err != nil {
+			return nil
+		}
+		return MaxVolume{DB: db}
+	case strings.HasPrefix(l, "MV"):
+		db, err := ParseVolume(l[len("MV"):])
+		if err != nil {
+			return nil
+		}
+		return Volume{DB: db}
+	case l == "MUON":
+		return Mute{On: true}
+	case l == "MUOFF":
+		return Mute{On: false}
+	case strings.HasPrefix(l, "SI"):
+		if len(l) == len("SI") {
+			return nil
+		}
+		return Input{Source: l[len("SI"):]}
+	case strings.HasPrefix(l, "MSQUICK"):
+		return parseQuickSelect(l[len("MSQUICK"):])
+	case strings.HasPrefix(l, "MSSMART"):
+		return nil
+	case strings.HasPrefix(l, "MS"):
+		mode := ParseSurroundMode(l[len("MS"):])
+		if mode == "" {
+			return nil
+		}
+		return Surround{Mode: mode}
+	case l == "ZMON":
+		return Zone{Zone: 1, Msg: Power{On: true}}
+	case l == "ZMOFF":
+		return Zone{Zone: 1, Msg: Power{On: false}}
+	case strings.HasPrefix(l, "Z2"), strings.HasPrefix(l, "Z3"):
+		m := parseZone(l[2:])
+		if m == nil {
+			return nil
+		}
+		return Zone{Zone: int(l[1] - '0'), Msg: m}
+	case l == "CVEND":
+		return ChannelEnd{}
+	case strings.HasPrefix(l, "CV"):
+		return parseChannelLevel(l[len("CV"):])
+	case strings.HasPrefix(l, "TFAN"):
+		khz, err := ParseFrequency(l[len("TFAN"):])
+		if err != nil {
+			return nil
+		}
+		return Frequency{KHz: khz}
+	case strings.HasPrefix(l, "SLP"):
+		min, err := ParseSleep(l[len("SLP"):])
+		if err != nil {
+			return nil
+		}
+		return Sleep{Minutes: min}
+	case l == "SSHPD ON":
+		return Headphones{Connected: true}
+	case l == "SSHPD OFF":
+		return Headphones{Connected: false}
+	case l == "MNZST ON", l == "PSZST ON":
+		return AllZoneStereo{On: true}
+	case l == "MNZST OFF", l == "PSZST OFF":
+		return AllZoneStereo{On: false}
+	case strings.HasPrefix(l, "UGSTS "):
+		return parseUpdate(l[len("UGSTS "):])
+	case strings.HasPrefix(l, "SSINF"):
+		param, value, ok := strings.Cut(l[len("SSINF"):], " ")
+		if !ok || param == "" || value == "?" {
+			return nil
+		}
+		return Signal{Param: param, Value: strings.TrimSpace(value)}
+	case strings.HasPrefix(l, "NSE"), strings.HasPrefix(l, "NSA"):
+		if len(l) < 4 || !isDigit(l[3]) || l[3] > '8' {
+			return nil
+		}
+		return Display{Row: int(l[3] - '0'), Alt: l[2] == 'A', Text: l[4:]}
+	}
+	return nil
+}
+
+// parseUpdate parses v, an update report without its "UGSTS "
+// prefix.
+func parseUpdate(v string) Message {
+	switch v {
+	case "NONE", "AVAILABLE", "START", "END", "ERROR":
+		return Update{State: v, Percent: -1}
+	}
+	if len(v) != 3 || !isDigit(v[0]) || !isDigit(v[1]) || !isDigit(v[2]) {
+		return nil
+	}
+	n, _ := strconv.Atoi(v)
+	if n > 100 {
+		return nil
+	}
+	return Update{State: "PROGRESS", Percent: n}
+}
+
+// zoneNonSources are parameter prefixes of zone lines that are
+// neither power, volume, nor mute, nor an input source.
+var zoneNonSources = []string{"CS", "CV", "PS", "SLP", "QUICK", "STBY", "HPF", "SMART", "SOURCE"}
+
+// parseZone parses v, a Z2 or Z3 line without its zone prefix.
+func parseZone(v string) Message {
+	switch {
+	case v == "ON":
+		return Power{On: true}
+	case v == "OFF":
+		return Power{On: false}
+	case v == "MUON":
+		return Mute{On: true}
+	case v == "MUOFF":
+		return Mute{On: false}
+	case v == "" || v == "?" || strings.HasPrefix(v, "MU"):
+		return nil
+	case isDigit(v[0]):
+		db, err := ParseVolume(v)
+		if err != nil {
+			return nil
+		}
+		return Volume{DB: db}
+	}
+	for _, p := range zoneNonSources {
+		if strings.HasPrefix(v, p) {
+			return nil
+		}
+	}
+	return Input{Source: v}
+}
+
+// parseQuickSelect parses the part of a quick select line after
+// "MSQUICK", such as "1" or "1 MEMORY".
+func parseQuickSelect(v string) Message {
+	if v == "" || !isDigit(v[0]) {
+		return nil
+	}
+	switch rest := v[1:]; rest {
+	case "":
+		return QuickSelect{N: int(v[0] - '0')}
+	case " MEMORY":
+		return QuickSelect{N: int(v[0] - '0'), Memory: true}
+	}
+	return nil
+}
+
+// channelZero is the channel level encoding of 0dB.
+const channelZero = 50
+
+// parseChannelLevel parses the part of a channel level line after
+// "CV", such as "FL 505".
+func parseChannelLevel(v string) Message {
+	f := strings.Fields(v)
+	if len(f) != 2 {
+		return nil
+	}
+	db, err := ParseLevel(f[1], channelZero)
+	if err != nil {
+		return nil
+	}
+	return ChannelLevel{Channel: f[0], DB: db}
+}
+
+// MinVolume is the volume in dB of the amp's "---" setting.
+const MinVolume = -80.0
+
+// volumeZero is the master volume encoding of 0dB.
+const volumeZero = 80
+
+// ParseVolume parses a master or zone volume level such as "45" or
+// "805" into dB. Level 80 is 0dB, and both 00 and 99 mean MinVolume.
+func ParseVolume(s string) (db float64, err error) {
+	if s == "99" {
+		return MinVolume, nil
+	}
+	return ParseLevel(s, volumeZero)
+}
+
+// ParseLevel parses a level such as "45" or "805" into dB relative
+// to the level zero. A third digit of 5 adds half a step, so with a
+// zero of 80, "795" is -0.5dB and "805" is +0.5dB.
+func ParseLevel(s string, zero int) (db float64, err error) {
+	if len(s) != 2 && !(len(s) == 3 && s[2] == '5') {
+		return 0, fmt.Errorf("invalid level %q", s)
+	}
+	if !isDigit(s[0]) || !isDigit(s[1]) {
+		return 0, fmt.Errorf("invalid level %q", s)
+	}
+	n := int(s[0]-'0')*10 + int(s[1]-'0')
+	db = float64(n - zero)
+	if len(s) == 3 {
+		db += 0.5
+	}
+	return db, nil
+}
+
+// ParseSurroundMode returns the surround mode name s with leading
+// and trailing spaces removed and internal runs of spaces collapsed.
+func ParseSurroundMode(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// fmThreshold is the boundary between the encodings of FM
+// frequencies, in units of 10kHz, and AM frequencies, in units of
+// 0.01kHz.
+const fmThreshold = 50000
+
+// ParseFrequency parses a six digit tuner frequency such as "010500"
+// (105.00MHz) or "100000" (1000kHz) into kHz.
+func ParseFrequency(s string) (khz int, err error) {
+	if len(s) != 6 || !allDigits(s) {
+		return 0, fmt.Errorf("invalid frequency %q", s)
+	}
+	n, _ := strconv.Atoi(s)
+	if n < fmThreshold {
+		return n * 10, nil
+	}
+	return n / 100, nil
+}
+
+// ParseSleep parses a sleep timer value such as "010" or "OFF" into
+// minutes, with zero for off.
+func ParseSleep(s string) (minutes int, err error) {
+	if s == "OFF" {
+		return 0, nil
+	}
+	if len(s) != 3 || !allDigits(s) {
+		return 0, fmt.Errorf("invalid sleep timer %q", s)
+	}
+	n, _ := strconv.Atoi(s)
+	return n, nil
+}
+
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,272 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package proto
+
+import (
+	"strings"
+	"testing"
+)
+
+var parseTests = []struct {
+	line string
+	want Message
+}{
+	// Power and main zone volume.
+	{"PWON", Power{On: true}},
+	{"PWSTANDBY", Power{On: false}},
+	{"PWOFF", Unknown{Line: "PWOFF"}},
+	{"MV45", Volume{DB: -35}},
+	{"MV455", Volume{DB: -34.5}},
+	{"MV80", Volume{DB: 0}},
+	{"MV805", Volume{DB: 0.5}},
+	{"MV795", Volume{DB: -0.5}},
+	{"MV98", Volume{DB: 18}},
+	{"MV00", Volume{DB: MinVolume}},
+	{"MV99", Volume{DB: MinVolume}},
+	{"MV", Unknown{Line: "MV"}},
+	{"MV4", Unknown{Line: "MV4"}},
+	{"MV456", Unknown{Line: "MV456"}},
+	{"MV4555", Unknown{Line: "MV4555"}},
+	{"MVAB", Unknown{Line: "MVAB"}},
+	{"MVUP", Unknown{Line: "MVUP"}},
+	{"MV?", Unknown{Line: "MV?"}},
+	{"MVMAX 98", MaxVolume{DB: 18}},
+	{"MVMAX 985", MaxVolume{DB: 18.5}},
+	{"MVMAX98", MaxVolume{DB: 18}},
+	{"MVMAX", Unknown{Line: "MVMAX"}},
+	{"MVMAX X", Unknown{Line: "MVMAX X"}},
+
+	// Mute.
+	{"MUON", Mute{On: true}},
+	{"MUOFF", Mute{On: false}},
+	{"MU?", Unknown{Line: "MU?"}},
+
+	// Input.
+	{"SISAT/CBL", Input{Source: "SAT/CBL"}},
+	{"SICD", Input{Source: "CD"}},
+	{"SINET", Input{Source: "NET"}},
+	{"SI", Unknown{Line: "SI"}},
+
+	// Surround mode and Quick Select.
+	{"MSDOLBY D+", Surround{Mode: "DOLBY D+"}},
+	{"MSSTEREO", Surround{Mode: "STEREO"}},
+	{"MSDOLBY D+  +PL2X C", Surround{Mode: "DOLBY D+ +PL2X C"}},
+	{"MS DTS SURROUND ", Surround{Mode: "DTS SURROUND"}},
+	{"MS", Unknown{Line: "MS"}},
+	{"MS   ", Unknown{Line: "MS   "}},
+	{"MSQUICK1", QuickSelect{N: 1}},
+	{"MSQUICK0", QuickSelect{N: 0}},
+	{"MSQUICK5 MEMORY", QuickSelect{N: 5, Memory: true}},
+	{"MSQUICK", Unknown{Line: "MSQUICK"}},
+	{"MSQUICKX", Unknown{Line: "MSQUICKX"}},
+	{"MSQUICK1 X", Unknown{Line: "MSQUICK1 X"}},
+	{"MSSMART1", Unknown{Line: "MSSMART1"}},
+
+	// Network audio display.
+	{"NSE0Now Playing", Display{Row: 0, Text: "Now Playing"}},
+	{"NSE1\x01Song", Display{Row: 1, Text: "\x01Song"}},
+	{"NSE2Artist", Display{Row: 2, Text: "Artist"}},
+	{"NSE3Album", Display{Row: 3, Text: "Album"}},
+	{"NSE4", Display{Row: 4}},
+	{"NSE5", Display{Row: 5}},
+	{"NSE6", Display{Row: 6}},
+	{"NSE7", Display{Row: 7}},
+	{"NSE8 3/10", Display{Row: 8, Text: " 3/10"}},
+	{"NSA1Song", Display{Row: 1, Alt: true, Text: "Song"}},
+	{"NSE9", Unknown{Line: "NSE9"}},
+	{"NSE", Unknown{Line: "NSE"}},
+	{"NSEX", Unknown{Line: "NSEX"}},
+
+	// Zones.
+	{"ZMON", Zone{Zone: 1, Msg: Power{On: true}}},
+	{"ZMOFF", Zone{Zone: 1, Msg: Power{On: false}}},
+	{"Z2ON", Zone{Zone: 2, Msg: Power{On: true}}},
+	{"Z2OFF", Zone{Zone: 2, Msg: Power{On: false}}},
+	{"Z3ON", Zone{Zone: 3, Msg: Power{On: true}}},
+	{"Z250", Zone{Zone: 2, Msg: Volume{DB: -30}}},
+	{"Z2505", Zone{Zone: 2, Msg: Volume{DB: -29.5}}},
+	{"Z299", Zone{Zone: 2, Msg: Volume{DB: MinVolume}}},
+	{"Z340", Zone{Zone: 3, Msg: Volume{DB: -40}}},
+	{"Z2MUON", Zone{Zone: 2, Msg: Mute{On: true}}},
+	{"Z3MUOFF", Zone{Zone: 3, Msg: Mute{On: false}}},
+	{"Z2CD", Zone{Zone: 2, Msg: Input{Source: "CD"}}},
+	{"Z3TUNER", Zone{Zone: 3, Msg: Input{Source: "TUNER"}}},
+	{"Z2SAT/CBL", Zone{Zone: 2, Msg: Input{Source: "SAT/CBL"}}},
+	{"Z2", Unknown{Line: "Z2"}},
+	{"Z2?", Unknown{Line: "Z2?"}},
+	{"Z2MU?", Unknown{Line: "Z2MU?"}},
+	{"Z2999", Unknown{Line: "Z2999"}},
+	{"Z2CSST", Unknown{Line: "Z2CSST"}},
+	{"Z2CVFL 50", Unknown{Line: "Z2CVFL 50"}},
+	{"Z2PSBAS 50", Unknown{Line: "Z2PSBAS 50"}},
+	{"Z2SLP010", Unknown{Line: "Z2SLP010"}},
+	{"Z2QUICK1", Unknown{Line: "Z2QUICK1"}},
+	{"Z2STBY2H", Unknown{Line: "Z2STBY2H"}},
+	{"Z2HPFON", Unknown{Line: "Z2HPFON"}},
+	{"Z2SMART1", Unknown{Line: "Z2SMART1"}},
+	{"Z2SOURCE", Unknown{Line: "Z2SOURCE"}},
+	{"Z4ON", Unknown{Line: "Z4ON"}},
+	{"ZM?", Unknown{Line: "ZM?"}},
+
+	// Channel levels.
+	{"CVFL 505", ChannelLevel{Channel: "FL", DB: 0.5}},
+	{"CVSW 38", ChannelLevel{Channel: "SW", DB: -12}},
+	{"CVEND", ChannelEnd{}},
+	{"CVFL", Unknown{Line: "CVFL"}},
+	{"CVFL 50 50", Unknown{Line: "CVFL 50 50"}},
+	{"CVFL XX", Unknown{Line: "CVFL XX"}},
+
+	// Tuner and sleep timer.
+	{"TFAN010500", Frequency{KHz: 105000}},
+	{"TFAN100000", Frequency{KHz: 1000}},
+	{"TFAN1050", Unknown{Line: "TFAN1050"}},
+	{"TFAN01050X", Unknown{Line: "TFAN01050X"}},
+	{"SLP010", Sleep{Minutes: 10}},
+	{"SLP120", Sleep{Minutes: 120}},
+	{"SLPOFF", Sleep{Minutes: 0}},
+	{"SLP10", Unknown{Line: "SLP10"}},
+
+	// Signal, headphones and firmware updates.
+	{"SSINFAISFSV 48K", Signal{Param: "AISFSV", Value: "48K"}},
+	{"SSINFAISSIG 02", Signal{Param: "AISSIG", Value: "02"}},
+	{"SSINFAISFSV ?", Unknown{Line: "SSINFAISFSV ?"}},
+	{"SSINFAISFSV", Unknown{Line: "SSINFAISFSV"}},
+	{"SSINF 48K", Unknown{Line: "SSINF 48K"}},
+	{"SSHPD ON", Headphones{Connected: true}},
+	{"SSHPD OFF", Headphones{Connected: false}},
+	{"SSHPD ?", Unknown{Line: "SSHPD ?"}},
+	{"MNZST ON", AllZoneStereo{On: true}},
+	{"PSZST OFF", AllZoneStereo{On: false}},
+	{"MNZST ?", Unknown{Line: "MNZST ?"}},
+	{"UGSTS NONE", Update{State: "NONE", Percent: -1}},
+	{"UGSTS AVAILABLE", Update{State: "AVAILABLE", Percent: -1}},
+	{"UGSTS START", Update{State: "START", Percent: -1}},
+	{"UGSTS END", Update{State: "END", Percent: -1}},
+	{"UGSTS ERROR", Update{State: "ERROR", Percent: -1}},
+	{"UGSTS 000", Update{State: "PROGRESS", Percent: 0}},
+	{"UGSTS 045", Update{State: "PROGRESS", Percent: 45}},
+	{"UGSTS 100", Update{State: "PROGRESS", Percent: 100}},
+	{"UGSTS 101", Unknown{Line: "UGSTS 101"}},
+	{"UGSTS 45", Unknown{Line: "UGSTS 45"}},
+	{"UGSTS", Unknown{Line: "UGSTS"}},
+
+	// Malformed and empty lines.
+	{"", Unknown{Line: ""}},
+	{" ", Unknown{Line: " "}},
+	{"?", Unknown{Line: "?"}},
+	{"\x00\xff\xfe", Unknown{Line: "\x00\xff\xfe"}},
+	{"mv45", Unknown{Line: "mv45"}},
+	{"PW", Unknown{Line: "PW"}},
+	{"XYZZY", Unknown{Line: "XYZZY"}},
+}
+
+func TestParse(t *testing.T) {
+	for _, tt := range parseTests {
+		if got := Parse(tt.line); got != tt.want {
+			t.Errorf("Parse(%q) = %#v; want %#v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseLong(t *testing.T) {
+	l := "SI" + strings.Repeat("X", MaxLineLen)
+	if got, want := Parse(l), (Unknown{Line: l}); got != want {
+		t.Errorf("Parse of %d byte line = %T; want Unknown", len(l), got)
+	}
+	l = "SI" + strings.Repeat("X", MaxLineLen-2)
+	if got, want := Parse(l), (Input{Source: l[2:]}); got != want {
+		t.Errorf("Parse of %d byte line = %T; want Input", len(l), got)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		s    string
+		zero int
+		want float64
+		ok   bool
+	}{
+		{"50", 50, 0, true},
+		{"505", 50, 0.5, true},
+		{"38", 50, -12, true},
+		{"62", 50, 12, true},
+		{"00", 80, -80, true},
+		{"", 50, 0, false},
+		{"5", 50, 0, false},
+		{"506", 50, 0, false},
+		{"5X", 50, 0, false},
+		{"-5", 50, 0, false},
+		{"5055", 50, 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.s, tt.zero)
+		if ok := err == nil; ok != tt.ok || got != tt.want {
+			t.Errorf("ParseLevel(%q, %d) = %v, %v; want %v, ok %v", tt.s, tt.zero, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+// firmwareGarbage is the kind of thing amps send during firmware
+// updates: binary runs without line breaks, cut to line lengths by
+// the reader.
+var firmwareGarbage = []string{
+	"\x00\x00\x00\x00",
+	"\xff\xfe\xfd\x80\x81",
+	"MV\x00\x00",
+	"NSE\xff\x00",
+	"Z2\xff",
+	"SSINF\x00 \x00",
+	"UGSTS \x00\x00\x00",
+	"MSQUICK\xff MEMORY",
+	"CV\x00 \x00",
+	strings.Repeat("\xaa\x55", MaxLineLen/2),
+	strings.Repeat("Z", MaxLineLen+1),
+}
+
+func FuzzParseLine(f *testing.F) {
+	for _, tt := range parseTests {
+		f.Add(tt.line)
+	}
+	for _, l := range firmwareGarbage {
+		f.Add(l)
+	}
+	f.Fuzz(func(t *testing.T, l string) {
+		m := Parse(l)
+		if m == nil {
+			t.Fatalf("Parse(%q) = nil", l)
+		}
+		if len(l) > MaxLineLen {
+			if m != (Unknown{Line: l}) {
+				t.Fatalf("Parse of %d byte line = %#v; want Unknown", len(l), m)
+			}
+			return
+		}
+		if n := textLen(m); n > len(l) || n > MaxLineLen {
+			t.Fatalf("Parse(%q) = %#v, with %d bytes of text", l, m, n)
+		}
+	})
+}
+
+// textLen returns the total length of m's strings.
+func textLen(m Message) int {
+	switch m := m.(type) {
+	case Input:
+		return len(m.Source)
+	case Surround:
+		return len(m.Mode)
+	case Zone:
+		return textLen(m.Msg)
+	case ChannelLevel:
+		return len(m.Channel)
+	case Display:
+		return len(m.Text)
+	case Signal:
+		return len(m.Param) + len(m.Value)
+	case Update:
+		return len(m.State)
+	case Unknown:
+		return len(m.Line)
+	}
+	return 0
+}
@@ -0,0 +1,49 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"fmt"
+)
+
+// Parsable is the constraint of Query's result types: pointers to T
+// with a Parse method, which parses a reply line into the T.
+type Parsable[T any] interface {
+	*T
+	Parse(reply []byte) error
+}
+
+// Query is like Amp.Query but parses the reply into a T, for replies
+// the package has no method for. For example:
+//
+//	type CinemaEQ bool
+//
+//	func (c *CinemaEQ) Parse(b []byte) error {
+//		switch string(b) {
+//		case "PSCINEMA EQ.ON":
+//			*c = true
+//		case "PSCINEMA EQ.OFF":
+//			*c = false
+//		default:
+//			return errors.New("want ON or OFF")
+//		}
+//		return nil
+//	}
+//
+//	on, err := avr.Query[CinemaEQ](ctx, amp, "PSCINEMA EQ. ?")
+//
+// Parse is given the whole reply, as Amp.Query returns it. Its errors
+// are returned wrapped with the reply.
+func Query[T any, PT Parsable[T]](ctx context.Context, a *Amp, q string) (T, error) {
+	var v T
+	l, err := a.Query(ctx, q)
+	if err != nil {
+		return v, err
+	}
+	if err := PT(&v).Parse([]byte(l)); err != nil {
+		return v, fmt.Errorf("parsing reply %q: %w", l, err)
+	}
+	return v, nil
+}
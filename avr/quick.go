@@ -0,0 +1,63 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"fmt"
+	"strconv"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// MaxQuickSelect is the highest Quick Select number.
+const MaxQuickSelect = 5
+
+// QuickSelect recalls Quick Select memory n, from 1 to
+// MaxQuickSelect, as if its front panel button were pressed.
+func (a *Amp) QuickSelect(n int) error {
+	if err := checkQuickSelect(n); err != nil {
+		return err
+	}
+	cmd := "MSQUICK" + strconv.Itoa(n)
+	return a.setConfirm(cmd, is[proto.QuickSelect], cmd)
+}
+
+// SaveQuickSelect stores the current input, volume and surround
+// settings into Quick Select memory n.
+func (a *Amp) SaveQuickSelect(n int) error {
+	if err := checkQuickSelect(n); err != nil {
+		return err
+	}
+	cmd := "MSQUICK" + strconv.Itoa(n) + " MEMORY"
+	_, err := a.timeoutQuery(cmd, is[proto.QuickSelect])
+	return err
+}
+
+// CurrentQuickSelect returns the most recently recalled Quick Select
+// memory, or zero if none is active.
+func (a *Amp) CurrentQuickSelect() (int, error) {
+	l, err := a.timeoutQuery("MSQUICK ?", is[proto.QuickSelect])
+	if err != nil {
+		return 0, err
+	}
+	return proto.Parse(l).(proto.QuickSelect).N, nil
+}
+
+// OnQuickSelect registers f to be called with the Quick Select
+// number whenever the amp reports one being recalled, including from
+// the front panel or remote. The returned func unregisters f.
+func (a *Amp) OnQuickSelect(f func(n int)) (remove func()) {
+	return a.listen(func(ev Event) {
+		if ev, ok := ev.(QuickSelectRecalled); ok {
+			f(ev.N)
+		}
+	})
+}
+
+func checkQuickSelect(n int) error {
+	if n < 1 || n > MaxQuickSelect {
+		return fmt.Errorf("quick select %d out of range [1, %d]", n, MaxQuickSelect)
+	}
+	return nil
+}
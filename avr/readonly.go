@@ -0,0 +1,35 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithReadOnly makes the Amp a monitor: it connects, queries and
+// publishes events as usual, but refuses every command that could
+// change the amp's state, returning ErrReadOnly. That covers the
+// setters, Wake, and SendCommand with anything but a query. Queries
+// are commands ending in "?", such as "MV?" or "PSDYNEQ ?", and the
+// network display request "NSE". The HEOS client is not restricted.
+func WithReadOnly() Option {
+	return func(a *Amp) { a.readOnly = true }
+}
+
+// checkReadOnly returns ErrReadOnly if the Amp is read-only and raw
+// command cmd isn't a query.
+func (a *Amp) checkReadOnly(cmd string) error {
+	if !a.readOnly || isQuery(cmd) {
+		return nil
+	}
+	return fmt.Errorf("%q: %w", strings.TrimSuffix(cmd, "\r"), ErrReadOnly)
+}
+
+// isQuery reports whether raw command cmd only asks for the amp's
+// state.
+func isQuery(cmd string) bool {
+	cmd = strings.TrimSuffix(cmd, "\r")
+	return strings.HasSuffix(cmd, "?") || cmd == "NSE" || cmd == "NSA"
+}
@@ -0,0 +1,59 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import "context"
+
+// A Receiver is an AV receiver of any brand, controlled through its
+// main zone. *Amp is the Denon and Marantz implementation; other
+// brands' drivers live in their own packages, such as eiscp for
+// Onkyo and Integra. Drivers speak in this package's units and
+// names: volume in dB, where 0dB is reference level, and Denon input
+// source names, which they translate to their own.
+type Receiver interface {
+	PowerOn() error
+	PowerOff() error
+	PowerState() (on bool, err error)
+
+	SetVolume(db float64) error
+	GetVolume() (db float64, err error)
+	VolumeUp() error
+	VolumeDown() error
+
+	Mute(on bool) error
+	IsMuted() (bool, error)
+
+	SelectInput(src InputSource) error
+	CurrentInput() (InputSource, error)
+
+	// Subscribe returns a channel of the receiver's events, which
+	// are of this package's Event types, and a func to unsubscribe.
+	Subscribe() (events <-chan Event, cancel func())
+
+	// SendRaw sends cmd, a command in the receiver's own protocol,
+	// without waiting for a reply. The driver adds the protocol's
+	// framing, such as the line ending, and paces cmd like its
+	// other commands.
+	SendRaw(ctx context.Context, cmd string) error
+
+	// QueryRaw sends cmd like SendRaw and returns the receiver's
+	// reply, matched to cmd as each driver documents. Replies to
+	// raw commands also reach subscribers.
+	QueryRaw(ctx context.Context, cmd string) (string, error)
+
+	Close() error
+}
+
+// SendRaw implements Receiver. It is SendCommandContext.
+func (a *Amp) SendRaw(ctx context.Context, cmd string) error {
+	return a.SendCommandContext(ctx, cmd)
+}
+
+// QueryRaw implements Receiver. It is Query, so the reply to "MV?"
+// is a line such as "MV45".
+func (a *Amp) QueryRaw(ctx context.Context, cmd string) (string, error) {
+	return a.Query(ctx, cmd)
+}
+
+var _ Receiver = (*Amp)(nil)
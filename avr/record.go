@@ -0,0 +1,236 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// TCP returns a Transport dialing addr over TCP, for wrapping with
+// Record.
+func TCP(addr string) Transport {
+	return tcpTransport{addr: addr}
+}
+
+type tcpTransport struct {
+	addr string
+}
+
+func (t tcpTransport) Open(ctx context.Context) (io.ReadWriteCloser, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", t.addr)
+}
+
+// A recordEvent is a line of a recording.
+type recordEvent struct {
+	Time time.Time `json:"time"`
+	Conn int       `json:"conn"` // counts connections from 1
+	Op   string    `json:"op"`   // "open", "send", "recv", "end" or "close"
+	Data string    `json:"data,omitempty"`
+	Err  string    `json:"err,omitempty"`
+}
+
+// Record returns a Transport that connects through t and writes
+// every byte sent to and received from the amp to w, one JSON object
+// per line, with timestamps. Play a recording back with Replay.
+func Record(t Transport, w io.Writer) Transport {
+	return &recorder{t: t, enc: json.NewEncoder(w)}
+}
+
+type recorder struct {
+	t Transport
+
+	mu    sync.Mutex
+	enc   *json.Encoder
+	conns int
+}
+
+func (r *recorder) log(ev recordEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ev.Time = time.Now()
+	r.enc.Encode(ev)
+}
+
+func (r *recorder) Open(ctx context.Context) (io.ReadWriteCloser, error) {
+	c, err := r.t.Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.conns++
+	n := r.conns
+	r.mu.Unlock()
+	r.log(recordEvent{Conn: n, Op: "open"})
+	return &recordConn{rwc: c, r: r, n: n}, nil
+}
+
+type recordConn struct {
+	rwc  io.ReadWriteCloser
+	r    *recorder
+	n    int
+	once sync.Once
+}
+
+func (c *recordConn) Read(p []byte) (int, error) {
+	n, err := c.rwc.Read(p)
+	if n > 0 {
+		c.r.log(recordEvent{Conn: c.n, Op: "recv", Data: string(p[:n])})
+	}
+	if err != nil {
+		c.ended(recordEvent{Conn: c.n, Op: "end", Err: err.Error()})
+	}
+	return n, err
+}
+
+func (c *recordConn) Write(p []byte) (int, error) {
+	n, err := c.rwc.Write(p)
+	if n > 0 {
+		c.r.log(recordEvent{Conn: c.n, Op: "send", Data: string(p[:n])})
+	}
+	return n, err
+}
+
+func (c *recordConn) Close() error {
+	c.ended(recordEvent{Conn: c.n, Op: "close"})
+	return c.rwc.Close()
+}
+
+// ended records the end of the connection: "end" if reading from
+// the amp failed, or "close" if the client closed it first.
+func (c *recordConn) ended(ev recordEvent) {
+	c.once.Do(func() { c.r.log(ev) })
+}
+
+// Replay returns a Transport that plays back a recording made with
+// Record. Each connection opened replays the next recorded
+// connection: reads return what the amp sent, in order, each waiting
+// until the bytes the client had sent before it in the recording have
+// been written, so replay doesn't depend on timing. What is written
+// is not checked. Where reading from the amp failed in the recording,
+// reads return io.EOF; otherwise they block until Close. Opening more connections
+// than were recorded fails.
+func Replay(r io.Reader) (Transport, error) {
+	sessions := make(map[int][]recordEvent)
+	var order []int
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		var ev recordEvent
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			return nil, fmt.Errorf("recording line %d: %w", line, err)
+		}
+		if _, ok := sessions[ev.Conn]; !ok {
+			order = append(order, ev.Conn)
+		}
+		sessions[ev.Conn] = append(sessions[ev.Conn], ev)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	rp := new(replayer)
+	for _, n := range order {
+		rp.sessions = append(rp.sessions, sessions[n])
+	}
+	return rp, nil
+}
+
+type replayer struct {
+	mu       sync.Mutex
+	sessions [][]recordEvent
+}
+
+func (rp *replayer) Open(ctx context.Context) (io.ReadWriteCloser, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if len(rp.sessions) == 0 {
+		return nil, errors.New("replay: no more recorded connections")
+	}
+	c := &replayConn{events: rp.sessions[0]}
+	c.cond = sync.NewCond(&c.mu)
+	rp.sessions = rp.sessions[1:]
+	return c, nil
+}
+
+type replayConn struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	events []recordEvent // remaining
+	off    int           // bytes of events[0].Data already used
+	closed bool
+}
+
+// skip drops events that neither read nor write.
+//
+// must be called with mu held
+func (c *replayConn) skip() {
+	for len(c.events) > 0 && c.events[0].Op == "open" {
+		c.events = c.events[1:]
+	}
+}
+
+func (c *replayConn) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		c.skip()
+		if c.closed {
+			return 0, net.ErrClosed
+		}
+		if len(c.events) > 0 {
+			switch ev := c.events[0]; ev.Op {
+			case "recv":
+				n := copy(p, ev.Data[c.off:])
+				c.off += n
+				if c.off == len(ev.Data) {
+					c.events, c.off = c.events[1:], 0
+				}
+				c.cond.Broadcast()
+				return n, nil
+			case "end":
+				return 0, io.EOF
+			}
+		}
+		c.cond.Wait() // for a write, or Close
+	}
+}
+
+func (c *replayConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	left := len(p)
+	for left > 0 {
+		c.skip()
+		if len(c.events) == 0 || c.events[0].Op != "send" {
+			break
+		}
+		n := min(left, len(c.events[0].Data)-c.off)
+		left -= n
+		c.off += n
+		if c.off == len(c.events[0].Data) {
+			c.events, c.off = c.events[1:], 0
+		}
+	}
+	c.cond.Broadcast()
+	return len(p), nil
+}
+
+func (c *replayConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	c.cond.Broadcast()
+	return nil
+}
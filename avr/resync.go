@@ -0,0 +1,42 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"time"
+)
+
+// resyncTimeout bounds the status queries after a reconnect.
+const resyncTimeout = 30 * time.Second
+
+// resync queries the amp's state after a reconnect, replaces the
+// cached state with it and publishes Resynced. If the queries fail,
+// as when the connection drops again, the next connection retries.
+func (a *Amp) resync() {
+	defer a.wg.Done()
+	ctx, cancel := a.backgroundContext()
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, resyncTimeout)
+	defer cancelTimeout()
+
+	st, err := a.Status(ctx)
+	if err != nil {
+		a.log.Debug("avr: resync failed", "addr", a.addr, "err", err)
+		return
+	}
+	st.Web = nil
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return
+	}
+	a.cache = *st.clone()
+	a.cacheChanged()
+	a.publish(Resynced{Status: *st})
+	if a.updating {
+		a.updating = false
+		a.publish(UpdateStatus{State: UpdateRestarted})
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// A Rule runs a Scene whenever the main zone switches to Input,
+// however it was switched, such as:
+//
+//	avr.Rule{Input: avr.SourceGame, Scene: &avr.Scene{
+//		Name: "game",
+//		Steps: []avr.Step{
+//			avr.SetSurroundModeStep(avr.SurroundDirect),
+//			avr.LimitVolumeStep(-25),
+//			avr.VideoProcessingStep(avr.VideoProcessingGame),
+//		},
+//	}}
+type Rule struct {
+	Input InputSource
+	Scene *Scene
+}
+
+// RunRules runs the scenes of the rules matching each input the amp
+// reports switching to, in the order of rules, until ctx is done, and
+// then returns ctx.Err(). Inputs changed while the connection was
+// down, or while events were dropped, count once the Amp resyncs or
+// notices. During quick input changes only the latest input's rules
+// run. Scenes that fail are logged as
+// errors.
+//
+// RunRules returns an error at once if a rule has no input or scene,
+// or with ErrClosed if the Amp is closed.
+func (a *Amp) RunRules(ctx context.Context, rules []Rule) error {
+	for i, r := range rules {
+		switch {
+		case r.Input == "":
+			return fmt.Errorf("rule %d has no input", i)
+		case r.Scene == nil:
+			return fmt.Errorf("rule %d has no scene", i)
+		}
+	}
+	events, cancel := a.Subscribe()
+	defer cancel()
+
+	input := a.CachedState().Input
+	for {
+		changed := false
+		err := a.nextEvents(ctx, events, func(ev Event) {
+			switch ev := ev.(type) {
+			case InputChanged:
+				input, changed = ev.Input, true
+			case Resynced:
+				if ev.Status.Input != input {
+					input, changed = ev.Status.Input, true
+				}
+			}
+		})
+		switch {
+		case errors.Is(err, ErrClosed):
+			return fmt.Errorf("running rules: %w", err)
+		case err != nil:
+			return err
+		}
+		if !changed {
+			continue
+		}
+		for _, r := range rules {
+			if r.Input != input {
+				continue
+			}
+			if err := a.RunScene(ctx, r.Scene); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if errors.Is(err, ErrClosed) {
+					return fmt.Errorf("running rules: %w", err)
+				}
+				a.log.Error("avr: rule scene failed", "input", input, "scene", r.Scene.Name, "err", err)
+			}
+		}
+	}
+}
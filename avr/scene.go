@@ -0,0 +1,221 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// A Scene is an ordered list of steps, such as turning the amp on,
+// selecting an input and setting the volume for a movie.
+type Scene struct {
+	Name  string
+	Steps []Step
+}
+
+// A Step is one action of a Scene. The Step functions below return
+// the common ones.
+type Step struct {
+	Name string // such as "power on", for errors
+	Do   func(ctx context.Context, a *Amp) error
+
+	// Delay is how long to wait after Do, such as while the amp
+	// warms up after powering on.
+	Delay time.Duration
+
+	// Verify, if non-nil, is called after Delay and fails the step
+	// if the amp isn't in the state Do asked for.
+	Verify func(ctx context.Context, a *Amp) error
+}
+
+// A SceneError reports the step at which a Scene failed.
+type SceneError struct {
+	Scene string
+	Step  int    // index in Steps
+	Name  string // the step's name
+	Err   error
+}
+
+func (e *SceneError) Error() string {
+	return fmt.Sprintf("scene %q: step %d (%s): %v", e.Scene, e.Step, e.Name, e.Err)
+}
+
+func (e *SceneError) Unwrap() error { return e.Err }
+
+// RunScene runs the steps of s in order, stopping at the first that
+// fails, with a *SceneError, or when ctx is done.
+func (a *Amp) RunScene(ctx context.Context, s *Scene) error {
+	for i, st := range s.Steps {
+		fail := func(err error) error {
+			return &SceneError{Scene: s.Name, Step: i, Name: st.Name, Err: err}
+		}
+		if err := ctx.Err(); err != nil {
+			return fail(err)
+		}
+		if st.Do != nil {
+			if err := st.Do(ctx, a); err != nil {
+				return fail(err)
+			}
+		}
+		if st.Delay > 0 {
+			t := time.NewTimer(st.Delay)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return fail(ctx.Err())
+			}
+		}
+		if st.Verify != nil {
+			if err := st.Verify(ctx, a); err != nil {
+				return fail(err)
+			}
+		}
+	}
+	return nil
+}
+
+// PowerOnStep turns the amp on and waits warmUp before the next step.
+func PowerOnStep(warmUp time.Duration) Step {
+	return Step{
+		Name:  "power on",
+		Do:    func(ctx context.Context, a *Amp) error { return a.PowerOn() },
+		Delay: warmUp,
+		Verify: func(ctx context.Context, a *Amp) error {
+			on, err := a.PowerState()
+			if err == nil && !on {
+				err = &StateError{Cmd: "PWON", Want: "PWON", Got: "PWSTANDBY"}
+			}
+			return err
+		},
+	}
+}
+
+// PowerOffStep puts the amp into standby.
+func PowerOffStep() Step {
+	return Step{
+		Name: "power off",
+		Do:   func(ctx context.Context, a *Amp) error { return a.PowerOff() },
+	}
+}
+
+// SelectInputStep switches the main zone to src.
+func SelectInputStep(src InputSource) Step {
+	return Step{
+		Name: "select " + string(src),
+		Do:   func(ctx context.Context, a *Amp) error { return a.SelectInput(src) },
+		Verify: func(ctx context.Context, a *Amp) error {
+			got, err := a.CurrentInput()
+			if err == nil && got != src {
+				err = &StateError{Cmd: "SI" + string(src), Want: "SI" + string(src), Got: "SI" + string(got)}
+			}
+			return err
+		},
+	}
+}
+
+// SetVolumeStep sets the master volume to db.
+func SetVolumeStep(db float64) Step {
+	return Step{
+		Name: fmt.Sprintf("set volume %vdB", db),
+		Do:   func(ctx context.Context, a *Amp) error { return a.setVolumeContext(ctx, db) },
+		Verify: func(ctx context.Context, a *Amp) error {
+			got, err := a.GetVolume()
+			if err == nil && math.Abs(got-db) > 0.25 {
+				err = fmt.Errorf("volume is %vdB; want %vdB", got, db)
+			}
+			return err
+		},
+	}
+}
+
+// MuteStep mutes or unmutes the main zone.
+func MuteStep(on bool) Step {
+	name := "unmute"
+	if on {
+		name = "mute"
+	}
+	return Step{
+		Name: name,
+		Do:   func(ctx context.Context, a *Amp) error { return a.Mute(on) },
+	}
+}
+
+// FadeVolumeStep fades the master volume to db over d.
+func FadeVolumeStep(db float64, d time.Duration) Step {
+	return Step{
+		Name: fmt.Sprintf("fade volume to %vdB", db),
+		Do:   func(ctx context.Context, a *Amp) error { return a.FadeVolume(ctx, db, d) },
+	}
+}
+
+// SetSurroundModeStep sets the surround mode to m. The amp may report
+// a specific mode for m as SetSurroundMode describes, so it is not
+// verified.
+func SetSurroundModeStep(m SurroundMode) Step {
+	return Step{
+		Name: "surround " + string(m),
+		Do:   func(ctx context.Context, a *Amp) error { return a.SetSurroundMode(m) },
+	}
+}
+
+// CommandStep sends raw command cmd without waiting for a reply.
+func CommandStep(cmd string) Step {
+	return Step{
+		Name: cmd,
+		Do:   func(ctx context.Context, a *Amp) error { return a.SendCommandContext(ctx, cmd) },
+	}
+}
+
+// BatchStep sends cmds together with SendBatch, failing if any
+// command fails.
+func BatchStep(cmds ...Command) Step {
+	return Step{
+		Name: fmt.Sprintf("batch of %d commands", len(cmds)),
+		Do: func(ctx context.Context, a *Amp) error {
+			_, err := a.SendBatch(ctx, cmds)
+			return err
+		},
+	}
+}
+
+// WaitStep pauses for d.
+func WaitStep(d time.Duration) Step {
+	return Step{Name: "wait " + d.String(), Delay: d}
+}
+
+// LimitVolumeStep lowers the master volume to db if it is higher,
+// leaving quieter volumes alone.
+func LimitVolumeStep(db float64) Step {
+	return Step{
+		Name: fmt.Sprintf("limit volume to %vdB", db),
+		Do: func(ctx context.Context, a *Amp) error {
+			got, err := a.GetVolume()
+			if err != nil || got <= db {
+				return err
+			}
+			return a.setVolumeContext(ctx, db)
+		},
+	}
+}
+
+// PictureModeStep sets the video processor's picture mode to m.
+func PictureModeStep(m PictureMode) Step {
+	return Step{
+		Name: "picture " + string(m),
+		Do:   func(ctx context.Context, a *Amp) error { return a.SetPictureMode(m) },
+	}
+}
+
+// VideoProcessingStep sets the video processing mode to m, such as
+// VideoProcessingGame.
+func VideoProcessingStep(m VideoProcessing) Step {
+	return Step{
+		Name: "video processing " + string(m),
+		Do:   func(ctx context.Context, a *Amp) error { return a.SetVideoProcessing(m) },
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// A Schedule runs a Scene daily at a time of day, such as turning
+// the tuner on at 7:00 on weekdays, or everything off at 1:00.
+type Schedule struct {
+	Scene  *Scene
+	Hour   int            // 0 to 23
+	Minute int            // 0 to 59
+	Days   []time.Weekday // every day if empty
+
+	// Location is the time zone of Hour and Minute. Nil means
+	// time.Local.
+	Location *time.Location
+}
+
+// Next returns the first time after t at which s is due. Across a
+// daylight saving change, a time of day that doesn't exist that day
+// is moved as time.Date moves it. If Days holds no weekday from
+// Sunday to Saturday, s is never due, and Next returns the zero time.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := s.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	t = t.In(loc)
+	for day := 0; day <= 7; day++ {
+		d := time.Date(t.Year(), t.Month(), t.Day()+day, s.Hour, s.Minute, 0, 0, loc)
+		if d.After(t) && (len(s.Days) == 0 || slices.Contains(s.Days, d.Weekday())) {
+			return d
+		}
+	}
+	return time.Time{} // no day of the next 8 matches
+}
+
+func (s *Schedule) check() error {
+	switch {
+	case s.Scene == nil:
+		return fmt.Errorf("schedule at %02d:%02d has no scene", s.Hour, s.Minute)
+	case s.Hour < 0 || s.Hour > 23 || s.Minute < 0 || s.Minute > 59:
+		return fmt.Errorf("schedule for scene %q: invalid time %02d:%02d", s.Scene.Name, s.Hour, s.Minute)
+	}
+	for _, d := range s.Days {
+		if d < time.Sunday || d > time.Saturday {
+			return fmt.Errorf("schedule for scene %q: invalid weekday %d", s.Scene.Name, d)
+		}
+	}
+	return nil
+}
+
+// Schedule timing.
+const (
+	// scheduleCheck is the longest RunSchedules sleeps before
+	// looking at the clock again. Timers measure elapsed time, so
+	// this is what catches the host's clock being set or the host
+	// waking from sleep.
+	scheduleCheck = time.Minute
+
+	// scheduleGrace is how late a scene may start. Scenes due
+	// earlier than that, as while the host was asleep, are skipped.
+	scheduleGrace = 5 * time.Minute
+)
+
+// RunSchedules runs each schedule's scene whenever it is due until
+// ctx is done, and then returns ctx.Err(). Times are kept by the
+// host's clock, not the amp's. Scenes run one at a time, so a scene
+// due while another runs starts once it is done. Scene failures and
+// scenes skipped for being more than five minutes late are logged
+// as errors. RunSchedules returns an error at once if a schedule has
+// no scene or an invalid time.
+func (a *Amp) RunSchedules(ctx context.Context, scheds []Schedule) error {
+	if len(scheds) == 0 {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	next := make([]time.Time, len(scheds))
+	now := time.Now()
+	for i := range scheds {
+		if err := scheds[i].check(); err != nil {
+			return err
+		}
+		next[i] = scheds[i].Next(now)
+	}
+	for {
+		i := 0
+		for j := range next {
+			if next[j].Before(next[i]) {
+				i = j
+			}
+		}
+		if wait := min(time.Until(next[i]), scheduleCheck); wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-t.C:
+				continue
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			}
+		}
+
+		s := &scheds[i]
+		if late := time.Since(next[i]); late > scheduleGrace {
+			a.log.Error("avr: skipping missed scheduled scene", "scene", s.Scene.Name, "due", next[i], "late", late)
+		} else if err := a.RunScene(ctx, s.Scene); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			a.log.Error("avr: scheduled scene failed", "scene", s.Scene.Name, "err", err)
+		}
+		next[i] = s.Next(time.Now())
+	}
+}
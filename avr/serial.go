@@ -0,0 +1,47 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"io"
+)
+
+// SerialBaud is the baud rate of the amp's RS-232 port. The port uses
+// 8 data bits, no parity and one stop bit.
+const SerialBaud = 9600
+
+// Serial returns a Transport using the RS-232 port attached at
+// device, such as "/dev/ttyUSB0" for a USB serial adapter. The amp
+// answers on its serial port even when its network stack is asleep.
+func Serial(device string) Transport {
+	return serialTransport{device: device}
+}
+
+type serialTransport struct {
+	device string
+}
+
+func (t serialTransport) Open(ctx context.Context) (io.ReadWriteCloser, error) {
+	type result struct {
+		rwc io.ReadWriteCloser
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		rwc, err := openSerial(t.device)
+		ch <- result{rwc, err}
+	}()
+	select {
+	case r := <-ch:
+		return r.rwc, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-ch; r.rwc != nil {
+				r.rwc.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// openSerial opens device and sets it to SerialBaud 8N1 raw mode.
+func openSerial(device string) (io.ReadWriteCloser, error) {
+	// Non-blocking, so that the os.File uses the poller and Close
+	// unblocks a pending Read.
+	fd, err := syscall.Open(device, syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: device, Err: err}
+	}
+	t := syscall.Termios{
+		Cflag: syscall.B9600 | syscall.CS8 | syscall.CREAD | syscall.CLOCAL,
+	}
+	t.Cc[syscall.VMIN] = 1
+	t.Cc[syscall.VTIME] = 0
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCSETS, uintptr(unsafe.Pointer(&t))); errno != 0 {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("configuring %s: %w", device, errno)
+	}
+	return os.NewFile(uintptr(fd), device), nil
+}
@@ -0,0 +1,15 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+//go:build !linux
+
+package avr
+
+import (
+	"errors"
+	"io"
+)
+
+func openSerial(device string) (io.ReadWriteCloser, error) {
+	return nil, errors.New("serial ports are not supported on this platform")
+}
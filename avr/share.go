@@ -0,0 +1,322 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// The amp accepts one control connection at a time, so Amps in the
+// same process dialing the same address over TCP share one session:
+// each reads every line the amp sends, and their commands are written
+// to the same connection. The session is closed when the last of its
+// Amps disconnects, or when any of them finds the amp has gone silent,
+// in which case every one of them reports ErrSilent. Amps share a
+// session only if created with the same dialing options.
+
+// WithExclusiveConnection makes the Amp dial its own connection
+// rather than share one with other Amps for the same address. The
+// amp then drops whichever connection was made first.
+func WithExclusiveConnection() Option {
+	return func(a *Amp) { a.exclusive = true }
+}
+
+// sessions holds the shared sessions by sessionKey.
+var sessions = struct {
+	sync.Mutex
+	m map[string]*session
+}{m: make(map[string]*session)}
+
+// sessionLines is how many lines a shared connection buffers for an
+// Amp that is slow to read them. An Amp that falls further behind is
+// detached from the session, failing with errFellBehind, so that it
+// reconnects and resyncs rather than miss replies.
+const sessionLines = 64
+
+// errFellBehind ends an Amp's handle on a session when it is more than
+// sessionLines behind.
+var errFellBehind = errors.New("fell behind the shared connection")
+
+// A session is a TCP connection to an amp shared by Amps.
+type session struct {
+	key     string
+	addr    string        // host:port of the amp
+	ready   chan struct{} // closed once dialed
+	c       net.Conn      // set before ready is closed, if the dial worked
+	err     error         // dial error, set before ready is closed
+	stopped chan struct{} // closed when the reader has stopped
+
+	wmu sync.Mutex // serializes writes to c
+
+	mu      sync.Mutex
+	conns   map[*sharedConn]bool
+	readErr error // why the connection ended, once it has
+}
+
+// An addrConn is a connection that knows its addresses, such as a
+// net.Conn or a sharedConn.
+type addrConn interface {
+	LocalAddr() net.Addr
+	RemoteAddr() net.Addr
+}
+
+// A sharedConn is an Amp's handle on a session.
+type sharedConn struct {
+	s     *session
+	lines chan []byte // closed when the session ends or drops sc
+	done  chan struct{}
+	once  sync.Once
+	buf   []byte // rest of the line being read
+	err   error  // why s dropped sc, if it did; guarded by s.mu
+}
+
+// sessionKey returns the key of the sessions a can share: those to
+// the same address dialed with the same options, as the session is
+// dialed with the first Amp's.
+func (a *Amp) sessionKey() string {
+	d := &a.dialer
+	key := a.addr
+	if d.LocalAddr != nil {
+		key += " from " + d.LocalAddr.String()
+	}
+	return fmt.Sprintf("%s timeout %v keepalive %v fallback %v resolver %p",
+		key, d.Timeout, d.KeepAlive, d.FallbackDelay, d.Resolver)
+}
+
+// openShared returns a handle on the session for a's address,
+// dialing it unless another Amp already has.
+func (a *Amp) openShared(ctx context.Context) (io.ReadWriteCloser, error) {
+	key := a.sessionKey()
+	for {
+		sessions.Lock()
+		s := sessions.m[key]
+		if s == nil {
+			s = &session{
+				key:     key,
+				addr:    a.addr,
+				ready:   make(chan struct{}),
+				stopped: make(chan struct{}),
+				conns:   make(map[*sharedConn]bool),
+			}
+			sessions.m[key] = s
+			sessions.Unlock()
+			s.dial(ctx, &a.dialer)
+		} else {
+			sessions.Unlock()
+		}
+		select {
+		case <-s.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if s.err != nil {
+			return nil, s.err
+		}
+		if sc := s.join(); sc != nil {
+			return sc, nil
+		}
+		// The session ended before we joined; dial another.
+	}
+}
+
+// dial connects s with d and starts reading from it.
+func (s *session) dial(ctx context.Context, d *net.Dialer) {
+	s.c, s.err = d.DialContext(ctx, "tcp", s.addr)
+	if s.err != nil {
+		close(s.stopped)
+		s.remove()
+	} else {
+		go s.read()
+	}
+	close(s.ready)
+}
+
+// join returns a new handle on s, or nil if s has ended.
+func (s *session) join() *sharedConn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.readErr != nil {
+		return nil
+	}
+	sc := &sharedConn{
+		s:     s,
+		lines: make(chan []byte, sessionLines),
+		done:  make(chan struct{}),
+	}
+	s.conns[sc] = true
+	return sc
+}
+
+// read copies each line the amp sends to every handle until the
+// connection fails, dropping any handle with no room for it.
+func (s *session) read() {
+	defer close(s.stopped)
+	r := bufio.NewReaderSize(s.c, proto.MaxLineLen)
+	for {
+		bs, err := r.ReadSlice('\r')
+		if err == bufio.ErrBufferFull {
+			err = nil // handles drop overlong lines themselves
+		}
+		if err != nil {
+			s.end(err)
+			return
+		}
+		s.mu.Lock()
+		conns := make([]*sharedConn, 0, len(s.conns))
+		for sc := range s.conns {
+			conns = append(conns, sc)
+		}
+		s.mu.Unlock()
+		for _, sc := range conns {
+			select {
+			case sc.lines <- append([]byte(nil), bs...):
+			default:
+				// The Amp is behind by sessionLines; drop it rather
+				// than hold up the others.
+				s.drop(sc, errFellBehind)
+			}
+		}
+	}
+}
+
+// end records that s's connection failed with err, so that its
+// handles report err and the next Amp to connect dials anew.
+func (s *session) end(err error) {
+	s.remove()
+	s.c.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.readErr == nil {
+		s.readErr = err
+	}
+	for sc := range s.conns {
+		close(sc.lines)
+	}
+	s.conns = nil
+}
+
+// remove removes s from sessions, if it is still there.
+func (s *session) remove() {
+	sessions.Lock()
+	defer sessions.Unlock()
+	if sessions.m[s.key] == s {
+		delete(sessions.m, s.key)
+	}
+}
+
+// leave removes sc from s, closing s's connection if sc was the last
+// handle on it and waiting for its reader to stop.
+func (s *session) leave(sc *sharedConn) {
+	s.mu.Lock()
+	last := s.detach(sc)
+	s.mu.Unlock()
+	if last {
+		<-s.stopped
+	}
+}
+
+// drop removes sc from s, making its reads fail with err, and closes
+// s's connection if sc was the last handle on it.
+//
+// run in reader goroutine
+func (s *session) drop(sc *sharedConn, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conns[sc] {
+		sc.err = err
+		close(sc.lines)
+	}
+	s.detach(sc)
+}
+
+// detach removes sc from s, ending s if sc was its last handle, and
+// reports whether it was. The session is ended under mu, so that a
+// join racing with the last detach either keeps the connection open
+// or finds it ended, never closing.
+//
+// must be called with s.mu held
+func (s *session) detach(sc *sharedConn) bool {
+	if !s.conns[sc] {
+		return false
+	}
+	delete(s.conns, sc)
+	if len(s.conns) > 0 {
+		return false
+	}
+	s.readErr = net.ErrClosed
+	s.remove()
+	s.c.Close()
+	return true
+}
+
+func (sc *sharedConn) Read(p []byte) (int, error) {
+	if len(sc.buf) == 0 {
+		select {
+		case l, ok := <-sc.lines:
+			if !ok {
+				sc.s.mu.Lock()
+				defer sc.s.mu.Unlock()
+				if sc.err != nil {
+					return 0, sc.err
+				}
+				return 0, sc.s.readErr
+			}
+			sc.buf = l
+		case <-sc.done:
+			return 0, net.ErrClosed
+		}
+	}
+	n := copy(p, sc.buf)
+	sc.buf = sc.buf[n:]
+	return n, nil
+}
+
+func (sc *sharedConn) Write(p []byte) (int, error) {
+	select {
+	case <-sc.done:
+		return 0, net.ErrClosed
+	default:
+	}
+	sc.s.wmu.Lock()
+	defer sc.s.wmu.Unlock()
+	return sc.s.c.Write(p)
+}
+
+// Close leaves the session, unblocking any Read in progress.
+func (sc *sharedConn) Close() error {
+	sc.once.Do(func() {
+		close(sc.done)
+		sc.s.leave(sc)
+	})
+	return nil
+}
+
+// abort closes the session's connection, ending it with err for all
+// its Amps, as when the amp has gone silent. Detaching sc alone would
+// leave the dead connection open for as long as other Amps share it,
+// each of them rejoining it in turn.
+func (sc *sharedConn) abort(err error) {
+	sc.s.mu.Lock()
+	if sc.s.readErr == nil {
+		sc.s.readErr = err
+	}
+	sc.s.mu.Unlock()
+	sc.s.remove()
+	sc.s.c.Close()
+	sc.Close()
+}
+
+// RemoteAddr returns the amp's address.
+func (sc *sharedConn) RemoteAddr() net.Addr { return sc.s.c.RemoteAddr() }
+
+// LocalAddr returns the session's local address.
+func (sc *sharedConn) LocalAddr() net.Addr { return sc.s.c.LocalAddr() }
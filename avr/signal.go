@@ -0,0 +1,118 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// SignalInfo describes the signal on the main zone's current input.
+// Values are as the amp reports them; empty if not reported.
+type SignalInfo struct {
+	SampleRate  int    `json:"sample_rate"`  // audio sampling rate in Hz, zero if none
+	AudioFormat string `json:"audio_format"` // such as "PCM" or "DOLBY TRUEHD"
+	Video       string `json:"video"`        // video resolution, such as "1080p60"
+	HDR         string `json:"hdr"`          // such as "HDR10"; empty for SDR
+}
+
+// SSINF parameters for each SignalInfo field.
+const (
+	sigSampleRate = "AISFSV"
+	sigAudio      = "AISSIG"
+	sigVideo      = "SIGRES"
+	sigHDR        = "HDR"
+)
+
+var signalParams = []string{sigSampleRate, sigAudio, sigVideo, sigHDR}
+
+// signalTimeout is how long each signal query waits for an answer
+// once written, as older models don't answer them at all.
+const signalTimeout = time.Second
+
+// SignalInfo asks the amp about the signal on the main zone's input.
+// Parameters the amp doesn't answer for are left empty; if it
+// ignores the queries for all of them, SignalInfo returns
+// ErrUnsupported. If the amp was on at the time, it does so without
+// asking until the Amp reconnects or the amp is next turned on.
+func (a *Amp) SignalInfo(ctx context.Context) (*SignalInfo, error) {
+	a.mu.Lock()
+	unsupported := a.noSignalInfo
+	a.mu.Unlock()
+	if unsupported {
+		return nil, ErrUnsupported
+	}
+	si := new(SignalInfo)
+	answered := false
+	for _, p := range signalParams {
+		l, res, err := a.probeQuery(ctx, "SSINF"+p+" ?", prefix("SSINF"+p+" "), signalTimeout)
+		if res == probeIgnored {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if sig, ok := proto.Parse(l).(proto.Signal); ok {
+			si.apply(sig)
+			answered = true
+		}
+	}
+	if !answered {
+		a.mu.Lock()
+		a.noSignalInfo = a.cache.Power // in standby, the amp ignores them anyway
+		a.mu.Unlock()
+		return nil, ErrUnsupported
+	}
+	return si, nil
+}
+
+// apply updates si from sig, reporting whether si changed.
+func (si *SignalInfo) apply(sig proto.Signal) bool {
+	old := *si
+	switch sig.Param {
+	case sigSampleRate:
+		si.SampleRate = parseSampleRate(sig.Value)
+	case sigAudio:
+		si.AudioFormat = sig.Value
+	case sigVideo:
+		si.Video = sig.Value
+	case sigHDR:
+		si.HDR = sig.Value
+		if si.HDR == "SDR" || si.HDR == "NON" {
+			si.HDR = ""
+		}
+	}
+	return *si != old
+}
+
+// parseSampleRate parses a rate such as "48K" or "44.1K" into Hz.
+func parseSampleRate(s string) int {
+	khz, ok := strings.CutSuffix(strings.ToUpper(s), "K")
+	if !ok {
+		return 0
+	}
+	f, err := strconv.ParseFloat(khz, 64)
+	if err != nil || f < 0 {
+		return 0
+	}
+	return int(f*1000 + 0.5)
+}
+
+// updateSignal applies a signal line to the cached signal info and
+// publishes SignalChanged if it changed.
+//
+// must be called with mu held
+func (a *Amp) updateSignal(sig proto.Signal) {
+	if a.cache.Signal == nil {
+		a.cache.Signal = new(SignalInfo)
+	}
+	if a.cache.Signal.apply(sig) {
+		a.cacheChanged()
+		a.publish(SignalChanged{Signal: *a.cache.Signal})
+	}
+}
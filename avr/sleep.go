@@ -0,0 +1,45 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"fmt"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// MaxSleepTimer is the longest sleep timer the amp accepts, in minutes.
+const MaxSleepTimer = 120
+
+// SetSleepTimer sets the main zone's sleep timer to the given number
+// of minutes, from 1 to MaxSleepTimer. Zero turns the timer off.
+func (a *Amp) SetSleepTimer(minutes int) error {
+	cmd, err := sleepCmd("SLP", minutes)
+	if err != nil {
+		return err
+	}
+	return a.setConfirm(cmd, is[proto.Sleep], cmd)
+}
+
+// SleepTimer returns the minutes remaining on the main zone's sleep
+// timer, or zero if it is off.
+func (a *Amp) SleepTimer() (minutes int, err error) {
+	l, err := a.timeoutQuery("SLP?", is[proto.Sleep])
+	if err != nil {
+		return 0, err
+	}
+	return proto.Parse(l).(proto.Sleep).Minutes, nil
+}
+
+// sleepCmd returns the command setting a sleep timer, where p is the
+// command's prefix.
+func sleepCmd(p string, minutes int) (string, error) {
+	if minutes == 0 {
+		return p + "OFF", nil
+	}
+	if minutes < 1 || minutes > MaxSleepTimer {
+		return "", fmt.Errorf("sleep timer %d minutes out of range [1, %d]", minutes, MaxSleepTimer)
+	}
+	return fmt.Sprintf("%s%03d", p, minutes), nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Speaker presets. Newer models store two speaker configurations,
+// such as a stereo pair and a full Atmos layout.
+const (
+	SpeakerPreset1 = 1
+	SpeakerPreset2 = 2
+)
+
+// SetSpeakerPreset switches to speaker configuration preset n,
+// SpeakerPreset1 or SpeakerPreset2, and waits for the amp to
+// confirm. It returns ErrUnsupported if the amp is known to have no
+// presets.
+func (a *Amp) SetSpeakerPreset(n int) error {
+	if n != SpeakerPreset1 && n != SpeakerPreset2 {
+		return fmt.Errorf("invalid speaker preset %d", n)
+	}
+	if err := a.checkSpeakerPresets(); err != nil {
+		return err
+	}
+	return a.setParam("SPPR ", strconv.Itoa(n))
+}
+
+// GetSpeakerPreset returns the speaker configuration preset in use.
+func (a *Amp) GetSpeakerPreset() (int, error) {
+	if err := a.checkSpeakerPresets(); err != nil {
+		return 0, err
+	}
+	v, err := a.param("SPPR ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || (n != SpeakerPreset1 && n != SpeakerPreset2) {
+		return 0, fmt.Errorf("invalid speaker preset %q", v)
+	}
+	return n, nil
+}
+
+// checkSpeakerPresets returns ErrUnsupported if the amp is known to
+// have no speaker presets.
+func (a *Amp) checkSpeakerPresets() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.caps.Known && !a.caps.SpeakerPresets {
+		return fmt.Errorf("%w: speaker presets", ErrUnsupported)
+	}
+	return nil
+}
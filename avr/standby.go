@@ -0,0 +1,69 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import "fmt"
+
+// AutoStandby is an auto standby delay. The main zone accepts
+// minutes and the other zones hours.
+type AutoStandby string
+
+const (
+	AutoStandbyOff AutoStandby = "OFF"
+
+	// Main zone.
+	AutoStandby15m AutoStandby = "15M"
+	AutoStandby30m AutoStandby = "30M"
+	AutoStandby60m AutoStandby = "60M"
+
+	// Zones 2 and 3.
+	AutoStandby2h AutoStandby = "2H"
+	AutoStandby4h AutoStandby = "4H"
+	AutoStandby8h AutoStandby = "8H"
+)
+
+// SetAutoStandby sets the main zone's auto standby delay.
+func (a *Amp) SetAutoStandby(s AutoStandby) error {
+	return a.MainZone().SetAutoStandby(s)
+}
+
+// GetAutoStandby returns the main zone's auto standby delay.
+func (a *Amp) GetAutoStandby() (AutoStandby, error) {
+	return a.MainZone().AutoStandby()
+}
+
+// SetAutoStandby sets the zone's auto standby delay.
+func (z *Zone) SetAutoStandby(s AutoStandby) error {
+	switch s {
+	case AutoStandbyOff:
+	case AutoStandby15m, AutoStandby30m, AutoStandby60m:
+		if z.n != 1 {
+			return fmt.Errorf("auto standby %q not valid for zone %d", s, z.n)
+		}
+	case AutoStandby2h, AutoStandby4h, AutoStandby8h:
+		if z.n == 1 {
+			return fmt.Errorf("auto standby %q not valid for the main zone", s)
+		}
+	default:
+		return fmt.Errorf("invalid auto standby %q", s)
+	}
+	return z.a.setParam(z.standbyParam(), string(s))
+}
+
+// AutoStandby returns the zone's auto standby delay.
+func (z *Zone) AutoStandby() (AutoStandby, error) {
+	p := z.standbyParam()
+	l, err := z.a.timeoutQuery(p+"?", prefix(p))
+	if err != nil {
+		return "", err
+	}
+	return AutoStandby(l[len(p):]), nil
+}
+
+func (z *Zone) standbyParam() string {
+	if z.n == 1 {
+		return "STBY"
+	}
+	return z.prefix() + "STBY"
+}
@@ -0,0 +1,205 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// Status is a snapshot of the amp's main settings. It marshals to
+// and from JSON, so a snapshot can be saved and later restored with
+// ApplyStatus.
+type Status struct {
+	Power        bool         `json:"power"`
+	Volume       float64      `json:"volume"` // master volume in dB
+	Input        InputSource  `json:"input"`
+	SurroundMode SurroundMode `json:"surround_mode"`
+	Muted        bool         `json:"muted"`
+	Headphones   bool         `json:"headphones"` // false if the amp doesn't report them
+
+	// Zone2 and Zone3 are nil if the amp did not answer for them.
+	Zone2 *ZoneStatus `json:"zone2,omitempty"`
+	Zone3 *ZoneStatus `json:"zone3,omitempty"`
+
+	// Signal is nil if the amp doesn't report its input signal.
+	Signal *SignalInfo `json:"signal,omitempty"`
+
+	// Web is nil unless the Amp was created WithWebStatus. It is not
+	// tracked by CachedState.
+	Web *WebStatus `json:"web,omitempty"`
+}
+
+// ZoneStatus is a snapshot of a zone's settings.
+type ZoneStatus struct {
+	Power  bool        `json:"power"`
+	Volume float64     `json:"volume"` // in dB
+	Source InputSource `json:"source"`
+	Muted  bool        `json:"muted"`
+}
+
+// zoneListQuiet is how long a zone status query waits for further
+// lines once the amp has started answering.
+const zoneListQuiet = 300 * time.Millisecond
+
+// Status queries the amp for its power, volume, input, surround mode
+// and mute state, those of zones 2 and 3, its input signal and
+// whether headphones are plugged in. If the Amp was created
+// WithWebStatus, it also fetches the amp's web status. Unless ctx has
+// a deadline, each query gives up after the command timeout.
+func (a *Amp) Status(ctx context.Context) (*Status, error) {
+	st := new(Status)
+	l, err := a.queryContext(ctx, "PW?", is[proto.Power])
+	if err != nil {
+		return nil, err
+	}
+	st.Power = proto.Parse(l).(proto.Power).On
+	if l, err = a.queryContext(ctx, "MV?", isVolumeLine); err != nil {
+		return nil, err
+	}
+	st.Volume = proto.Parse(l).(proto.Volume).DB
+	if l, err = a.queryContext(ctx, "SI?", is[proto.Input]); err != nil {
+		return nil, err
+	}
+	st.Input = InputSource(proto.Parse(l).(proto.Input).Source)
+	if l, err = a.queryContext(ctx, "MS?", isSurroundLine); err != nil {
+		return nil, err
+	}
+	st.SurroundMode = surroundMode(proto.Parse(l).(proto.Surround))
+	if l, err = a.queryContext(ctx, "MU?", is[proto.Mute]); err != nil {
+		return nil, err
+	}
+	st.Muted = proto.Parse(l).(proto.Mute).On
+	if st.Zone2, err = a.Zone2().status(ctx); err != nil {
+		return nil, err
+	}
+	if st.Zone3, err = a.Zone3().status(ctx); err != nil {
+		return nil, err
+	}
+	if st.Signal, err = a.SignalInfo(ctx); err != nil && !errors.Is(err, ErrUnsupported) {
+		return nil, err
+	}
+	if st.Headphones, err = a.Headphones(ctx); err != nil && !errors.Is(err, ErrUnsupported) {
+		return nil, err
+	}
+	if a.webClient != nil {
+		if st.Web, err = a.WebStatus(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+// status queries the state of a zone other than the main zone. It
+// returns nil and no error if the amp doesn't answer for the zone.
+func (z *Zone) status(ctx context.Context) (*ZoneStatus, error) {
+	p := z.prefix()
+	var power, volume, source bool
+	end := func(l string) bool {
+		if ev, ok := parseEvent(l).(ZoneEvent); ok {
+			switch ev.Event.(type) {
+			case PowerChanged:
+				power = true
+			case VolumeChanged:
+				volume = true
+			case InputChanged:
+				source = true
+			}
+		}
+		return power && volume && source
+	}
+	lines, err := z.a.queryLines(ctx, p+"?", zoneIs[proto.Message](z.n), end, zoneListQuiet)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if errors.Is(err, ErrTimeout) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	zs := new(ZoneStatus)
+	for _, l := range lines {
+		if ev, ok := parseEvent(l).(ZoneEvent); ok {
+			zs.apply(ev.Event)
+		}
+	}
+	l, err := z.a.queryContext(ctx, p+"MU?", zoneIs[proto.Mute](z.n))
+	if err != nil {
+		return nil, err
+	}
+	zs.apply(parseEvent(l).(ZoneEvent).Event)
+	return zs, nil
+}
+
+// restoreWarmUp is how long ApplyStatus waits after turning the amp
+// on before changing its settings.
+const restoreWarmUp = 3 * time.Second
+
+// ApplyStatus restores the settings in st, as saved from Status: it
+// turns the amp on, selects the main zone's input, surround mode,
+// volume and muting, and sets each zone in st likewise, or, if
+// st.Power is false, puts the amp into standby. The signal and web
+// status are ignored, as is an empty input or surround mode. The
+// settings are applied as a Scene, so failures are reported as a
+// *SceneError naming the setting.
+func (a *Amp) ApplyStatus(ctx context.Context, st *Status) error {
+	s := &Scene{Name: "restore status"}
+	if !st.Power {
+		s.Steps = append(s.Steps, PowerOffStep())
+		return a.RunScene(ctx, s)
+	}
+	on, err := a.PowerState()
+	if err != nil {
+		return err
+	}
+	warmUp := restoreWarmUp
+	if on {
+		warmUp = 0
+	}
+	s.Steps = append(s.Steps, PowerOnStep(warmUp))
+	if st.Input != "" {
+		s.Steps = append(s.Steps, SelectInputStep(st.Input))
+	}
+	if st.SurroundMode != "" {
+		s.Steps = append(s.Steps, SetSurroundModeStep(st.SurroundMode))
+	}
+	s.Steps = append(s.Steps, SetVolumeStep(st.Volume), MuteStep(st.Muted))
+	if st.Zone2 != nil {
+		s.Steps = append(s.Steps, a.Zone2().restoreSteps(st.Zone2)...)
+	}
+	if st.Zone3 != nil {
+		s.Steps = append(s.Steps, a.Zone3().restoreSteps(st.Zone3)...)
+	}
+	return a.RunScene(ctx, s)
+}
+
+// restoreSteps returns the steps restoring the zone to zs.
+func (z *Zone) restoreSteps(zs *ZoneStatus) []Step {
+	name := fmt.Sprintf("zone %d ", z.n)
+	step := func(what string, do func() error) Step {
+		return Step{
+			Name: name + what,
+			Do:   func(ctx context.Context, a *Amp) error { return do() },
+		}
+	}
+	if !zs.Power {
+		return []Step{step("power off", z.PowerOff)}
+	}
+	steps := []Step{step("power on", z.PowerOn)}
+	if zs.Source != "" {
+		steps = append(steps, step("select "+string(zs.Source), func() error { return z.SetSource(zs.Source) }))
+	}
+	mute := "unmute"
+	if zs.Muted {
+		mute = "mute"
+	}
+	return append(steps,
+		step(fmt.Sprintf("set volume %vdB", zs.Volume), func() error { return z.SetVolume(zs.Volume) }),
+		step(mute, func() error { return z.Mute(zs.Muted) }))
+}
@@ -0,0 +1,207 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"sync"
+)
+
+// An OverflowPolicy decides what happens to the events a subscriber
+// has no room for because it fell behind.
+type OverflowPolicy int
+
+const (
+	// DropNewest drops events until the subscriber makes room, as
+	// Subscribe does.
+	DropNewest OverflowPolicy = iota
+
+	// DropOldest drops the subscriber's oldest unread events to make
+	// room for new ones, for subscribers that care about the current
+	// state more than its history.
+	DropOldest
+
+	// Block delivers every event, in order. Events the subscriber has
+	// no room for wait in a queue of its own, without limit, so that
+	// the Amp never waits for the subscriber.
+	Block
+)
+
+// SubscribeWith is like Subscribe but with a channel of capacity
+// buffer, at least 2, and the given policy when it is full. When
+// events are dropped, the subscriber receives an Overflow before the
+// next event that fits.
+func (a *Amp) SubscribeWith(buffer int, policy OverflowPolicy) (events <-chan Event, cancel func()) {
+	s := &subscriber{
+		ch:     make(chan Event, max(buffer, 2)),
+		policy: policy,
+	}
+	if policy == Block {
+		s.wake = make(chan struct{}, 1)
+		s.stop = make(chan struct{})
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		close(s.ch)
+		return s.ch, func() {}
+	}
+	if a.subscribers == nil {
+		a.subscribers = make(map[*subscriber]bool)
+	}
+	a.subscribers[s] = true
+	if policy == Block {
+		a.wg.Add(1)
+		go s.forward(&a.wg)
+	}
+	return s.ch, func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.subscribers[s] { // else already closed
+			delete(a.subscribers, s)
+			s.close()
+		}
+	}
+}
+
+// A subscriber is a channel returned by Subscribe or SubscribeWith.
+type subscriber struct {
+	ch      chan Event
+	policy  OverflowPolicy
+	dropped int // events dropped since the last Overflow
+
+	// If Block:
+	mu    sync.Mutex
+	queue []Event       // waiting for room in ch
+	wake  chan struct{} // signaled when queue grows
+	stop  chan struct{} // closed by close
+}
+
+// send delivers ev according to s's policy.
+//
+// must be called with the Amp's mu held
+func (s *subscriber) send(ev Event) {
+	switch s.policy {
+	case Block:
+		s.mu.Lock()
+		s.queue = append(s.queue, ev)
+		s.mu.Unlock()
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	case DropOldest:
+		need := 1
+		if s.dropped > 0 {
+			need = 2
+		}
+		for cap(s.ch)-len(s.ch) < need {
+			select {
+			case old := <-s.ch:
+				if o, ok := old.(Overflow); ok {
+					s.dropped += o.Dropped
+				} else {
+					s.dropped++
+				}
+				need = 2
+			default:
+				// The subscriber made room.
+			}
+		}
+		s.flush()
+		s.ch <- ev
+	default:
+		s.flush()
+		select {
+		case s.ch <- ev:
+		default:
+			s.dropped++
+		}
+	}
+}
+
+// flush sends an Overflow for the events dropped so far, if any and
+// if there is room for it.
+//
+// must be called with the Amp's mu held
+func (s *subscriber) flush() {
+	if s.dropped == 0 {
+		return
+	}
+	select {
+	case s.ch <- Overflow{Dropped: s.dropped}:
+		s.dropped = 0
+	default:
+	}
+}
+
+// forward sends the queued events of a Block subscriber until it is
+// closed, then closes its channel.
+func (s *subscriber) forward(wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer close(s.ch)
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.queue = nil // release the backing array
+			s.mu.Unlock()
+			select {
+			case <-s.wake:
+				continue
+			case <-s.stop:
+				return
+			}
+		}
+		ev := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+		select {
+		case s.ch <- ev:
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// close closes s's channel, once forward has stopped if s is a Block
+// subscriber.
+//
+// must be called with the Amp's mu held
+func (s *subscriber) close() {
+	if s.policy == Block {
+		close(s.stop)
+		return
+	}
+	close(s.ch)
+}
+
+// nextEvents waits for an event from events, a subscription to a, and
+// passes it and every event queued behind it to handle, so that code
+// following the amp's state acts once on the latest of a burst of
+// changes. An Overflow is passed on as Resynced with the cached state,
+// which already includes the changes the dropped events reported.
+// nextEvents returns ctx.Err() once ctx is done, and ErrClosed once
+// events is closed.
+func (a *Amp) nextEvents(ctx context.Context, events <-chan Event, handle func(Event)) error {
+	var ev Event
+	select {
+	case ev = <-events:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	for {
+		if ev == nil {
+			return ErrClosed
+		}
+		if _, ok := ev.(Overflow); ok {
+			ev = Resynced{Status: a.CachedState().Status}
+		}
+		handle(ev)
+		select {
+		case ev = <-events:
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// Subwoofer level limits in dB. Like channel levels, the amp encodes
+// the subwoofer level with 50 as 0dB.
+const (
+	MinSubwooferLevel = -12.0
+	MaxSubwooferLevel = 12.0
+)
+
+// LFE level limits in dB. The amp encodes the LFE level as its
+// attenuation, so "PSLFE 10" is -10dB.
+const (
+	MinLFELevel = -10
+	MaxLFELevel = 0
+)
+
+// SubwooferOn enables the subwoofer level adjustment.
+func (a *Amp) SubwooferOn() error {
+	return a.setConfirm("PSSWL ON", isSubwooferSwitchLine, "PSSWL ON")
+}
+
+// SubwooferOff disables the subwoofer level adjustment.
+func (a *Amp) SubwooferOff() error {
+	return a.setConfirm("PSSWL OFF", isSubwooferSwitchLine, "PSSWL OFF")
+}
+
+// SetSubwooferLevel sets the subwoofer level to db, rounded to the
+// nearest half step.
+func (a *Amp) SetSubwooferLevel(db float64) error {
+	if math.IsNaN(db) || db < MinSubwooferLevel || db > MaxSubwooferLevel {
+		return fmt.Errorf("subwoofer level %vdB out of range [%v, %v]", db, MinSubwooferLevel, MaxSubwooferLevel)
+	}
+	cmd := "PSSWL " + encodeLevel(db, channelZeroLevel)
+	return a.setConfirm(cmd, isSubwooferLevelLine, cmd)
+}
+
+// SubwooferLevel returns the subwoofer level in dB.
+func (a *Amp) SubwooferLevel() (db float64, err error) {
+	l, err := a.timeoutQuery("PSSWL ?", isSubwooferLevelLine)
+	if err != nil {
+		return 0, err
+	}
+	return proto.ParseLevel(l[len("PSSWL "):], channelZeroLevel)
+}
+
+// SetLFELevel sets the LFE channel level, from MinLFELevel to
+// MaxLFELevel dB.
+func (a *Amp) SetLFELevel(db int) error {
+	if db < MinLFELevel || db > MaxLFELevel {
+		return fmt.Errorf("LFE level %ddB out of range [%d, %d]", db, MinLFELevel, MaxLFELevel)
+	}
+	return a.setParam("PSLFE ", fmt.Sprintf("%02d", -db))
+}
+
+// LFELevel returns the LFE channel level in dB.
+func (a *Amp) LFELevel() (db int, err error) {
+	v, err := a.param("PSLFE ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid LFE level %q", v)
+	}
+	return -n, nil
+}
+
+func isSubwooferSwitchLine(l string) bool {
+	return l == "PSSWL ON" || l == "PSSWL OFF"
+}
+
+func isSubwooferLevelLine(l string) bool {
+	v := strings.TrimPrefix(l, "PSSWL ")
+	_, err := proto.ParseLevel(v, channelZeroLevel)
+	return v != l && err == nil
+}
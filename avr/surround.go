@@ -0,0 +1,84 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"errors"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// SurroundMode is a surround mode name as used by the MS command.
+// Modes the amp reports that are not listed below, such as
+// "DOLBY D+PL2X C", are represented by the amp's own name.
+type SurroundMode string
+
+// Surround modes that can be selected. The amp reports the decoded
+// mode it actually chose, so selecting SurroundAuto or SurroundMovie
+// reads back as something like "DOLBY DIGITAL".
+const (
+	SurroundMovie          SurroundMode = "MOVIE"
+	SurroundMusic          SurroundMode = "MUSIC"
+	SurroundGame           SurroundMode = "GAME"
+	SurroundDirect         SurroundMode = "DIRECT"
+	SurroundPureDirect     SurroundMode = "PURE DIRECT"
+	SurroundStereo         SurroundMode = "STEREO"
+	SurroundAuto           SurroundMode = "AUTO"
+	SurroundNeural         SurroundMode = "NEURAL"
+	SurroundStandard       SurroundMode = "STANDARD"
+	SurroundDolbyDigital   SurroundMode = "DOLBY DIGITAL"
+	SurroundDTS            SurroundMode = "DTS SURROUND"
+	SurroundMultiChStereo  SurroundMode = "MCH STEREO"
+	SurroundWideScreen     SurroundMode = "WIDE SCREEN"
+	SurroundSuperStadium   SurroundMode = "SUPER STADIUM"
+	SurroundRockArena      SurroundMode = "ROCK ARENA"
+	SurroundJazzClub       SurroundMode = "JAZZ CLUB"
+	SurroundClassicConcert SurroundMode = "CLASSIC CONCERT"
+	SurroundMonoMovie      SurroundMode = "MONO MOVIE"
+	SurroundMatrix         SurroundMode = "MATRIX"
+	SurroundVideoGame      SurroundMode = "VIDEO GAME"
+	SurroundVirtual        SurroundMode = "VIRTUAL"
+)
+
+// surroundAliases maps names the amp reports to the name used to
+// select the same mode.
+var surroundAliases = map[string]SurroundMode{
+	"MULTI CH STEREO": SurroundMultiChStereo,
+	"MCH STEREO":      SurroundMultiChStereo,
+	"DTS SUR":         SurroundDTS,
+}
+
+// SetSurroundMode selects the main zone's surround mode and waits
+// for the amp to report the resulting mode.
+func (a *Amp) SetSurroundMode(m SurroundMode) error {
+	if m == "" {
+		return errors.New("empty surround mode")
+	}
+	_, err := a.timeoutQuery("MS"+string(m), isSurroundLine)
+	return err
+}
+
+// GetSurroundMode returns the main zone's current surround mode.
+func (a *Amp) GetSurroundMode() (SurroundMode, error) {
+	l, err := a.timeoutQuery("MS?", isSurroundLine)
+	if err != nil {
+		return "", err
+	}
+	return surroundMode(proto.Parse(l).(proto.Surround)), nil
+}
+
+// isSurroundLine reports whether l is a surround mode report, as
+// opposed to another MS parameter such as "MSQUICK1".
+func isSurroundLine(l string) bool {
+	return is[proto.Surround](l)
+}
+
+// surroundMode returns the mode reported by m, translating names
+// the amp reports into the names used to select them.
+func surroundMode(m proto.Surround) SurroundMode {
+	if sm, ok := surroundAliases[m.Mode]; ok {
+		return sm
+	}
+	return SurroundMode(m.Mode)
+}
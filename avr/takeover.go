@@ -0,0 +1,88 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"time"
+)
+
+// WithReclaim makes the Amp take the control session back after
+// another client, such as the amp's mobile app, takes it over. The
+// amp allows a single session, and ends the old one when a new
+// client connects; the Amp then reconnects after initial, and each
+// time the session is taken over again within maxDelay of reclaiming
+// it, waits twice as long as the time before, up to maxDelay. By
+// default the Amp stays disconnected once the session is taken over,
+// leaving the other client be, until Ping or a request reconnects it.
+//
+// The amp also ends the session when it restarts or drops an idle
+// connection, after which the Amp reconnects as usual, so the session
+// counts as taken over only when the amp ends it within 10 seconds of
+// connecting: the Amp's reconnect took the session from the other
+// client, which took it straight back.
+func WithReclaim(initial, maxDelay time.Duration) Option {
+	return func(a *Amp) {
+		a.reclaimMin = initial
+		a.reclaimMax = max(initial, maxDelay)
+	}
+}
+
+// takeoverWindow is how soon after connecting the amp ending the
+// session shows that another client took it over.
+const takeoverWindow = 10 * time.Second
+
+// isTakeover reports whether connection error err, ending a
+// connection that lasted age, means that another client took the
+// session over: the amp, rather than the Amp or the network, ended it
+// soon after the Amp connected, and no write to it had failed first,
+// which would make the close the amp's answer to the Amp's own
+// failure. The amp also ends it to restart after a firmware update,
+// which the loop tells apart.
+func isTakeover(err error, age time.Duration, writeFailed bool) bool {
+	return age < takeoverWindow && !writeFailed && (errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET))
+}
+
+// reclaim tracks the WithReclaim backoff. It is only used by the
+// loop goroutine.
+type reclaim struct {
+	delay time.Duration // before the next reclaim; 0 for the initial delay
+}
+
+// takenOver handles the amp ending a session that lasted age with
+// err, publishing SessionTakenOver and scheduling a reconnect if
+// WithReclaim is in effect.
+//
+// run in loop goroutine
+func (a *Amp) takenOver(rc *reclaim, age time.Duration, err error) {
+	ev := SessionTakenOver{}
+	if a.reclaimMin > 0 {
+		if rc.delay == 0 || age > a.reclaimMax {
+			rc.delay = a.reclaimMin
+		} else {
+			rc.delay = min(2*rc.delay, a.reclaimMax)
+		}
+		ev.ReclaimAt = time.Now().Add(rc.delay)
+		a.log.Error("avr: session taken over; reclaiming", "addr", a.addr, "err", err, "delay", rc.delay)
+	} else {
+		a.log.Error("avr: session taken over", "addr", a.addr, "err", err)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.reclaimMin > 0 && !a.closed {
+		if a.reclaimTimer != nil {
+			a.reclaimTimer.Stop()
+		}
+		a.reclaimTimer = time.AfterFunc(rc.delay, a.reconnect)
+	}
+	a.publish(ev)
+}
+
+// takeoverErr returns err wrapped with ErrSessionTakenOver.
+func takeoverErr(err error) error {
+	return fmt.Errorf("%w: %w", ErrSessionTakenOver, err)
+}
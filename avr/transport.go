@@ -0,0 +1,25 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"io"
+)
+
+// A Transport opens the control connection to an amp. The amp speaks
+// the same protocol over TCP and, on many models, RS-232.
+type Transport interface {
+	// Open connects to the amp. ctx is done when the dial timeout
+	// expires or the Amp is closed. Closing the returned connection
+	// must unblock any Read in progress.
+	Open(ctx context.Context) (io.ReadWriteCloser, error)
+}
+
+// WithTransport makes the Amp connect through t instead of over TCP.
+// The address given to New is then only used in logs and by Addr.
+// WithTransport overrides WithDialer.
+func WithTransport(t Transport) Option {
+	return func(a *Amp) { a.transport = t }
+}
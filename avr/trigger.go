@@ -0,0 +1,44 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// MaxTrigger is the highest 12V trigger output number.
+const MaxTrigger = 3
+
+// SetTrigger switches 12V trigger output n on or off.
+func (a *Amp) SetTrigger(n int, on bool) error {
+	p, err := triggerParam(n)
+	if err != nil {
+		return err
+	}
+	return a.setOnOff(p, on)
+}
+
+// Trigger reports whether 12V trigger output n is on.
+func (a *Amp) Trigger(n int) (bool, error) {
+	p, err := triggerParam(n)
+	if err != nil {
+		return false, err
+	}
+	// TR? reports every trigger, one per line.
+	l, err := a.timeoutQuery("TR?", prefix(p))
+	if err != nil {
+		return false, err
+	}
+	return parseOnOff(p, l[len(p):])
+}
+
+// triggerParam returns the parameter name of trigger n, such as
+// "TR1 ".
+func triggerParam(n int) (string, error) {
+	if n < 1 || n > MaxTrigger {
+		return "", fmt.Errorf("trigger %d out of range [1, %d]", n, MaxTrigger)
+	}
+	return "TR" + strconv.Itoa(n) + " ", nil
+}
@@ -0,0 +1,204 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// A Tuner controls the amp's AM/FM tuner with the TF, TP and TM
+// commands.
+type Tuner struct {
+	a *Amp
+}
+
+// Tuner returns the amp's tuner.
+func (a *Amp) Tuner() *Tuner { return &Tuner{a: a} }
+
+// Band is a tuner band.
+type Band string
+
+const (
+	BandAM Band = "AM"
+	BandFM Band = "FM"
+)
+
+// Frequency is a tuner frequency in kHz. Frequencies of 50MHz and
+// above are on the FM band.
+type Frequency int
+
+// fmThreshold is the lowest FM frequency, and also the lowest
+// encoded AM frequency: the amp encodes FM in units of 10kHz and AM
+// in units of 0.01kHz, so that 105.00MHz is "010500" and 1000kHz is
+// "100000".
+const fmThreshold = 50000
+
+// Band returns the band of f.
+func (f Frequency) Band() Band {
+	if f >= fmThreshold {
+		return BandFM
+	}
+	return BandAM
+}
+
+func (f Frequency) String() string {
+	if f.Band() == BandFM {
+		return fmt.Sprintf("%.2fMHz", float64(f)/1000)
+	}
+	return fmt.Sprintf("%dkHz", int(f))
+}
+
+// encode returns the amp's six digit encoding of f.
+func (f Frequency) encode() (string, error) {
+	var n int
+	switch {
+	case f >= fmThreshold && f <= 108000:
+		n = int(f) / 10
+	case f >= 500 && f <= 1710:
+		n = int(f) * 100
+	default:
+		return "", fmt.Errorf("frequency %v out of range", f)
+	}
+	return fmt.Sprintf("%06d", n), nil
+}
+
+// isFrequencyLine reports whether l is a frequency report such as
+// "TFAN010500", as opposed to "TFANNAME...".
+func isFrequencyLine(l string) bool {
+	return is[proto.Frequency](l)
+}
+
+// SetFrequency tunes to f, switching bands as needed, and waits for
+// the amp to confirm.
+func (t *Tuner) SetFrequency(f Frequency) error {
+	enc, err := f.encode()
+	if err != nil {
+		return err
+	}
+	cmd := "TFAN" + enc
+	return t.a.setConfirm(cmd, isFrequencyLine, cmd)
+}
+
+// Frequency returns the frequency the tuner is tuned to.
+func (t *Tuner) Frequency() (Frequency, error) {
+	l, err := t.a.timeoutQuery("TFAN?", isFrequencyLine)
+	if err != nil {
+		return 0, err
+	}
+	return Frequency(proto.Parse(l).(proto.Frequency).KHz), nil
+}
+
+// SeekUp switches the tuner to auto tuning and seeks to the next
+// station up the band. It returns once the seek has started.
+func (t *Tuner) SeekUp() error {
+	return t.seek("TFANUP")
+}
+
+// SeekDown is like SeekUp but seeks down the band.
+func (t *Tuner) SeekDown() error {
+	return t.seek("TFANDOWN")
+}
+
+func (t *Tuner) seek(cmd string) error {
+	if err := t.a.setConfirm("TMANAUTO", isTuningModeLine, "TMANAUTO"); err != nil {
+		return err
+	}
+	_, err := t.a.timeoutQuery(cmd, isFrequencyLine)
+	return err
+}
+
+func isTuningModeLine(l string) bool {
+	return l == "TMANAUTO" || l == "TMANMANUAL"
+}
+
+func isBandLine(l string) bool {
+	return l == "TMANAM" || l == "TMANFM"
+}
+
+// SetBand switches the tuner to band b.
+func (t *Tuner) SetBand(b Band) error {
+	if b != BandAM && b != BandFM {
+		return fmt.Errorf("invalid band %q", b)
+	}
+	cmd := "TMAN" + string(b)
+	return t.a.setConfirm(cmd, isBandLine, cmd)
+}
+
+// Band returns the tuner's current band.
+func (t *Tuner) Band() (Band, error) {
+	l, err := t.a.timeoutQuery("TMAN?", isBandLine)
+	if err != nil {
+		return "", err
+	}
+	return Band(l[len("TMAN"):]), nil
+}
+
+// MaxPreset is the highest tuner preset number.
+const MaxPreset = 56
+
+// A Preset is a stored tuner station.
+type Preset struct {
+	Number int
+	Name   string // as shown on the front panel; may be empty
+}
+
+// SelectPreset tunes to preset n, from 1 to MaxPreset.
+func (t *Tuner) SelectPreset(n int) error {
+	if n < 1 || n > MaxPreset {
+		return fmt.Errorf("preset %d out of range [1, %d]", n, MaxPreset)
+	}
+	cmd := fmt.Sprintf("TPAN%02d", n)
+	return t.a.setConfirm(cmd, prefix("TPAN"), cmd)
+}
+
+// CurrentPreset returns the selected preset number, or zero if the
+// tuner is not on a preset.
+func (t *Tuner) CurrentPreset() (int, error) {
+	l, err := t.a.timeoutQuery("TPAN?", prefix("TPAN"))
+	if err != nil {
+		return 0, err
+	}
+	if l == "TPANOFF" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(l[len("TPAN"):])
+	if err != nil {
+		return 0, fmt.Errorf("invalid preset line %q", l)
+	}
+	return n, nil
+}
+
+// presetListQuiet is how long ListPresets waits after the last
+// preset line before deciding the list is complete.
+const presetListQuiet = 500 * time.Millisecond
+
+// ListPresets returns the tuner's stored presets, as reported by
+// the amp's OPTPN lines such as "OPTPN01BBC R4".
+func (t *Tuner) ListPresets() ([]Preset, error) {
+	ctx, cancel := t.a.timeoutContext(context.Background())
+	defer cancel()
+	lines, err := t.a.queryLines(ctx, "OPTPN?", prefix("OPTPN"), nil, presetListQuiet)
+	if err != nil && len(lines) == 0 {
+		return nil, err
+	}
+	var ps []Preset
+	for _, l := range lines {
+		rest := l[len("OPTPN"):]
+		if len(rest) < 2 {
+			continue
+		}
+		n, err := strconv.Atoi(rest[:2])
+		if err != nil {
+			continue
+		}
+		ps = append(ps, Preset{Number: n, Name: strings.TrimSpace(rest[2:])})
+	}
+	return ps, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"time"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// An UpdateState is a stage of a firmware update, as reported in
+// UpdateStatus events.
+type UpdateState string
+
+const (
+	UpdateNone       UpdateState = "NONE"      // no newer firmware
+	UpdateAvailable  UpdateState = "AVAILABLE" // newer firmware can be installed
+	UpdateStarted    UpdateState = "START"     // the amp began updating
+	UpdateInstalling UpdateState = "PROGRESS"  // see UpdateStatus.Progress
+	UpdateFinished   UpdateState = "END"       // the amp is about to restart
+	UpdateFailed     UpdateState = "ERROR"
+
+	// UpdateRestarted is not reported by the amp. The Amp publishes
+	// it once it has reconnected and resynced after an update, when
+	// the amp is ready for commands again.
+	UpdateRestarted UpdateState = "RESTARTED"
+)
+
+// updateCheckTimeout bounds TriggerUpdateCheck if its context has no
+// deadline, as the amp asks the update server before answering.
+const updateCheckTimeout = 30 * time.Second
+
+// TriggerUpdateCheck has the amp check for newer firmware, returning
+// its answer: an UpdateStatus whose State is UpdateNone or
+// UpdateAvailable. Amps set to update automatically then install it,
+// reporting progress as UpdateStatus events, which end in
+// UpdateRestarted once the amp is back.
+func (a *Amp) TriggerUpdateCheck(ctx context.Context) (UpdateStatus, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, updateCheckTimeout)
+		defer cancel()
+	}
+	l, err := a.query(ctx, "UGCHK", isCheckResult)
+	if err != nil {
+		return UpdateStatus{}, timeoutErr(err)
+	}
+	return eventOf(proto.Parse(l)).(UpdateStatus), nil
+}
+
+// isCheckResult reports whether l answers an update check.
+func isCheckResult(l string) bool {
+	u, ok := proto.Parse(l).(proto.Update)
+	return ok && (u.State == string(UpdateNone) || u.State == string(UpdateAvailable))
+}
+
+// noteUpdate records whether an update is under way, so that the
+// next resync publishes UpdateRestarted.
+//
+// must be called with mu held
+func (a *Amp) noteUpdate(u proto.Update) {
+	switch UpdateState(u.State) {
+	case UpdateStarted, UpdateInstalling, UpdateFinished:
+		a.updating = true
+	case UpdateFailed:
+		a.updating = false
+	}
+}
+
+// updateUnderWay reports whether the amp reported a firmware update
+// under way, after which it restarts, ending the connection.
+func (a *Amp) updateUnderWay() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.updating
+}
+
+// updateStatus converts u.
+func updateStatus(u proto.Update) UpdateStatus {
+	st := UpdateStatus{State: UpdateState(u.State)}
+	switch st.State {
+	case UpdateInstalling:
+		st.Progress = u.Percent
+	case UpdateFinished:
+		st.Progress = 100
+	}
+	return st
+}
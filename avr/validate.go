@@ -0,0 +1,112 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"fmt"
+	"strings"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// maxCommandLen is the length of the longest command Validate
+// accepts. The longest the amp takes, a friendly name, is shorter.
+const maxCommandLen = 128
+
+// commandPrefixes are the prefixes of the commands the amp accepts.
+var commandPrefixes = []string{
+	"PW", "MV", "CV", "MU", "SI", "ZM", "Z2", "Z3", "MS", "SV", "SD",
+	"DC", "SR", "SLP", "STBY", "ECO", "DIM", "PS", "PV", "VS", "TF",
+	"TP", "TM", "HD", "DA", "NS", "SS", "SY", "SP", "BT", "TR", "MN",
+	"UG",
+}
+
+// WithStrictValidation makes SendCommand and Query refuse commands
+// that Validate rejects, rather than send the amp bytes it would
+// ignore.
+func WithStrictValidation() Option {
+	return func(a *Amp) { a.strict = true }
+}
+
+// Validate reports whether the amp would accept raw command cmd,
+// returning an error wrapping ErrInvalidCommand that describes the
+// problem if not. It rejects empty commands, control characters
+// other than a trailing carriage return, unknown prefixes, volumes
+// beyond the amp's range or its detected limit and zones other than
+// 2 and 3. Commands for zones the amp is known to lack are rejected
+// with ErrUnsupported. Validate only checks what it understands:
+// other commands with known prefixes pass.
+func (a *Amp) Validate(cmd string) error {
+	cmd = strings.TrimSuffix(cmd, "\r")
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w %q: %s", ErrInvalidCommand, cmd, fmt.Sprintf(format, args...))
+	}
+	switch {
+	case cmd == "":
+		return fmt.Errorf("%w: empty command", ErrInvalidCommand)
+	case len(cmd) > maxCommandLen:
+		return invalid("longer than %d bytes", maxCommandLen)
+	}
+	for i := 0; i < len(cmd); i++ {
+		if c := cmd[i]; c < ' ' || c > '~' {
+			return invalid("byte %#02x at offset %d", c, i)
+		}
+	}
+	if cmd[0] == 'Z' && len(cmd) > 1 && cmd[1] >= '0' && cmd[1] <= '9' {
+		n := int(cmd[1] - '0')
+		if n != 2 && n != 3 {
+			return invalid("no zone %d", n)
+		}
+		if err := a.checkZone(n); err != nil {
+			return err
+		}
+	}
+	known := false
+	for _, p := range commandPrefixes {
+		if strings.HasPrefix(cmd, p) {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return invalid("unknown command")
+	}
+	return a.validateVolume(cmd)
+}
+
+// validateVolume checks the level of master and zone volume commands.
+func (a *Amp) validateVolume(cmd string) error {
+	var v string
+	switch {
+	case strings.HasPrefix(cmd, "MV"):
+		v = cmd[len("MV"):]
+	case strings.HasPrefix(cmd, "Z2"), strings.HasPrefix(cmd, "Z3"):
+		v = cmd[len("Z2"):]
+	default:
+		return nil
+	}
+	if v == "" || v[0] < '0' || v[0] > '9' {
+		return nil // UP, DOWN, a query or another setting
+	}
+	db, err := proto.ParseVolume(v)
+	if err != nil {
+		return fmt.Errorf("%w %q: bad volume level", ErrInvalidCommand, cmd)
+	}
+	a.mu.Lock()
+	limit := a.caps.MaxVolume
+	a.mu.Unlock()
+	if db < MinVolume || db > limit {
+		return fmt.Errorf("%w %q: volume %vdB out of range [%v, %v]", ErrInvalidCommand, cmd, db, MinVolume, limit)
+	}
+	return nil
+}
+
+// checkStrict validates cmd if the Amp was created
+// WithStrictValidation.
+func (a *Amp) checkStrict(cmd string) error {
+	if !a.strict {
+		return nil
+	}
+	return a.Validate(cmd)
+}
@@ -0,0 +1,256 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MonitorOut is an HDMI monitor output selection.
+type MonitorOut string
+
+const (
+	MonitorAuto MonitorOut = "AUTO"
+	Monitor1    MonitorOut = "1"
+	Monitor2    MonitorOut = "2"
+)
+
+// VideoProcessing is a video processing mode.
+type VideoProcessing string
+
+const (
+	VideoProcessingAuto  VideoProcessing = "AUTO"
+	VideoProcessingGame  VideoProcessing = "GAME"
+	VideoProcessingMovie VideoProcessing = "MOVI"
+)
+
+// Resolution is a scaler output resolution.
+type Resolution string
+
+const (
+	Resolution480p    Resolution = "48P"
+	Resolution1080i   Resolution = "10I"
+	Resolution720p    Resolution = "72P"
+	Resolution1080p   Resolution = "10P"
+	Resolution1080p24 Resolution = "10P24"
+	Resolution4K      Resolution = "4K"
+	ResolutionAuto    Resolution = "AUTO"
+)
+
+// AspectRatio is how the amp fits 4:3 video to its output.
+type AspectRatio string
+
+const (
+	AspectNormal AspectRatio = "NRM" // pillarboxed at 4:3
+	AspectFull   AspectRatio = "FUL" // stretched to 16:9
+)
+
+// SetMonitorOut selects the HDMI monitor output.
+func (a *Amp) SetMonitorOut(m MonitorOut) error {
+	switch m {
+	case MonitorAuto, Monitor1, Monitor2:
+	default:
+		return fmt.Errorf("invalid monitor output %q", m)
+	}
+	return a.setParam("VSMONI", string(m))
+}
+
+// GetMonitorOut returns the selected HDMI monitor output.
+func (a *Amp) GetMonitorOut() (MonitorOut, error) {
+	v, err := a.param("VSMONI")
+	return MonitorOut(v), err
+}
+
+// SetVideoProcessing sets the video processing mode.
+func (a *Amp) SetVideoProcessing(m VideoProcessing) error {
+	if m == "" {
+		return fmt.Errorf("empty video processing mode")
+	}
+	return a.setParam("VSVPM", string(m))
+}
+
+// GetVideoProcessing returns the video processing mode.
+func (a *Amp) GetVideoProcessing() (VideoProcessing, error) {
+	v, err := a.param("VSVPM")
+	return VideoProcessing(v), err
+}
+
+// SetResolution sets the scaler's output resolution.
+func (a *Amp) SetResolution(r Resolution) error {
+	if r == "" {
+		return fmt.Errorf("empty resolution")
+	}
+	cmd := "VSSC" + string(r)
+	return a.setConfirm(cmd, isScalerLine, cmd)
+}
+
+// GetResolution returns the scaler's output resolution.
+func (a *Amp) GetResolution() (Resolution, error) {
+	l, err := a.timeoutQuery("VSSC ?", isScalerLine)
+	if err != nil {
+		return "", err
+	}
+	return Resolution(l[len("VSSC"):]), nil
+}
+
+// SetAspectRatio sets the aspect ratio for the current input.
+func (a *Amp) SetAspectRatio(r AspectRatio) error {
+	switch r {
+	case AspectNormal, AspectFull:
+	default:
+		return fmt.Errorf("invalid aspect ratio %q", r)
+	}
+	return a.setParam("VSASP", string(r))
+}
+
+// GetAspectRatio returns the aspect ratio for the current input.
+func (a *Amp) GetAspectRatio() (AspectRatio, error) {
+	v, err := a.param("VSASP")
+	return AspectRatio(v), err
+}
+
+// isScalerLine reports whether l is a scaler resolution report, as
+// opposed to the HDMI scaler's "VSSCH" line.
+func isScalerLine(l string) bool {
+	return strings.HasPrefix(l, "VSSC") && !strings.HasPrefix(l, "VSSCH")
+}
+
+// PictureMode is a video processor picture mode.
+type PictureMode string
+
+const (
+	PictureOff      PictureMode = "OFF"
+	PictureStandard PictureMode = "STD"
+	PictureMovie    PictureMode = "MOV"
+	PictureVivid    PictureMode = "VVD"
+	PictureStream   PictureMode = "STM"
+	PictureCustom   PictureMode = "CTM"
+	PictureISFDay   PictureMode = "DAY"
+	PictureISFNight PictureMode = "NGT"
+)
+
+// NoiseReduction is a video processor noise reduction level.
+type NoiseReduction string
+
+const (
+	NoiseReductionOff  NoiseReduction = "OFF"
+	NoiseReductionLow  NoiseReduction = "LOW"
+	NoiseReductionMid  NoiseReduction = "MID"
+	NoiseReductionHigh NoiseReduction = "HI"
+)
+
+// Picture adjustments such as contrast range from MinPictureLevel to
+// MaxPictureLevel, with 0 the default. They apply to PictureCustom.
+const (
+	MinPictureLevel = -50
+	MaxPictureLevel = 50
+)
+
+// pictureZeroLevel is the parameter value of picture level 0.
+const pictureZeroLevel = 50
+
+// SetPictureMode sets the video processor's picture mode. It and the
+// other picture methods return ErrUnsupported if the amp is known to
+// have no video processor.
+func (a *Amp) SetPictureMode(m PictureMode) error {
+	if m == "" {
+		return fmt.Errorf("empty picture mode")
+	}
+	if err := a.checkVideoProcessor(); err != nil {
+		return err
+	}
+	return a.setParam("PVPICT ", string(m))
+}
+
+// GetPictureMode returns the picture mode.
+func (a *Amp) GetPictureMode() (PictureMode, error) {
+	if err := a.checkVideoProcessor(); err != nil {
+		return "", err
+	}
+	v, err := a.param("PVPICT ")
+	return PictureMode(v), err
+}
+
+// SetContrast sets the picture contrast.
+func (a *Amp) SetContrast(level int) error {
+	return a.setPictureLevel("PVCN ", "contrast", level)
+}
+
+// Contrast returns the picture contrast.
+func (a *Amp) Contrast() (int, error) {
+	return a.pictureLevel("PVCN ", "contrast")
+}
+
+// SetBrightness sets the picture brightness.
+func (a *Amp) SetBrightness(level int) error {
+	return a.setPictureLevel("PVBR ", "brightness", level)
+}
+
+// Brightness returns the picture brightness.
+func (a *Amp) Brightness() (int, error) {
+	return a.pictureLevel("PVBR ", "brightness")
+}
+
+// SetNoiseReduction sets the digital noise reduction level.
+func (a *Amp) SetNoiseReduction(n NoiseReduction) error {
+	switch n {
+	case NoiseReductionOff, NoiseReductionLow, NoiseReductionMid, NoiseReductionHigh:
+	default:
+		return fmt.Errorf("invalid noise reduction level %q", n)
+	}
+	if err := a.checkVideoProcessor(); err != nil {
+		return err
+	}
+	return a.setParam("PVDNR ", string(n))
+}
+
+// GetNoiseReduction returns the digital noise reduction level.
+func (a *Amp) GetNoiseReduction() (NoiseReduction, error) {
+	if err := a.checkVideoProcessor(); err != nil {
+		return "", err
+	}
+	v, err := a.param("PVDNR ")
+	return NoiseReduction(v), err
+}
+
+// setPictureLevel sets picture parameter p, naming the adjustment
+// in errors.
+func (a *Amp) setPictureLevel(p, name string, level int) error {
+	if level < MinPictureLevel || level > MaxPictureLevel {
+		return fmt.Errorf("%s %d out of range [%d, %d]", name, level, MinPictureLevel, MaxPictureLevel)
+	}
+	if err := a.checkVideoProcessor(); err != nil {
+		return err
+	}
+	return a.setParam(p, fmt.Sprintf("%03d", level+pictureZeroLevel))
+}
+
+// pictureLevel returns the value of picture parameter p.
+func (a *Amp) pictureLevel(p, name string) (int, error) {
+	if err := a.checkVideoProcessor(); err != nil {
+		return 0, err
+	}
+	v, err := a.param(p)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, v)
+	}
+	return n - pictureZeroLevel, nil
+}
+
+// checkVideoProcessor returns ErrUnsupported if the amp is known to
+// have no video processor.
+func (a *Amp) checkVideoProcessor() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.caps.Known && !a.caps.VideoProcessor {
+		return fmt.Errorf("%w: video processor", ErrUnsupported)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// A VideoProfile is a set of video settings to use for an input.
+// Empty fields are left as they are.
+type VideoProfile struct {
+	Aspect     AspectRatio
+	Resolution Resolution
+}
+
+// ApplyVideoProfile applies p's settings to the current input.
+func (a *Amp) ApplyVideoProfile(p VideoProfile) error {
+	if p.Aspect != "" {
+		if err := a.SetAspectRatio(p.Aspect); err != nil {
+			return err
+		}
+	}
+	if p.Resolution != "" {
+		if err := a.SetResolution(p.Resolution); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FollowVideoProfiles applies profiles[input] whenever the main zone
+// switches to input, until ctx is done, and then returns ctx.Err().
+// It applies the current input's profile at once, and again after a
+// reconnect, as the amp may have restarted with other settings.
+// Inputs without a profile are left alone. Failures to apply a
+// profile are logged as errors.
+//
+// FollowVideoProfiles returns with ErrClosed if the Amp is closed.
+func (a *Amp) FollowVideoProfiles(ctx context.Context, profiles map[InputSource]VideoProfile) error {
+	events, cancel := a.Subscribe()
+	defer cancel()
+
+	input, err := a.CurrentInput()
+	if err != nil {
+		return err
+	}
+	apply := func(in InputSource) {
+		p, ok := profiles[in]
+		if !ok {
+			return
+		}
+		if err := a.ApplyVideoProfile(p); err != nil {
+			a.log.Error("avr: applying video profile", "input", in, "err", err)
+		}
+	}
+	apply(input)
+	for {
+		changed := false
+		err := a.nextEvents(ctx, events, func(ev Event) {
+			switch ev := ev.(type) {
+			case InputChanged:
+				input, changed = ev.Input, true
+			case Resynced:
+				input, changed = ev.Status.Input, true
+			}
+		})
+		switch {
+		case errors.Is(err, ErrClosed):
+			return fmt.Errorf("following video profiles: %w", err)
+		case err != nil:
+			return err
+		}
+		if changed {
+			apply(input)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// Volume limits, in dB. MinVolume is the amp's "---" setting.
+const (
+	MinVolume = proto.MinVolume
+	MaxVolume = 18.0
+)
+
+// The amp encodes volume as a level from 00 to 98 where 80 is 0dB.
+// A third digit of 5 adds half a step, so "795" is -0.5dB and "805"
+// is +0.5dB. Level 99 is an alternate spelling of "---".
+const zeroLevel = 80
+
+// SetVolume sets the master volume to db, rounded to the nearest
+// half step, and waits for the amp to confirm.
+// If db is above the WithVolumeLimit limit, the volume is set to the
+// limit and SetVolume returns ErrVolumeLimited.
+func (a *Amp) SetVolume(db float64) error {
+	return a.setVolumeContext(context.Background(), db)
+}
+
+func (a *Amp) setVolumeContext(ctx context.Context, db float64) error {
+	enc, err := encodeVolume(db)
+	if err != nil {
+		return err
+	}
+	limited := db > a.volLimit
+	if limited {
+		enc = encodeLevel(a.volLimit, zeroLevel)
+	}
+	cmd := "MV" + enc
+	if err := a.setConfirmContext(ctx, cmd, isVolumeLine, cmd); err != nil {
+		return err
+	}
+	if limited {
+		return ErrVolumeLimited
+	}
+	return nil
+}
+
+// VolumeUp raises the master volume by one step. At the
+// WithVolumeLimit limit, it returns ErrVolumeLimited instead.
+func (a *Amp) VolumeUp() error {
+	if a.volLimit < MaxVolume {
+		db, err := a.GetVolume()
+		if err != nil {
+			return err
+		}
+		if db >= a.volLimit {
+			return ErrVolumeLimited
+		}
+	}
+	l, err := a.timeoutQuery("MVUP", isVolumeLine)
+	if err != nil {
+		return err
+	}
+	if proto.Parse(l).(proto.Volume).DB > a.volLimit {
+		// The step overshot the limit.
+		return a.SetVolume(a.volLimit)
+	}
+	return nil
+}
+
+// VolumeDown lowers the master volume by one step.
+func (a *Amp) VolumeDown() error {
+	_, err := a.timeoutQuery("MVDOWN", isVolumeLine)
+	return err
+}
+
+// GetVolume returns the master volume in dB.
+func (a *Amp) GetVolume() (db float64, err error) {
+	l, err := a.timeoutQuery("MV?", isVolumeLine)
+	if err != nil {
+		return 0, err
+	}
+	return proto.Parse(l).(proto.Volume).DB, nil
+}
+
+// WithVolumeLimit caps the volume the Amp sets at db, for the main
+// and other zones. Calls that would exceed it set the volume to db
+// instead and return ErrVolumeLimited, and SendCommand refuses
+// commands setting a higher volume.
+func WithVolumeLimit(db float64) Option {
+	return func(a *Amp) { a.volLimit = db }
+}
+
+// checkRawVolume returns ErrVolumeLimited if raw command cmd sets a
+// zone's volume above the limit.
+func (a *Amp) checkRawVolume(cmd string) error {
+	if a.volLimit >= MaxVolume {
+		return nil
+	}
+	var v proto.Volume
+	switch m := proto.Parse(strings.TrimSuffix(cmd, "\r")).(type) {
+	case proto.Volume:
+		v = m
+	case proto.Zone:
+		var ok bool
+		if v, ok = m.Msg.(proto.Volume); !ok {
+			return nil
+		}
+	default:
+		return nil
+	}
+	if v.DB > a.volLimit {
+		return fmt.Errorf("%w: %s", ErrVolumeLimited, cmd)
+	}
+	return nil
+}
+
+// isVolumeLine reports whether l is a master volume report such as
+// "MV45", as opposed to "MVMAX 98".
+func isVolumeLine(l string) bool {
+	return is[proto.Volume](l)
+}
+
+// encodeVolume returns the amp's level encoding of db.
+func encodeVolume(db float64) (string, error) {
+	if math.IsNaN(db) || db < MinVolume || db > MaxVolume {
+		return "", fmt.Errorf("volume %vdB out of range [%v, %v]", db, MinVolume, MaxVolume)
+	}
+	return encodeLevel(db, zeroLevel), nil
+}
+
+// encodeLevel returns the two or three digit encoding of db, in
+// half steps relative to the level zero.
+func encodeLevel(db float64, zero int) string {
+	halves := int(math.Round((db + float64(zero)) * 2))
+	if halves%2 == 0 {
+		return fmt.Sprintf("%02d", halves/2)
+	}
+	return fmt.Sprintf("%02d5", halves/2)
+}
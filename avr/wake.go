@@ -0,0 +1,59 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"bytes"
+	"errors"
+	"net"
+)
+
+// wakeAddr is where Wake sends its magic packet.
+const wakeAddr = "255.255.255.255:9"
+
+// WithMAC sets the amp's Ethernet address, used by Wake.
+func WithMAC(mac net.HardwareAddr) Option {
+	return func(a *Amp) { a.mac = mac }
+}
+
+// SetMAC sets the amp's Ethernet address, used by Wake.
+func (a *Amp) SetMAC(mac net.HardwareAddr) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.mac = mac
+}
+
+// Wake broadcasts a Wake-on-LAN magic packet to the amp's Ethernet
+// address, set with WithMAC or SetMAC, and then starts connecting.
+// It is needed when the amp's network standby is off, as its
+// control port is closed while it sleeps. Use Ping to wait for the
+// connection. Wake returns ErrReadOnly if the Amp was created
+// WithReadOnly.
+func (a *Amp) Wake() error {
+	if a.readOnly {
+		return ErrReadOnly
+	}
+	a.mu.Lock()
+	mac := a.mac
+	a.mu.Unlock()
+	if len(mac) != 6 {
+		return errors.New("no Ethernet address for amp")
+	}
+	c, err := net.Dial("udp", wakeAddr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if _, err := c.Write(magicPacket(mac)); err != nil {
+		return err
+	}
+	a.startConnect()
+	return nil
+}
+
+// magicPacket returns the Wake-on-LAN packet for mac: six 0xff
+// bytes followed by mac sixteen times.
+func magicPacket(mac net.HardwareAddr) []byte {
+	return append(bytes.Repeat([]byte{0xff}, 6), bytes.Repeat(mac, 16)...)
+}
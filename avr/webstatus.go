@@ -0,0 +1,155 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// WebStatus is the part of the amp's state that is only available
+// from its web interface.
+type WebStatus struct {
+	FriendlyName string `json:"friendly_name"` // the amp's network name, such as "Living Room"
+
+	// InputNames maps each source in use to the name the user has
+	// given it on the amp. Sources set to be deleted in the amp's
+	// setup menu are left out.
+	InputNames map[InputSource]string `json:"input_names"`
+
+	NetSource InputSource `json:"net_source"` // the selected network audio source
+	NetLines  []string    `json:"net_lines"`  // the network audio display, top to bottom
+}
+
+// Web interface status pages, relative to the base URL.
+const (
+	mainZoneXMLPath = "/goform/formMainZone_MainZoneXmlStatus.xml"
+	netAudioXMLPath = "/goform/formNetAudio_StatusXml.xml"
+)
+
+// WithWebStatus makes Status also fetch the amp's web interface
+// status pages from baseURL, such as "http://192.168.1.20", and fill
+// in Status.Web. An empty baseURL uses port 80 on the host given to
+// New. A nil client uses http.DefaultClient.
+func WithWebStatus(baseURL string, client *http.Client) Option {
+	return func(a *Amp) {
+		if baseURL == "" {
+			baseURL = "http://" + net.JoinHostPort(a.host(), "80")
+		}
+		if client == nil {
+			client = http.DefaultClient
+		}
+		a.webURL = strings.TrimSuffix(baseURL, "/")
+		a.webClient = client
+	}
+}
+
+// WebStatus fetches the amp's web interface status pages. It fails
+// unless the Amp was created WithWebStatus.
+func (a *Amp) WebStatus(ctx context.Context) (*WebStatus, error) {
+	if a.webClient == nil {
+		return nil, errors.New("web status not enabled")
+	}
+	var mz mainZoneXML
+	if err := a.getXML(ctx, mainZoneXMLPath, &mz); err != nil {
+		return nil, err
+	}
+	var na netAudioXML
+	if err := a.getXML(ctx, netAudioXMLPath, &na); err != nil {
+		return nil, err
+	}
+	ws := &WebStatus{
+		FriendlyName: mz.FriendlyName.first(),
+		InputNames:   make(map[InputSource]string),
+		NetSource:    InputSource(na.NetFuncSelect.first()),
+	}
+	for i, src := range mz.InputFuncList.Values {
+		src = strings.TrimSpace(src)
+		if src == "" || mz.SourceDelete.at(i) == "DEL" {
+			continue
+		}
+		name := src
+		if i < len(mz.RenameSource.Values) {
+			if n := mz.RenameSource.Values[i].text(); n != "" {
+				name = n
+			}
+		}
+		ws.InputNames[InputSource(src)] = name
+	}
+	for _, l := range na.SzLine.Values {
+		ws.NetLines = append(ws.NetLines, strings.TrimSpace(l))
+	}
+	return ws, nil
+}
+
+func (a *Amp) getXML(ctx context.Context, path string, v any) error {
+	ctx, cancel := a.timeoutContext(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", a.webURL+path, nil)
+	if err != nil {
+		return err
+	}
+	res, err := a.webClient.Do(req)
+	if err != nil {
+		return timeoutErr(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s: %s", path, res.Status)
+	}
+	if err := xml.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return nil
+}
+
+// xmlValues is an element of the status pages. Each holds its
+// values in one or more <value> children.
+type xmlValues struct {
+	Values []string `xml:"value"`
+}
+
+func (x xmlValues) first() string {
+	return x.at(0)
+}
+
+func (x xmlValues) at(i int) string {
+	if i >= len(x.Values) {
+		return ""
+	}
+	return strings.TrimSpace(x.Values[i])
+}
+
+// xmlValue is a <value> element that, depending on the firmware,
+// holds either text or a nested <value>.
+type xmlValue struct {
+	Text  string   `xml:",chardata"`
+	Inner []string `xml:"value"`
+}
+
+func (x xmlValue) text() string {
+	if len(x.Inner) > 0 {
+		return strings.TrimSpace(x.Inner[0])
+	}
+	return strings.TrimSpace(x.Text)
+}
+
+type mainZoneXML struct {
+	FriendlyName  xmlValues
+	InputFuncList xmlValues
+	RenameSource  struct {
+		Values []xmlValue `xml:"value"`
+	}
+	SourceDelete xmlValues
+}
+
+type netAudioXML struct {
+	NetFuncSelect xmlValues
+	SzLine        xmlValues `xml:"szLine"`
+}
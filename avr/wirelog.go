@@ -0,0 +1,59 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WithWireLog makes the Amp write every line it sends to or receives
+// from the amp to w, one per line of w, as
+//
+//	2011-05-01T20:04:05.123456Z conn=1 tx "MV45"
+//	2011-05-01T20:04:05.131075Z conn=1 rx "MV45"
+//
+// The fields are the time in UTC, the connection number, which
+// increases with each connection the Amp makes, the direction, tx
+// or rx, and the line without its carriage return, quoted as a Go
+// string. Writes to w that fail are ignored.
+func WithWireLog(w io.Writer) Option {
+	return func(a *Amp) {
+		if w == nil {
+			a.wire = nil
+			return
+		}
+		a.wire = &wireLog{w: w}
+	}
+}
+
+// wireTimeFormat is the time format of wire log lines.
+const wireTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// A wireLog writes WithWireLog lines. A nil *wireLog discards them.
+type wireLog struct {
+	mu  sync.Mutex
+	w   io.Writer
+	buf []byte // guarded by mu
+}
+
+func (l *wireLog) log(conn uint64, dir, line string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := time.Now().UTC().AppendFormat(l.buf[:0], wireTimeFormat)
+	b = append(b, " conn="...)
+	b = strconv.AppendUint(b, conn, 10)
+	b = append(b, ' ')
+	b = append(b, dir...)
+	b = append(b, ' ')
+	b = strconv.AppendQuote(b, line)
+	b = append(b, '\n')
+	l.w.Write(b)
+	l.buf = b
+}
@@ -0,0 +1,337 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+// Package yamaha controls Yamaha receivers over YNCA, the line
+// protocol they serve on TCP port 50000. A Receiver implements
+// avr.Receiver, so code written against that interface works with
+// Yamaha amps as well as Denon, Marantz and Onkyo ones.
+//
+// YNCA lines look like "@MAIN:VOL=-30.5": a subunit, a function and
+// a value. Queries put "?" in place of the value.
+package yamaha
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.google.com/p/go-avr/avr"
+	"code.google.com/p/go-avr/avr/internal/link"
+	"code.google.com/p/go-avr/avr/internal/pace"
+)
+
+// DefaultPort is the TCP port receivers serve YNCA on.
+const DefaultPort = 50000
+
+// DefaultTimeout is how long commands wait for the receiver to
+// answer.
+const DefaultTimeout = 5 * time.Second
+
+// Errors the receiver reports.
+var (
+	// ErrRestricted means the receiver answered "@RESTRICTED": the
+	// command isn't allowed in its current state, as in standby.
+	ErrRestricted = errors.New("restricted")
+
+	// ErrUndefined means the receiver answered "@UNDEFINED": its
+	// model has no such command or value.
+	ErrUndefined = errors.New("undefined")
+)
+
+// A Receiver is a Yamaha receiver. It connects on first use and
+// reconnects after the connection breaks. It is safe for concurrent
+// use.
+type Receiver struct {
+	c *link.Client
+}
+
+// The package registers brand "yamaha" for avr.LoadConfig.
+func init() {
+	open := func(addr string) avr.Receiver { return New(addr) }
+	avr.RegisterBrand("yamaha", open)
+}
+
+// New returns a Receiver for the receiver at addr, which defaults to
+// DefaultPort if it has no port.
+func New(addr string) *Receiver {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, strconv.Itoa(DefaultPort))
+	}
+	return &Receiver{c: link.New(addr, DefaultTimeout, protocol)}
+}
+
+// protocol is YNCA for link.Client.
+var protocol = link.Protocol{
+	Write: func(w io.Writer, cmd string) error {
+		_, err := io.WriteString(w, cmd+"\r\n")
+		return err
+	},
+	Read:  readLine,
+	Split: split,
+	Error: replyError,
+	Event: event,
+	Gaps: pace.Gaps{
+		Min: avr.DefaultCommandGap,
+		// The receiver ignores commands while powering on.
+		Prefix: map[string]time.Duration{"@MAIN:PWR=On": time.Second},
+	},
+}
+
+// maxLineLen bounds the lines readLine accepts.
+const maxLineLen = 1024
+
+// readLine reads a YNCA line and returns it without its line ending.
+func readLine(r *bufio.Reader) (string, error) {
+	l, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(l) > maxLineLen {
+		return "", fmt.Errorf("YNCA line too long (%d bytes)", len(l))
+	}
+	return strings.TrimRight(l, "\r\n"), nil
+}
+
+// split splits a line such as "@MAIN:VOL=-30.5" into its parameter,
+// "MAIN:VOL", and value, "-30.5".
+func split(l string) (p, v string, ok bool) {
+	if !strings.HasPrefix(l, "@") {
+		return "", "", false
+	}
+	return strings.Cut(l[1:], "=")
+}
+
+// replyError returns the error for an error reply, or nil.
+func replyError(l string) error {
+	switch l {
+	case "@RESTRICTED":
+		return ErrRestricted
+	case "@UNDEFINED":
+		return ErrUndefined
+	}
+	return nil
+}
+
+// Close closes the connection and every subscription channel.
+func (r *Receiver) Close() error { return r.c.Close() }
+
+// Volume range. The receiver steps volume by half a decibel; its
+// lowest setting, -80.5dB, is muting.
+const (
+	minVolume = -80.5
+	maxVolume = 16.5
+)
+
+// PowerOn turns the receiver's main zone on.
+func (r *Receiver) PowerOn() error { return r.set("MAIN:PWR", "On") }
+
+// PowerOff puts the receiver's main zone in standby.
+func (r *Receiver) PowerOff() error { return r.set("MAIN:PWR", "Standby") }
+
+// PowerState reports whether the main zone is on.
+func (r *Receiver) PowerState() (on bool, err error) {
+	v, err := r.query("MAIN:PWR")
+	return v == "On", err
+}
+
+// SetVolume sets the master volume to db, rounded to the nearest
+// half step.
+func (r *Receiver) SetVolume(db float64) error {
+	db = math.Round(db*2) / 2
+	if db < avr.MinVolume || db > maxVolume {
+		return fmt.Errorf("volume %vdB out of range [%v, %v]", db, avr.MinVolume, maxVolume)
+	}
+	return r.set("MAIN:VOL", strconv.FormatFloat(db, 'f', 1, 64))
+}
+
+// GetVolume returns the master volume in dB.
+func (r *Receiver) GetVolume() (db float64, err error) {
+	v, err := r.query("MAIN:VOL")
+	if err != nil {
+		return 0, err
+	}
+	return parseVolume(v)
+}
+
+// VolumeUp raises the master volume by half a decibel.
+func (r *Receiver) VolumeUp() error { return r.set("MAIN:VOL", "Up") }
+
+// VolumeDown lowers the master volume by half a decibel.
+func (r *Receiver) VolumeDown() error { return r.set("MAIN:VOL", "Down") }
+
+// Mute mutes or unmutes the main zone.
+func (r *Receiver) Mute(on bool) error {
+	if on {
+		return r.set("MAIN:MUTE", "On")
+	}
+	return r.set("MAIN:MUTE", "Off")
+}
+
+// IsMuted reports whether the main zone is muted. Attenuation, as
+// in "Att -20 dB", counts as muted.
+func (r *Receiver) IsMuted() (bool, error) {
+	v, err := r.query("MAIN:MUTE")
+	return v != "Off", err
+}
+
+// SelectInput switches the main zone to src. Sources with a YNCA
+// equivalent, such as avr.SourceTuner, are translated; others are
+// sent as they are, so Yamaha input names such as "HDMI1" work too.
+func (r *Receiver) SelectInput(src avr.InputSource) error {
+	if src == "" {
+		return errors.New("empty input source")
+	}
+	name, ok := inputNames[src]
+	if !ok {
+		name = string(src)
+	}
+	return r.set("MAIN:INP", name)
+}
+
+// CurrentInput returns the main zone's input, translated to an
+// avr.InputSource where there is an equivalent or else the YNCA
+// input name, such as "HDMI1".
+func (r *Receiver) CurrentInput() (avr.InputSource, error) {
+	v, err := r.query("MAIN:INP")
+	if err != nil {
+		return "", err
+	}
+	return inputSource(v), nil
+}
+
+// Subscribe implements avr.Receiver. Typed events are sent when the
+// main zone's power, volume, muting or input changes; zone 2 and 3
+// changes are sent as avr.ZoneEvent, and other lines as
+// avr.RawLine. Subscribe connects to the receiver if needed.
+func (r *Receiver) Subscribe() (events <-chan avr.Event, cancel func()) {
+	return r.c.Subscribe()
+}
+
+// SendRaw implements avr.Receiver. cmd is a YNCA line such as
+// "@MAIN:SOUNDPRG=Straight"; SendRaw adds the line ending.
+func (r *Receiver) SendRaw(ctx context.Context, cmd string) error {
+	return r.c.Send(ctx, cmd)
+}
+
+// QueryRaw implements avr.Receiver. cmd is a YNCA line such as
+// "@SYS:MODELNAME=?", and the reply is the first line for the same
+// subunit and function, such as "@SYS:MODELNAME=RX-V685". Error
+// replies are returned as ErrRestricted or ErrUndefined.
+func (r *Receiver) QueryRaw(ctx context.Context, cmd string) (string, error) {
+	p, _, ok := split(cmd)
+	if !ok {
+		return "", fmt.Errorf("bad YNCA line %q", cmd)
+	}
+	return r.c.Query(ctx, cmd, p)
+}
+
+// set sets parameter p to v and waits for the receiver to report
+// p's resulting value. The receiver reports nothing when a parameter
+// is set to its current value, so set queries p too.
+func (r *Receiver) set(p, v string) error {
+	_, err := r.roundTrip("@"+p+"="+v+"\r\n@"+p+"=?", p)
+	return err
+}
+
+// query returns the value of parameter p, such as "MAIN:PWR".
+func (r *Receiver) query(p string) (string, error) {
+	return r.roundTrip("@"+p+"=?", p)
+}
+
+func (r *Receiver) roundTrip(cmd, p string) (string, error) {
+	v, err := r.c.RoundTrip(cmd, p)
+	if errors.Is(err, ErrRestricted) || errors.Is(err, ErrUndefined) {
+		return "", fmt.Errorf("%s: %w", strings.SplitN(cmd, "\r\n", 2)[0], err)
+	}
+	return v, err
+}
+
+// zones maps YNCA subunits to zone numbers.
+var zones = map[string]int{"MAIN": 1, "ZONE2": 2, "ZONE3": 3}
+
+// event returns the event for parameter p having value v, or nil.
+func event(p, v string) avr.Event {
+	unit, fn, _ := strings.Cut(p, ":")
+	zone, ok := zones[unit]
+	if !ok {
+		return nil
+	}
+	var ev avr.Event
+	switch fn {
+	case "PWR":
+		ev = avr.PowerChanged{On: v == "On"}
+	case "MUTE":
+		ev = avr.MuteChanged{Muted: v != "Off"}
+	case "VOL":
+		db, err := parseVolume(v)
+		if err != nil {
+			return nil
+		}
+		ev = avr.VolumeChanged{Volume: db}
+	case "INP":
+		ev = avr.InputChanged{Input: inputSource(v)}
+	default:
+		return nil
+	}
+	if zone > 1 {
+		return avr.ZoneEvent{Zone: zone, Event: ev}
+	}
+	return ev
+}
+
+// parseVolume parses a VOL value such as "-30.5" into dB. The
+// receiver's muting level is avr.MinVolume.
+func parseVolume(v string) (float64, error) {
+	db, err := strconv.ParseFloat(v, 64)
+	if err != nil || db < minVolume || db > maxVolume {
+		return 0, fmt.Errorf("invalid volume %q", v)
+	}
+	return max(db, avr.MinVolume), nil
+}
+
+// inputNames maps input sources to YNCA input names.
+var inputNames = map[avr.InputSource]string{
+	avr.SourcePhono:     "PHONO",
+	avr.SourceTuner:     "TUNER",
+	avr.SourceVAux:      "V-AUX",
+	avr.SourceUSB:       "USB",
+	avr.SourceNet:       "NET RADIO",
+	avr.SourceIRadio:    "NET RADIO",
+	avr.SourceServer:    "SERVER",
+	avr.SourceBluetooth: "Bluetooth",
+	avr.SourcePandora:   "Pandora",
+	avr.SourceRhapsody:  "Rhapsody",
+	avr.SourceNapster:   "Napster",
+}
+
+// inputSources maps YNCA input names to the input sources reported
+// for them.
+var inputSources = map[string]avr.InputSource{
+	"PHONO":     avr.SourcePhono,
+	"TUNER":     avr.SourceTuner,
+	"V-AUX":     avr.SourceVAux,
+	"USB":       avr.SourceUSB,
+	"NET RADIO": avr.SourceIRadio,
+	"SERVER":    avr.SourceServer,
+	"Bluetooth": avr.SourceBluetooth,
+	"Pandora":   avr.SourcePandora,
+	"Rhapsody":  avr.SourceRhapsody,
+	"Napster":   avr.SourceNapster,
+}
+
+// inputSource returns the input source for YNCA input name v.
+func inputSource(v string) avr.InputSource {
+	if src, ok := inputSources[v]; ok {
+		return src
+	}
+	return avr.InputSource(v)
+}
+
+var _ avr.Receiver = (*Receiver)(nil)
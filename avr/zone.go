@@ -0,0 +1,122 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"errors"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// A Zone is one of the amp's output zones. The main zone is
+// controlled by the MV, SI, MU and ZM commands; the other zones by
+// commands prefixed with Z2 or Z3.
+type Zone struct {
+	a *Amp
+	n int // 1 for the main zone
+}
+
+// MainZone returns the amp's main zone.
+func (a *Amp) MainZone() *Zone { return &Zone{a: a, n: 1} }
+
+// Zone2 returns the amp's second zone.
+func (a *Amp) Zone2() *Zone { return &Zone{a: a, n: 2} }
+
+// Zone3 returns the amp's third zone.
+func (a *Amp) Zone3() *Zone { return &Zone{a: a, n: 3} }
+
+// Number returns the zone's number: 1 for the main zone, or 2 or 3.
+func (z *Zone) Number() int { return z.n }
+
+// prefix returns the zone's command prefix, such as "Z2".
+func (z *Zone) prefix() string {
+	return zonePrefix(z.n)
+}
+
+// PowerOn turns the zone on and waits for the amp to confirm.
+func (z *Zone) PowerOn() error {
+	return z.setPower(true)
+}
+
+// PowerOff turns the zone off and waits for the amp to confirm.
+func (z *Zone) PowerOff() error {
+	return z.setPower(false)
+}
+
+func (z *Zone) setPower(on bool) error {
+	if err := z.a.checkZone(z.n); err != nil {
+		return err
+	}
+	p := z.prefix()
+	if z.n == 1 {
+		p = "ZM"
+	}
+	cmd := p + "OFF"
+	if on {
+		cmd = p + "ON"
+	}
+	return z.a.setConfirm(cmd, zoneIs[proto.Power](z.n), cmd)
+}
+
+// SetVolume sets the zone's volume to db, rounded to the nearest
+// half step, and waits for the amp to confirm. Like Amp.SetVolume,
+// it caps db at the WithVolumeLimit limit.
+func (z *Zone) SetVolume(db float64) error {
+	if z.n == 1 {
+		return z.a.SetVolume(db)
+	}
+	if err := z.a.checkZone(z.n); err != nil {
+		return err
+	}
+	enc, err := encodeVolume(db)
+	if err != nil {
+		return err
+	}
+	limited := db > z.a.volLimit
+	if limited {
+		enc = encodeLevel(z.a.volLimit, zeroLevel)
+	}
+	cmd := z.prefix() + enc
+	if err := z.a.setConfirm(cmd, zoneIs[proto.Volume](z.n), cmd); err != nil {
+		return err
+	}
+	if limited {
+		return ErrVolumeLimited
+	}
+	return nil
+}
+
+// SetSource switches the zone to src and waits for the amp to
+// confirm.
+func (z *Zone) SetSource(src InputSource) error {
+	if z.n == 1 {
+		return z.a.SelectInput(src)
+	}
+	if src == "" {
+		return errors.New("empty input source")
+	}
+	if err := z.a.checkZone(z.n); err != nil {
+		return err
+	}
+	if err := z.a.checkInput(src); err != nil {
+		return err
+	}
+	cmd := z.prefix() + string(src)
+	return z.a.setConfirm(cmd, zoneIs[proto.Input](z.n), cmd)
+}
+
+// Mute mutes or unmutes the zone and waits for the amp to confirm.
+func (z *Zone) Mute(on bool) error {
+	if z.n == 1 {
+		return z.a.Mute(on)
+	}
+	if err := z.a.checkZone(z.n); err != nil {
+		return err
+	}
+	cmd := z.prefix() + "MUOFF"
+	if on {
+		cmd = z.prefix() + "MUON"
+	}
+	return z.a.setConfirm(cmd, zoneIs[proto.Mute](z.n), cmd)
+}
@@ -0,0 +1,92 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+package avr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"code.google.com/p/go-avr/avr/proto"
+)
+
+// LinkVolume keeps the zone's volume offsetDB from the main zone's,
+// as for whole-home party mode, until ctx is done, and then returns
+// ctx.Err(). It sets the zone's volume at once and whenever the amp
+// reports a new master volume, however it was changed, skipping to
+// the latest during quick changes such as fades. Volumes are capped
+// at the amp's range and the WithVolumeLimit limit. The zone is left
+// alone while it is off and matched again when it comes on. Failures
+// to set the zone's volume are logged as errors.
+//
+// LinkVolume returns an error at once if z is the main zone, or
+// with ErrClosed if the Amp is closed.
+func (z *Zone) LinkVolume(ctx context.Context, offsetDB float64) error {
+	if z.n == 1 {
+		return errors.New("can't link the main zone's volume to itself")
+	}
+	if err := z.a.checkZone(z.n); err != nil {
+		return err
+	}
+	events, cancel := z.a.Subscribe()
+	defer cancel()
+
+	on := true // until the amp says otherwise
+	snap := z.a.CachedState()
+	if zs := z.zoneStatus(&snap.Status); snap.Revision > 0 && zs != nil {
+		on = zs.Power
+	}
+	main, err := z.a.GetVolume()
+	if err != nil {
+		return err
+	}
+	follow := func(db float64) {
+		if !on {
+			return
+		}
+		db = min(max(db+offsetDB, proto.MinVolume), MaxVolume)
+		if err := z.SetVolume(db); err != nil && !errors.Is(err, ErrVolumeLimited) {
+			z.a.log.Error("avr: linking zone volume", "zone", z.n, "volume", db, "err", err)
+		}
+	}
+	follow(main)
+	for {
+		changed := false
+		err := z.a.nextEvents(ctx, events, func(ev Event) {
+			switch ev := ev.(type) {
+			case VolumeChanged:
+				main, changed = ev.Volume, true
+			case Resynced:
+				main, changed = ev.Status.Volume, true
+				if zs := z.zoneStatus(&ev.Status); zs != nil {
+					on = zs.Power
+				}
+			case ZoneEvent:
+				if p, ok := ev.Event.(PowerChanged); ok && ev.Zone == z.n && p.On != on {
+					on, changed = p.On, p.On
+				}
+			}
+		})
+		switch {
+		case errors.Is(err, ErrClosed):
+			return fmt.Errorf("linking zone %d volume: %w", z.n, err)
+		case err != nil:
+			return err
+		}
+		if changed {
+			follow(main)
+		}
+	}
+}
+
+// zoneStatus returns the zone's part of st, or nil.
+func (z *Zone) zoneStatus(st *Status) *ZoneStatus {
+	switch z.n {
+	case 2:
+		return st.Zone2
+	case 3:
+		return st.Zone3
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+// Avrctl controls a Denon AVR, or an Onkyo, Yamaha or Pioneer
+// receiver, from the command line.
+//
+// Usage:
+//
+//	avrctl [--addr host[:port]] [--brand brand] [--json] command [args]
+//	avrctl --config file [--amp name] [--json] command [args]
+//
+// Commands:
+//
+//	power [on|off]         show or set the power state
+//	volume [dB|up|down]    show or set the master volume
+//	mute [on|off]          show or set muting
+//	input [source]         show or select the input, such as bd
+//	surround [mode]        show or set the surround mode
+//	status                 show the amp's status
+//	info                   show the amp's model, firmware and addresses
+//	restore file           restore a status saved with --json status
+//	scene name             run a scene from the --config file
+//	update                 have the amp check for newer firmware
+//	watch                  print events as the amp reports them
+//	send command           send a raw command, such as MVUP
+//	query command          send a raw command and print the reply
+//
+// The address defaults to $AVR_ADDR. The brand is denon, marantz,
+// onkyo, which also covers Integra, yamaha or pioneer; the surround,
+// status, info, restore and update commands need a Denon or Marantz.
+// Raw commands are in the brand's own protocol, such as "?V" for
+// Pioneer.
+//
+// Alternatively, --config names a file of amps for avr.LoadConfig,
+// defaulting to $AVR_CONFIG, and --amp picks one of them by name;
+// it defaults to the first.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.google.com/p/go-avr/avr"
+	"code.google.com/p/go-avr/avr/eiscp"
+	"code.google.com/p/go-avr/avr/pioneer"
+	"code.google.com/p/go-avr/avr/yamaha"
+)
+
+// Flags
+var (
+	addr    = flag.String("addr", os.Getenv("AVR_ADDR"), "host[:port] of AVR")
+	brand   = flag.String("brand", "denon", "receiver brand: denon, marantz, onkyo, yamaha or pioneer")
+	jsonOut = flag.Bool("json", false, "print JSON")
+	timeout = flag.Duration("timeout", 5*time.Second, "how long to wait for the amp")
+	config  = flag.String("config", os.Getenv("AVR_CONFIG"), "file of amps to pick from with --amp")
+	ampName = flag.String("amp", "", "name of the amp in the --config file")
+)
+
+// manager holds the amps of the --config file, if used.
+var manager *avr.Manager
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: avrctl [flags] power|volume|mute|input|surround|status|info|restore|scene|update|watch|send|query [args]\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("avrctl: ")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+	}
+	if *addr == "" && *config == "" {
+		log.Fatalf("--addr, $AVR_ADDR, --config or $AVR_CONFIG required")
+	}
+	*brand = strings.ToLower(*brand)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	var r avr.Receiver
+	switch {
+	case *addr == "" || *ampName != "":
+		m, err := avr.LoadConfig(*config, avr.WithCommandTimeout(*timeout))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer m.Close()
+		manager = m
+		if *ampName == "" {
+			names := m.Names()
+			if len(names) == 0 {
+				log.Fatalf("%s: no amps", *config)
+			}
+			*ampName = names[0]
+		}
+		if r = m.Receiver(*ampName); r == nil {
+			log.Fatalf("%s: no amp %q", *config, *ampName)
+		}
+		*brand = m.Brand(*ampName)
+	case *brand == "denon", *brand == "marantz":
+		r = avr.New(*addr, avr.WithCommandTimeout(*timeout))
+	case *brand == "onkyo", *brand == "integra":
+		r = eiscp.New(*addr)
+	case *brand == "yamaha":
+		r = yamaha.New(*addr)
+	case *brand == "pioneer":
+		r = pioneer.New(*addr)
+	default:
+		log.Fatalf("unknown brand %q", *brand)
+	}
+	if manager == nil {
+		defer r.Close()
+	}
+	if amp, ok := r.(*avr.Amp); ok {
+		pctx, cancel := context.WithTimeout(ctx, *timeout)
+		err := amp.PingContext(pctx)
+		cancel()
+		if err != nil {
+			log.Fatalf("connecting to amp at %s: %v", amp.Addr(), err)
+		}
+	}
+
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+	if err := run(ctx, r, cmd, args); err != nil {
+		log.Fatalf("%s: %v", cmd, err)
+	}
+}
+
+func run(ctx context.Context, r avr.Receiver, cmd string, args []string) error {
+	arg := ""
+	if len(args) > 0 {
+		arg = strings.Join(args, " ")
+	}
+	amp, _ := r.(*avr.Amp)
+	switch cmd {
+	case "surround", "status", "info", "restore", "scene", "update":
+		if amp == nil {
+			return fmt.Errorf("not supported by %s receivers", *brand)
+		}
+	}
+	switch cmd {
+	case "power":
+		switch arg {
+		case "":
+			on, err := r.PowerState()
+			if err != nil {
+				return err
+			}
+			return show(map[string]bool{"on": on}, onOff(on))
+		case "on":
+			return r.PowerOn()
+		case "off":
+			return r.PowerOff()
+		}
+	case "volume":
+		switch arg {
+		case "":
+			db, err := r.GetVolume()
+			if err != nil {
+				return err
+			}
+			return show(map[string]float64{"db": db}, fmt.Sprintf("%vdB", db))
+		case "up":
+			return r.VolumeUp()
+		case "down":
+			return r.VolumeDown()
+		}
+		db, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(arg), "db"), 64)
+		if err != nil {
+			return fmt.Errorf("bad volume %q", arg)
+		}
+		return r.SetVolume(db)
+	case "mute":
+		switch arg {
+		case "":
+			m, err := r.IsMuted()
+			if err != nil {
+				return err
+			}
+			return show(map[string]bool{"muted": m}, onOff(m))
+		case "on":
+			return r.Mute(true)
+		case "off":
+			return r.Mute(false)
+		}
+	case "input":
+		if arg == "" {
+			src, err := r.CurrentInput()
+			if err != nil {
+				return err
+			}
+			return show(map[string]avr.InputSource{"input": src}, string(src))
+		}
+		return r.SelectInput(avr.InputSource(strings.ToUpper(arg)))
+	case "surround":
+		if arg == "" {
+			m, err := amp.GetSurroundMode()
+			if err != nil {
+				return err
+			}
+			return show(map[string]avr.SurroundMode{"mode": m}, string(m))
+		}
+		return amp.SetSurroundMode(avr.SurroundMode(strings.ToUpper(arg)))
+	case "status":
+		st, err := amp.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return show(st, statusText(st))
+	case "info":
+		d, err := amp.DeviceInfo(ctx)
+		if err != nil {
+			return err
+		}
+		return show(map[string]string{
+			"model":         d.Model,
+			"brand":         d.Brand,
+			"friendly_name": d.FriendlyName,
+			"firmware":      d.Firmware,
+			"mac":           d.MAC.String(),
+			"ip":            d.IP.String(),
+		}, fmt.Sprintf("model:    %s %s\nname:     %s\nfirmware: %s\nmac:      %s\nip:       %s",
+			d.Brand, d.Model, d.FriendlyName, d.Firmware, d.MAC, d.IP))
+	case "restore":
+		if arg == "" {
+			usage()
+		}
+		b, err := os.ReadFile(arg)
+		if err != nil {
+			return err
+		}
+		st := new(avr.Status)
+		if err := json.Unmarshal(b, st); err != nil {
+			return fmt.Errorf("parsing %s: %v", arg, err)
+		}
+		return amp.ApplyStatus(ctx, st)
+	case "scene":
+		if arg == "" || manager == nil {
+			usage()
+		}
+		return manager.RunScene(ctx, *ampName, arg)
+	case "update":
+		u, err := amp.TriggerUpdateCheck(ctx)
+		if err != nil {
+			return err
+		}
+		return show(map[string]bool{"available": u.State == avr.UpdateAvailable}, strings.ToLower(string(u.State)))
+	case "watch":
+		return watch(ctx, r)
+	case "send":
+		if arg == "" {
+			usage()
+		}
+		return r.SendRaw(ctx, arg)
+	case "query":
+		if arg == "" {
+			usage()
+		}
+		l, err := r.QueryRaw(ctx, arg)
+		if err != nil {
+			return err
+		}
+		return show(map[string]string{"reply": l}, l)
+	default:
+		usage()
+	}
+	return fmt.Errorf("bad argument %q", arg)
+}
+
+// show prints v as JSON with --json, else text.
+func show(v any, text string) error {
+	if *jsonOut {
+		return json.NewEncoder(os.Stdout).Encode(v)
+	}
+	fmt.Println(text)
+	return nil
+}
+
+func statusText(st *avr.Status) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "power:    %s\n", onOff(st.Power))
+	fmt.Fprintf(&b, "volume:   %vdB\n", st.Volume)
+	fmt.Fprintf(&b, "muted:    %v\n", st.Muted)
+	fmt.Fprintf(&b, "input:    %s\n", st.Input)
+	fmt.Fprintf(&b, "surround: %s", st.SurroundMode)
+	for i, z := range []*avr.ZoneStatus{st.Zone2, st.Zone3} {
+		if z != nil {
+			fmt.Fprintf(&b, "\nzone %d:   %s, %vdB, %s, muted %v", i+2, onOff(z.Power), z.Volume, z.Source, z.Muted)
+		}
+	}
+	return b.String()
+}
+
+// watch prints events until ctx is done.
+func watch(ctx context.Context, r avr.Receiver) error {
+	events, cancel := r.Subscribe()
+	defer cancel()
+	enc := json.NewEncoder(os.Stdout)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if *jsonOut {
+				name := strings.TrimPrefix(fmt.Sprintf("%T", ev), "avr.")
+				if err := enc.Encode(map[string]any{"type": name, "event": ev}); err != nil {
+					return err
+				}
+				continue
+			}
+			fmt.Printf("%s %T %+v\n", time.Now().Format(time.TimeOnly), ev, ev)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,143 @@
+// Copyright 2011 Google Inc.
+// See LICENSE file in root.
+
+// Avrd serves a Denon AVR over HTTP, and optionally gRPC and the
+// amp's own telnet protocol; see packages avrhttp, avrgrpc and
+// avrproxy.
+//
+// The amp is given by --addr or, alternatively, picked by --amp from
+// a --config file of amps for avr.LoadConfig, defaulting to the
+// first. Its settings, such as the volume limit and MAC address,
+// then come from the file.
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+
+	"code.google.com/p/go-avr/avr"
+	"code.google.com/p/go-avr/avr/avrgrpc"
+	"code.google.com/p/go-avr/avr/avrhttp"
+	"code.google.com/p/go-avr/avr/avrprom"
+	"code.google.com/p/go-avr/avr/avrproxy"
+
+	// Brands a --config file may name for its other receivers.
+	_ "code.google.com/p/go-avr/avr/eiscp"
+	_ "code.google.com/p/go-avr/avr/pioneer"
+	_ "code.google.com/p/go-avr/avr/yamaha"
+)
+
+// Flags
+var (
+	addr     = flag.String("addr", "", "host[:port] of AVR")
+	config   = flag.String("config", os.Getenv("AVR_CONFIG"), "file of amps to pick from with --amp")
+	ampName  = flag.String("amp", "", "name of the amp in the --config file")
+	listen   = flag.String("listen", ":8080", "address to serve HTTP on")
+	grpcAddr = flag.String("grpc", "", "address to serve gRPC on, if any")
+	proxy    = flag.String("proxy", "", "address to relay telnet protocol clients on, if any")
+	metrics  = flag.Bool("metrics", false, "serve Prometheus metrics on /metrics")
+	readOnly = flag.Bool("read-only", false, "refuse requests that would change the amp's state")
+	reclaim  = flag.Duration("reclaim", time.Minute, "how soon to retake the amp's session from another client; 0 to wait for a request")
+	history  = flag.Int("history", 256, "number of recent events to serve on /history")
+	histLog  = flag.String("history-log", "", "file to append events to as JSON lines, rotated at 10MB")
+	local    = flag.String("local-addr", "", "local IP address to connect to the AVR from, if any")
+)
+
+func main() {
+	flag.Parse()
+	if *addr == "" && *config == "" {
+		log.Fatalf("--addr, --config or $AVR_CONFIG required")
+	}
+	opts := []avr.Option{avr.WithEventHistory(*history)}
+	if *histLog != "" {
+		f, err := avr.OpenRotatingFile(*histLog, 10<<20, 5)
+		if err != nil {
+			log.Fatalf("history log: %v", err)
+		}
+		defer f.Close()
+		opts = append(opts, avr.WithHistoryLog(f))
+	}
+	if *local != "" {
+		ip := net.ParseIP(*local)
+		if ip == nil {
+			log.Fatalf("bad --local-addr %q", *local)
+		}
+		opts = append(opts, avr.WithLocalAddr(ip))
+	}
+	if *reclaim > 0 {
+		opts = append(opts, avr.WithReclaim(*reclaim, 16*(*reclaim)))
+	}
+	if *readOnly {
+		opts = append(opts, avr.WithReadOnly())
+	}
+	handler := http.NewServeMux()
+	if *metrics {
+		c := avrprom.NewCollector()
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(c)
+		opts = append(opts, avr.WithMetrics(c))
+		handler.Handle("GET /metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	}
+	amp := openAmp(opts)
+	defer amp.Close()
+	handler.Handle("/", avrhttp.NewHandler(amp))
+	if *grpcAddr != "" {
+		l, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatalf("grpc: %v", err)
+		}
+		s := grpc.NewServer()
+		avrgrpc.RegisterAvrServiceServer(s, avrgrpc.NewServer(amp))
+		log.Printf("Serving gRPC on %s", *grpcAddr)
+		go func() { log.Fatalf("grpc: %v", s.Serve(l)) }()
+	}
+	if *proxy != "" {
+		l, err := net.Listen("tcp", *proxy)
+		if err != nil {
+			log.Fatalf("proxy: %v", err)
+		}
+		log.Printf("Relaying telnet clients on %s", *proxy)
+		go func() { log.Fatalf("proxy: %v", avrproxy.NewServer(amp).Serve(l)) }()
+	}
+	log.Printf("Serving AVR at %s on %s", amp.Addr(), *listen)
+	if err := http.ListenAndServe(*listen, handler); err != nil {
+		log.Fatalf("http: %v", err)
+	}
+}
+
+// openAmp returns the amp at --addr or, if none is given or --amp is,
+// the one named by --amp in the --config file, with opts.
+func openAmp(opts []avr.Option) *avr.Amp {
+	if *addr != "" && *ampName == "" {
+		return avr.New(*addr, opts...)
+	}
+	if *config == "" {
+		log.Fatalf("--amp needs --config or $AVR_CONFIG")
+	}
+	m, err := avr.LoadConfig(*config, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	name := *ampName
+	if name == "" {
+		names := m.Names()
+		if len(names) == 0 {
+			log.Fatalf("%s: no amps", *config)
+		}
+		name = names[0]
+	}
+	amp := m.Amp(name)
+	if amp == nil {
+		log.Fatalf("%s: no Denon or Marantz amp %q", *config, name)
+	}
+	// The caller closes amp, the only receiver m has created.
+	return amp
+}